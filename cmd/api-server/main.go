@@ -5,10 +5,18 @@ import (
 	"os"
 
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api/middleware"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/jwt"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository/memory"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/urlpolicy"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/healthcheck"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/lifecycle"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/purge"
 )
 
 func main() {
@@ -36,26 +44,160 @@ func mainLogic() int {
 	// logger.SetLevel(config.Options.LogLevel)
 
 	// Setup Database
-	db, err := repository.NewDatabaseService(config.Database.Host, config.Database.Port,
-		config.Database.Username, config.Database.Password, config.Database.DBName)
+	db, err := setupRepository(config.Database)
 	if err != nil {
 		logger.Error(fmt.Sprintf("database error: %s", err.Error()), log.Field("type", "setup"))
 		return 1
 	}
 	defer db.Close()
 
+	if dbs, ok := db.(*repository.DatabaseService); ok {
+		dbs.Database.IgnoreURLScheme = config.Options.IgnoreURLScheme
+	}
+
+	if config.Database.Driver == core.DatabaseDriverMemory {
+		logger.Warn("using in-memory repository, data will be lost on exit", log.Field("type", "setup"))
+	}
+
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			return migrateCommand(logger, db, os.Args[2:])
+		default:
+			logger.Error(fmt.Sprintf("unknown command: %s", os.Args[1]), log.Field("type", "setup"))
+			return 2
+		}
+	}
+
+	if config.Database.AutoMigrate && config.Database.Driver != core.DatabaseDriverMemory {
+		if retCode := migrateCommand(logger, db, []string{"up"}); retCode != 0 {
+			return retCode
+		}
+	}
+
+	urlPolicy := &urlpolicy.Policy{
+		AllowPrivateAddresses: config.URLPolicy.AllowPrivateAddresses,
+		AllowedHosts:          config.URLPolicy.AllowedHosts,
+		DeniedHosts:           config.URLPolicy.DeniedHosts,
+		AllowedPorts:          config.URLPolicy.AllowedPorts,
+	}
+
 	server := api.NewServer(config.Webserver.Host, config.Webserver.Port, config.Options.DevMode, logger, db)
+	server.Fetcher = fetcher.NewFetcher(config.Options.FeedFetchTimeout, config.Options.FeedFetchMaxSize, urlPolicy)
+	server.URLPolicy = urlPolicy
+	server.ValidateFeeds = config.Options.ValidateFeeds
+	server.DefaultPollInterval = config.Options.DefaultPollInterval
+	server.MinPollInterval = config.Options.MinPollInterval
+	server.MaxPollInterval = config.Options.MaxPollInterval
+	server.LeaseTimeout = config.Options.FeedLeaseTimeout
+	server.IgnoreURLScheme = config.Options.IgnoreURLScheme
+
+	if config.Auth.Enabled {
+		staticKeys := make(map[string]entities.APIKey, len(config.Auth.Keys))
+		for _, key := range config.Auth.Keys {
+			staticKeys[key.KeyHash] = entities.APIKey{Name: key.Name, Scopes: key.Scopes}
+		}
+
+		server.Auth = &middleware.Authenticator{
+			Logger:        logger,
+			StaticKeys:    staticKeys,
+			Store:         db,
+			AnonymousRead: config.Auth.AnonymousRead,
+			RolesClaim:    config.Auth.RolesClaim,
+			RoleMapping:   config.Auth.RoleMapping,
+		}
+
+		var tokenKeys jwt.KeyProvider
+		if config.Auth.JWKSFile != "" {
+			keySet, err := jwt.LoadKeySetFile(config.Auth.JWKSFile)
+			if err != nil {
+				logger.Error(fmt.Sprintf("error loading jwks file: %s", err.Error()), log.Field("type", "setup"))
+				return 1
+			}
+			tokenKeys = keySet
+		} else if config.Auth.JWKSURL != "" {
+			tokenKeys = jwt.NewRemoteKeySet(config.Auth.JWKSURL)
+		}
+
+		if tokenKeys != nil {
+			server.Auth.Tokens = jwt.NewVerifier(tokenKeys, config.Auth.TokenIssuer, config.Auth.TokenAudience)
+			logger.Info("bearer token authentication enabled", log.Field("type", "setup"))
+		}
+	} else {
+		logger.Warn("authentication is disabled, anyone reaching the server can change feeds",
+			log.Field("type", "setup"))
+	}
+
+	// Background workers
+	var workers []core.ShutDowner
+	if config.HealthCheck.Enabled {
+		checker := healthcheck.NewChecker(logger, db, server.Fetcher, config.HealthCheck.Interval,
+			config.HealthCheck.Concurrency, config.HealthCheck.DisableAfter)
+		checker.Start()
+		workers = append(workers, checker)
+		logger.Info("feed health checker started", log.Field("type", "setup"))
+	}
+	if config.Purge.Enabled {
+		purger := purge.NewPurger(logger, db, config.Purge.Interval, config.Purge.Retention)
+		purger.Start()
+		workers = append(workers, purger)
+		logger.Info("deleted feeds purge started", log.Field("type", "setup"))
+	}
 
 	// Spawn SIGINT/SIGTERM listener
-	go lifecycle.TerminateHandler(logger, server)
+	terminated := make(chan struct{})
+	go func() {
+		lifecycle.TerminateHandler(logger, server, workers...)
+		close(terminated)
+	}()
 
 	logger.Info("listenning for incoming requests", log.Field("type", "runtime"))
 	err = server.ListenAndServe()
 	if err != nil {
 		logger.Error(fmt.Sprintf("unexpected error while serving HTTP: %s", err))
+		// The background workers must stop before the database is closed
+		lifecycle.ShutDownWorkers(logger, workers...)
 		return 1
 	}
 
+	// Wait for the background workers to stop before closing the database
+	<-terminated
+
 	logger.Info("APP gracefully terminated")
 	return 0
 }
+
+// closableRepository is a repository that holds resources that need to be released.
+type closableRepository interface {
+	core.Repository
+	Close() error
+}
+
+// setupRepository returns the repository backend selected in the configuration.
+func setupRepository(dbConfig core.DatabaseConfiguration) (closableRepository, error) {
+	var driver, dsn string
+
+	switch dbConfig.Driver {
+	case core.DatabaseDriverMemory:
+		return memory.NewRepository(), nil
+	case core.DatabaseDriverPostgres:
+		driver = repository.DriverPostgres
+		dsn = repository.PostgresDSN(dbConfig.Host, dbConfig.Port,
+			dbConfig.Username, dbConfig.Password, dbConfig.DBName, dbConfig.SSLMode)
+	case core.DatabaseDriverSQLite:
+		driver = repository.DriverSQLite
+		dsn = repository.SQLiteDSN(dbConfig.Path)
+	default:
+		driver = repository.DriverMySQL
+		dsn = repository.MySQLDSN(dbConfig.Host, dbConfig.Port,
+			dbConfig.Username, dbConfig.Password, dbConfig.DBName)
+	}
+
+	dbs, err := repository.NewDatabaseService(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbs, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// migrateCommand runs the 'migrate' subcommand, which manages the database schema.
+//
+// Usage: api-server migrate up|down|status|canonicalize
+//
+//	up           applies all pending migrations
+//	down         reverts the latest applied migration
+//	status       lists all migrations and whether they have been applied
+//	canonicalize sets the canonical URL of every feed again, after the IgnoreURLScheme option changes
+func migrateCommand(logger log.Logger, db closableRepository, args []string) int {
+	dbs, ok := db.(*repository.DatabaseService)
+	if !ok {
+		logger.Error("migrations are only supported by SQL database drivers", log.Field("type", "migrate"))
+		return 1
+	}
+
+	if len(args) != 1 {
+		logger.Error("usage: api-server migrate up|down|status|canonicalize", log.Field("type", "migrate"))
+		return 2
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := dbs.Database.MigrateUp()
+		for _, m := range applied {
+			logger.Info("migration applied", log.Fields(log.FieldsMap{
+				"type": "migrate", "version": m.Version, "description": m.Description}))
+		}
+		if err != nil {
+			logger.Error(err.Error(), log.Field("type", "migrate"))
+			return 1
+		}
+		logger.Info(fmt.Sprintf("database schema up to date (%d migrations applied)", len(applied)),
+			log.Field("type", "migrate"))
+	case "down":
+		reverted, err := dbs.Database.MigrateDown()
+		if err != nil {
+			logger.Error(err.Error(), log.Field("type", "migrate"))
+			return 1
+		}
+		if reverted == nil {
+			logger.Info("no migrations to revert", log.Field("type", "migrate"))
+			return 0
+		}
+		logger.Info("migration reverted", log.Fields(log.FieldsMap{
+			"type": "migrate", "version": reverted.Version, "description": reverted.Description}))
+	case "status":
+		statuses, err := dbs.Database.MigrationStatus()
+		if err != nil {
+			logger.Error(err.Error(), log.Field("type", "migrate"))
+			return 1
+		}
+		for _, status := range statuses {
+			fields := log.FieldsMap{
+				"type": "migrate", "version": status.Version, "description": status.Description, "applied": status.Applied}
+			if status.Applied {
+				fields["applied_at"] = status.AppliedAt.Format(time.RFC3339)
+			}
+			logger.Info("migration status", log.Fields(fields))
+		}
+	case "canonicalize":
+		changed, err := dbs.Database.CanonicalizeFeedURLs()
+		if err != nil {
+			logger.Error(err.Error(), log.Field("type", "migrate"))
+			return 1
+		}
+		logger.Info(fmt.Sprintf("canonical URLs up to date (%d feeds changed)", changed), log.Field("type", "migrate"))
+	default:
+		logger.Error(fmt.Sprintf("unknown migrate command: %s", args[0]), log.Field("type", "migrate"))
+		return 2
+	}
+
+	return 0
+}
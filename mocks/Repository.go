@@ -5,6 +5,7 @@ package mocks
 import (
 	entities "github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
 	mock "github.com/stretchr/testify/mock"
+	time "time"
 )
 
 // Repository is an autogenerated mock type for the Repository type
@@ -12,13 +13,120 @@ type Repository struct {
 	mock.Mock
 }
 
-// AddFeed provides a mock function with given fields: feed
-func (_m *Repository) AddFeed(feed entities.Feed) error {
-	ret := _m.Called(feed)
+// AddAPIKey provides a mock function with given fields: key, keyHash
+func (_m *Repository) AddAPIKey(key entities.APIKey, keyHash string) (entities.APIKey, error) {
+	ret := _m.Called(key, keyHash)
+
+	var r0 entities.APIKey
+	if rf, ok := ret.Get(0).(func(entities.APIKey, string) entities.APIKey); ok {
+		r0 = rf(key, keyHash)
+	} else {
+		r0 = ret.Get(0).(entities.APIKey)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(entities.APIKey, string) error); ok {
+		r1 = rf(key, keyHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddCategory provides a mock function with given fields: name
+func (_m *Repository) AddCategory(name string) (entities.Category, error) {
+	ret := _m.Called(name)
+
+	var r0 entities.Category
+	if rf, ok := ret.Get(0).(func(string) entities.Category); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Get(0).(entities.Category)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddFeed provides a mock function with given fields: feed, actor
+func (_m *Repository) AddFeed(feed entities.Feed, actor entities.Actor) (entities.Feed, error) {
+	ret := _m.Called(feed, actor)
+
+	var r0 entities.Feed
+	if rf, ok := ret.Get(0).(func(entities.Feed, entities.Actor) entities.Feed); ok {
+		r0 = rf(feed, actor)
+	} else {
+		r0 = ret.Get(0).(entities.Feed)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(entities.Feed, entities.Actor) error); ok {
+		r1 = rf(feed, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddFeeds provides a mock function with given fields: feeds, opts, actor
+func (_m *Repository) AddFeeds(feeds entities.Feeds, opts entities.FeedBatchOptions, actor entities.Actor) (entities.FeedBatchResults, error) {
+	ret := _m.Called(feeds, opts, actor)
+
+	var r0 entities.FeedBatchResults
+	if rf, ok := ret.Get(0).(func(entities.Feeds, entities.FeedBatchOptions, entities.Actor) entities.FeedBatchResults); ok {
+		r0 = rf(feeds, opts, actor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(entities.FeedBatchResults)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(entities.Feeds, entities.FeedBatchOptions, entities.Actor) error); ok {
+		r1 = rf(feeds, opts, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddProvider provides a mock function with given fields: name
+func (_m *Repository) AddProvider(name string) (entities.Provider, error) {
+	ret := _m.Called(name)
+
+	var r0 entities.Provider
+	if rf, ok := ret.Get(0).(func(string) entities.Provider); ok {
+		r0 = rf(name)
+	} else {
+		r0 = ret.Get(0).(entities.Provider)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteAPIKey provides a mock function with given fields: id
+func (_m *Repository) DeleteAPIKey(id string) error {
+	ret := _m.Called(id)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(entities.Feed) error); ok {
-		r0 = rf(feed)
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -26,13 +134,13 @@ func (_m *Repository) AddFeed(feed entities.Feed) error {
 	return r0
 }
 
-// DeleteFeed provides a mock function with given fields: url
-func (_m *Repository) DeleteFeed(url string) error {
-	ret := _m.Called(url)
+// DeleteCategory provides a mock function with given fields: id, cascade, actor
+func (_m *Repository) DeleteCategory(id uint64, cascade bool, actor entities.Actor) error {
+	ret := _m.Called(id, cascade, actor)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(url)
+	if rf, ok := ret.Get(0).(func(uint64, bool, entities.Actor) error); ok {
+		r0 = rf(id, cascade, actor)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -40,22 +148,222 @@ func (_m *Repository) DeleteFeed(url string) error {
 	return r0
 }
 
-// GetFeeds provides a mock function with given fields: provider, category, enabled
-func (_m *Repository) GetFeeds(provider string, category string, enabled bool) (entities.Feeds, error) {
-	ret := _m.Called(provider, category, enabled)
+// DeleteFeed provides a mock function with given fields: id, actor
+func (_m *Repository) DeleteFeed(id string, actor entities.Actor) error {
+	ret := _m.Called(id, actor)
 
-	var r0 entities.Feeds
-	if rf, ok := ret.Get(0).(func(string, string, bool) entities.Feeds); ok {
-		r0 = rf(provider, category, enabled)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, entities.Actor) error); ok {
+		r0 = rf(id, actor)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteProvider provides a mock function with given fields: id, cascade, actor
+func (_m *Repository) DeleteProvider(id uint64, cascade bool, actor entities.Actor) error {
+	ret := _m.Called(id, cascade, actor)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uint64, bool, entities.Actor) error); ok {
+		r0 = rf(id, cascade, actor)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAPIKeyByHash provides a mock function with given fields: keyHash
+func (_m *Repository) GetAPIKeyByHash(keyHash string) (entities.APIKey, error) {
+	ret := _m.Called(keyHash)
+
+	var r0 entities.APIKey
+	if rf, ok := ret.Get(0).(func(string) entities.APIKey); ok {
+		r0 = rf(keyHash)
+	} else {
+		r0 = ret.Get(0).(entities.APIKey)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(keyHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAPIKeys provides a mock function with given fields:
+func (_m *Repository) GetAPIKeys() (entities.APIKeys, error) {
+	ret := _m.Called()
+
+	var r0 entities.APIKeys
+	if rf, ok := ret.Get(0).(func() entities.APIKeys); ok {
+		r0 = rf()
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(entities.Feeds)
+			r0 = ret.Get(0).(entities.APIKeys)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAuditEntries provides a mock function with given fields: query
+func (_m *Repository) GetAuditEntries(query entities.AuditQuery) (entities.AuditPage, error) {
+	ret := _m.Called(query)
+
+	var r0 entities.AuditPage
+	if rf, ok := ret.Get(0).(func(entities.AuditQuery) entities.AuditPage); ok {
+		r0 = rf(query)
+	} else {
+		r0 = ret.Get(0).(entities.AuditPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(entities.AuditQuery) error); ok {
+		r1 = rf(query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetCategories provides a mock function with given fields:
+func (_m *Repository) GetCategories() (entities.Categories, error) {
+	ret := _m.Called()
+
+	var r0 entities.Categories
+	if rf, ok := ret.Get(0).(func() entities.Categories); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(entities.Categories)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFeed provides a mock function with given fields: id
+func (_m *Repository) GetFeed(id string) (entities.Feed, error) {
+	ret := _m.Called(id)
+
+	var r0 entities.Feed
+	if rf, ok := ret.Get(0).(func(string) entities.Feed); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(entities.Feed)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFeedByURL provides a mock function with given fields: canonicalURL
+func (_m *Repository) GetFeedByURL(canonicalURL string) (entities.Feed, error) {
+	ret := _m.Called(canonicalURL)
+
+	var r0 entities.Feed
+	if rf, ok := ret.Get(0).(func(string) entities.Feed); ok {
+		r0 = rf(canonicalURL)
+	} else {
+		r0 = ret.Get(0).(entities.Feed)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(canonicalURL)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFeedHealth provides a mock function with given fields: id
+func (_m *Repository) GetFeedHealth(id string) (entities.FeedHealth, error) {
+	ret := _m.Called(id)
+
+	var r0 entities.FeedHealth
+	if rf, ok := ret.Get(0).(func(string) entities.FeedHealth); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(entities.FeedHealth)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFeeds provides a mock function with given fields: query
+func (_m *Repository) GetFeeds(query entities.FeedQuery) (entities.FeedsPage, error) {
+	ret := _m.Called(query)
+
+	var r0 entities.FeedsPage
+	if rf, ok := ret.Get(0).(func(entities.FeedQuery) entities.FeedsPage); ok {
+		r0 = rf(query)
+	} else {
+		r0 = ret.Get(0).(entities.FeedsPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(entities.FeedQuery) error); ok {
+		r1 = rf(query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProviders provides a mock function with given fields:
+func (_m *Repository) GetProviders() (entities.Providers, error) {
+	ret := _m.Called()
+
+	var r0 entities.Providers
+	if rf, ok := ret.Get(0).(func() entities.Providers); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(entities.Providers)
 		}
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string, bool) error); ok {
-		r1 = rf(provider, category, enabled)
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -77,16 +385,179 @@ func (_m *Repository) HealthCheck() error {
 	return r0
 }
 
-// SetFeedState provides a mock function with given fields: url, enabled
-func (_m *Repository) SetFeedState(url string, enabled bool) error {
-	ret := _m.Called(url, enabled)
+// IterateFeeds provides a mock function with given fields: query, fn
+func (_m *Repository) IterateFeeds(query entities.FeedQuery, fn func(entities.Feed) error) error {
+	ret := _m.Called(query, fn)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, bool) error); ok {
-		r0 = rf(url, enabled)
+	if rf, ok := ret.Get(0).(func(entities.FeedQuery, func(entities.Feed) error) error); ok {
+		r0 = rf(query, fn)
 	} else {
 		r0 = ret.Error(0)
 	}
 
 	return r0
 }
+
+// LeaseDueFeeds provides a mock function with given fields: limit, leaseTimeout
+func (_m *Repository) LeaseDueFeeds(limit int, leaseTimeout time.Duration) (entities.Feeds, error) {
+	ret := _m.Called(limit, leaseTimeout)
+
+	var r0 entities.Feeds
+	if rf, ok := ret.Get(0).(func(int, time.Duration) entities.Feeds); ok {
+		r0 = rf(limit, leaseTimeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(entities.Feeds)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, time.Duration) error); ok {
+		r1 = rf(limit, leaseTimeout)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeletedFeeds provides a mock function with given fields: deletedBefore, limit, actor
+func (_m *Repository) PurgeDeletedFeeds(deletedBefore time.Time, limit int, actor entities.Actor) (int, error) {
+	ret := _m.Called(deletedBefore, limit, actor)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(time.Time, int, entities.Actor) int); ok {
+		r0 = rf(deletedBefore, limit, actor)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time, int, entities.Actor) error); ok {
+		r1 = rf(deletedBefore, limit, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RecordFeedCheck provides a mock function with given fields: id, check
+func (_m *Repository) RecordFeedCheck(id string, check entities.FeedCheck) (entities.FeedHealth, error) {
+	ret := _m.Called(id, check)
+
+	var r0 entities.FeedHealth
+	if rf, ok := ret.Get(0).(func(string, entities.FeedCheck) entities.FeedHealth); ok {
+		r0 = rf(id, check)
+	} else {
+		r0 = ret.Get(0).(entities.FeedHealth)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, entities.FeedCheck) error); ok {
+		r1 = rf(id, check)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RenameCategory provides a mock function with given fields: id, name
+func (_m *Repository) RenameCategory(id uint64, name string) error {
+	ret := _m.Called(id, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uint64, string) error); ok {
+		r0 = rf(id, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RenameProvider provides a mock function with given fields: id, name
+func (_m *Repository) RenameProvider(id uint64, name string) error {
+	ret := _m.Called(id, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(uint64, string) error); ok {
+		r0 = rf(id, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RestoreFeed provides a mock function with given fields: id, actor
+func (_m *Repository) RestoreFeed(id string, actor entities.Actor) (entities.Feed, error) {
+	ret := _m.Called(id, actor)
+
+	var r0 entities.Feed
+	if rf, ok := ret.Get(0).(func(string, entities.Actor) entities.Feed); ok {
+		r0 = rf(id, actor)
+	} else {
+		r0 = ret.Get(0).(entities.Feed)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, entities.Actor) error); ok {
+		r1 = rf(id, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetFeedFetchState provides a mock function with given fields: id, state, nextFetchAt
+func (_m *Repository) SetFeedFetchState(id string, state entities.FeedFetchState, nextFetchAt time.Time) error {
+	ret := _m.Called(id, state, nextFetchAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, entities.FeedFetchState, time.Time) error); ok {
+		r0 = rf(id, state, nextFetchAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetFeedState provides a mock function with given fields: id, enabled, actor
+func (_m *Repository) SetFeedState(id string, enabled bool, actor entities.Actor) error {
+	ret := _m.Called(id, enabled, actor)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, bool, entities.Actor) error); ok {
+		r0 = rf(id, enabled, actor)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateFeed provides a mock function with given fields: id, update, actor
+func (_m *Repository) UpdateFeed(id string, update entities.FeedUpdate, actor entities.Actor) (entities.Feed, error) {
+	ret := _m.Called(id, update, actor)
+
+	var r0 entities.Feed
+	if rf, ok := ret.Get(0).(func(string, entities.FeedUpdate, entities.Actor) entities.Feed); ok {
+		r0 = rf(id, update, actor)
+	} else {
+		r0 = ret.Get(0).(entities.Feed)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, entities.FeedUpdate, entities.Actor) error); ok {
+		r1 = rf(id, update, actor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -10,27 +10,70 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api/middleware"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/urlpolicy"
 )
 
 // Server is the webserver environment, which holds all its dependencies.
 type Server struct {
 	Logger log.Logger
 	Repo   core.Repository
+	// Fetcher fetches feeds and web pages, to validate and discover feeds.
+	Fetcher *fetcher.Fetcher
+	// ValidateFeeds rejects new feeds whose URL doesn't hold a feed.
+	ValidateFeeds bool
+	// DefaultPollInterval applies to feeds without a poll interval of their own, which must be between
+	// MinPollInterval and MaxPollInterval.
+	DefaultPollInterval time.Duration
+	MinPollInterval     time.Duration
+	MaxPollInterval     time.Duration
+	// LeaseTimeout is the time fetchers have to report back on the feeds leased to them.
+	LeaseTimeout time.Duration
+	// IgnoreURLScheme treats the 'http' and 'https' URLs of a feed as the same feed.
+	IgnoreURLScheme bool
+	// URLPolicy rejects the feed and web page URLs provided that the service must not fetch. Nil allows any URL.
+	// The Fetcher should enforce the same policy, to cover redirects and hosts resolving to different addresses.
+	URLPolicy *urlpolicy.Policy
+	// Auth authenticates requests by their API key or bearer token. Nil lets all requests through.
+	Auth *middleware.Authenticator
+	// FetchDeadline is the time allowed for all the fetches made while serving a single request, so that they end
+	// before the server write timeout, however many there are.
+	FetchDeadline time.Duration
 
 	Router     *gin.Engine
 	HTTPServer http.Server
 }
 
+// writeTimeout is the time allowed to serve a request, once its headers are read.
+const writeTimeout = 10 * time.Second
+
+// defaultFetchDeadline leaves requests fetching feeds time to respond before the write timeout.
+const defaultFetchDeadline = writeTimeout - 2*time.Second
+
 // NewServer creates a new server.
 func NewServer(addr string, port int, devMode bool, logger log.Logger, repo core.Repository) *Server {
-	s := &Server{Logger: logger, Repo: repo}
+	s := &Server{
+		Logger:  logger,
+		Repo:    repo,
+		Fetcher: fetcher.NewFetcher(fetcher.DefaultTimeout, fetcher.DefaultMaxSize, nil),
+
+		DefaultPollInterval: core.DefaultPollInterval,
+		MinPollInterval:     core.DefaultMinPollInterval,
+		MaxPollInterval:     core.DefaultMaxPollInterval,
+		LeaseTimeout:        core.DefaultFeedLeaseTimeout,
+		IgnoreURLScheme:     core.DefaultIgnoreURLScheme,
+		FetchDeadline:       defaultFetchDeadline,
+	}
 
 	if !devMode {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	s.Router = gin.New()
+	// Match routes against the escaped path, so that percent-encoded slashes don't split path segments
+	s.Router.UseRawPath = true
 
 	s.Router.Use(
 		middleware.GinReqLogger(logger, time.RFC3339, "request served", "http-router-mux"),
@@ -44,7 +87,7 @@ func NewServer(addr string, port int, devMode bool, logger log.Logger, repo core
 		Addr:           fmt.Sprintf("%s:%d", addr, port),
 		Handler:        s.Router,
 		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+		WriteTimeout:   writeTimeout,
 		MaxHeaderBytes: 1 << 20,
 	}
 
@@ -59,11 +102,50 @@ func (s *Server) setupRoutes(devMode bool) {
 	v1 := s.Router.Group("/api/v1")
 	v1.GET("/healthcheck", s.Healthcheck)
 
+	// Routes are only served to requests granted the scope they need: reading, changing or administering.
+	// Deleting is reserved to admins, as providers and categories are deleted along with their feeds.
+	read := s.authorize(entities.ScopeRead)
+	write := s.authorize(entities.ScopeWrite)
+	admin := s.authorize(entities.ScopeAdmin)
+
+	v1.POST("/feeds:action", write, s.FeedsAction)
+
 	feedsGroup := v1.Group("/feeds")
-	feedsGroup.GET("", s.GetFeeds)
-	feedsGroup.POST("", s.AddFeed)
-	feedsGroup.PUT("/*url", s.SetFeedState)
-	feedsGroup.DELETE("/*url", s.DeleteFeed)
+	feedsGroup.GET("", read, s.GetFeeds)
+	feedsGroup.POST("", write, s.AddFeed)
+	feedsGroup.GET("/export.opml", read, s.ExportFeedsOPML)
+	feedsGroup.POST("/import", write, s.ImportFeeds)
+	feedsGroup.POST("/discover", write, s.DiscoverFeeds)
+	// Leasing feeds changes their schedule
+	feedsGroup.GET("/due", write, s.GetDueFeeds)
+	feedsGroup.GET("/:ref", read, s.GetFeed)
+	feedsGroup.GET("/:ref/health", read, s.GetFeedHealth)
+	// Custom methods, such as ':refresh' and ':restore'
+	feedsGroup.POST("/:ref", write, s.FeedAction)
+	feedsGroup.PATCH("/:id", write, s.UpdateFeed)
+	feedsGroup.DELETE("/*ref", admin, s.DeleteFeed)
+	// Also serves the deprecated route with feed URLs in the path, kept for existing clients
+	feedsGroup.PUT("/*ref", write, s.PutFeed)
+
+	providersGroup := v1.Group("/providers")
+	providersGroup.GET("", read, s.GetProviders)
+	providersGroup.POST("", write, s.AddProvider)
+	providersGroup.PATCH("/:id", write, s.RenameProvider)
+	providersGroup.DELETE("/:id", admin, s.DeleteProvider)
+
+	categoriesGroup := v1.Group("/categories")
+	categoriesGroup.GET("", read, s.GetCategories)
+	categoriesGroup.POST("", write, s.AddCategory)
+	categoriesGroup.PATCH("/:id", write, s.RenameCategory)
+	categoriesGroup.DELETE("/:id", admin, s.DeleteCategory)
+
+	// The audit log tells who made each change, API key names and users included
+	v1.GET("/audit", admin, s.GetAuditEntries)
+
+	apiKeysGroup := v1.Group("/apikeys", admin)
+	apiKeysGroup.GET("", s.GetAPIKeys)
+	apiKeysGroup.POST("", s.AddAPIKey)
+	apiKeysGroup.DELETE("/:id", s.DeleteAPIKey)
 
 	// Profiler
 	// URL: https://<IP>:<PORT>/debug/pprof/
@@ -73,6 +155,16 @@ func (s *Server) setupRoutes(devMode bool) {
 	}
 }
 
+// authorize returns a handler that only lets through requests authenticated with the scope provided, unless
+// authentication is off.
+func (s *Server) authorize(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Auth != nil {
+			s.Auth.Authorize(c, scope)
+		}
+	}
+}
+
 // ListenAndServe listens and serves incoming requests.
 func (s *Server) ListenAndServe() error {
 	if err := s.HTTPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
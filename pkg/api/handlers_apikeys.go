@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api/middleware"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// GetAPIKeys handles requests to get all the API keys issued.
+// Keys provided through the configuration are not listed.
+func (s *Server) GetAPIKeys(c *gin.Context) {
+	keys, err := s.Repo.GetAPIKeys()
+	if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, keys)
+}
+
+// AddAPIKey handles requests to issue a new API key.
+// The key is only returned in the response, as only its hash is stored.
+func (s *Server) AddAPIKey(c *gin.Context) {
+	bodyData := struct {
+		Name   string   `json:"name" binding:"required,max=50"`
+		Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=read write admin"`
+	}{}
+
+	err := c.ShouldBindJSON(&bodyData)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing body: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	rawKey, err := middleware.NewAPIKey()
+	if err != nil {
+		s.Logger.Error(fmt.Sprintf("error generating api key: %s", err.Error()))
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	key, err := s.Repo.AddAPIKey(entities.APIKey{Name: bodyData.Name, Scopes: bodyData.Scopes},
+		middleware.HashAPIKey(rawKey))
+	if errT, ok := err.(*repository.DBDUPError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 409, "api key name already exists in the database")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(201, struct {
+		entities.APIKey
+		Key string `json:"key"`
+	}{APIKey: key, Key: rawKey})
+}
+
+// DeleteAPIKey handles requests to revoke an API key.
+func (s *Server) DeleteAPIKey(c *gin.Context) {
+	err := s.Repo.DeleteAPIKey(c.Param("id"))
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 404, "api key not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.Status(204)
+}
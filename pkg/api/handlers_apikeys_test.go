@@ -0,0 +1,231 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api/middleware"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/jwt"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/jwt/jwttest"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorization(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.Auth = &middleware.Authenticator{
+		Logger: logger,
+		StaticKeys: map[string]entities.APIKey{
+			middleware.HashAPIKey("reader-key"): {Name: "reader", Scopes: []string{entities.ScopeRead}},
+			middleware.HashAPIKey("writer-key"): {Name: "writer", Scopes: []string{entities.ScopeWrite}},
+		},
+		Store: repo,
+	}
+	router := server.Router
+
+	tests := map[string]struct {
+		method             string
+		url                string
+		body               string
+		key                string
+		anonymousRead      bool
+		expectedStatusCode int
+	}{
+		"healthcheck without key": {method: "GET", url: "/api/v1/healthcheck", expectedStatusCode: 200},
+		"read without key":        {method: "GET", url: "/api/v1/feeds", expectedStatusCode: 401},
+		"anonymous read":          {method: "GET", url: "/api/v1/feeds", anonymousRead: true, expectedStatusCode: 200},
+		"anonymous write": {method: "POST", url: "/api/v1/providers", body: `{"name": "CNN"}`, anonymousRead: true,
+			expectedStatusCode: 401},
+		"unknown key": {method: "GET", url: "/api/v1/feeds", key: "unknown-key", anonymousRead: true,
+			expectedStatusCode: 401},
+		"read with read key": {method: "GET", url: "/api/v1/providers", key: "reader-key", expectedStatusCode: 200},
+		"write with read key": {method: "POST", url: "/api/v1/providers", body: `{"name": "CNN"}`, key: "reader-key",
+			expectedStatusCode: 403},
+		"write with write key": {method: "POST", url: "/api/v1/providers", body: `{"name": "CNN"}`,
+			key: "writer-key", expectedStatusCode: 201},
+		"read with write key":  {method: "GET", url: "/api/v1/categories", key: "writer-key", expectedStatusCode: 200},
+		"admin with write key": {method: "GET", url: "/api/v1/apikeys", key: "writer-key", expectedStatusCode: 403},
+		"delete with write key": {method: "DELETE", url: "/api/v1/categories/1", key: "writer-key",
+			expectedStatusCode: 403},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server.Auth.AnonymousRead = test.anonymousRead
+
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			require.NoError(t, err)
+			if test.key != "" {
+				req.Header.Set("X-API-Key", test.key)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestBearerTokenAuthorization(t *testing.T) {
+	rsaKey := jwttest.NewRSAKey(t, "rsa-1")
+	ecKey := jwttest.NewECKey(t, "ec-1")
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwttest.KeySet(t, rsaKey, ecKey))
+	}))
+	defer jwksServer.Close()
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.Auth = &middleware.Authenticator{
+		Logger:      logger,
+		Tokens:      jwt.NewVerifier(jwt.NewRemoteKeySet(jwksServer.URL), "https://auth.example.com", "feeds-mgmt"),
+		RolesClaim:  "realm_access.roles",
+		RoleMapping: map[string]string{"feeds-admins": entities.RoleAdmin},
+	}
+	router := server.Router
+
+	token := func(key jwttest.SigningKey, roles ...string) string {
+		return key.Sign(t, map[string]interface{}{
+			"iss":          "https://auth.example.com",
+			"aud":          "feeds-mgmt",
+			"sub":          "jane",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"realm_access": map[string]interface{}{"roles": roles},
+		})
+	}
+
+	feed, err := repo.GetFeedByURL("https://feeds.skynews.com/feeds/rss/uk.xml")
+	require.NoError(t, err)
+
+	// Tests run in order, as the feed is deleted by the last one
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		body               string
+		token              string
+		expectedStatusCode int
+	}{
+		{name: "no token", method: "GET", url: "/api/v1/feeds", expectedStatusCode: 401},
+		{name: "invalid token", method: "GET", url: "/api/v1/feeds", token: "not-a-token", expectedStatusCode: 401},
+		{name: "viewer reads", method: "GET", url: "/api/v1/feeds", token: token(rsaKey, "viewer"),
+			expectedStatusCode: 200},
+		{name: "viewer writes", method: "POST", url: "/api/v1/providers", body: `{"name": "CNN"}`,
+			token: token(rsaKey, "viewer"), expectedStatusCode: 403},
+		{name: "no roles", method: "GET", url: "/api/v1/feeds", token: token(ecKey), expectedStatusCode: 403},
+		{name: "unknown role", method: "GET", url: "/api/v1/feeds", token: token(ecKey, "superuser"),
+			expectedStatusCode: 403},
+		{name: "editor writes", method: "POST", url: "/api/v1/providers", body: `{"name": "CNN"}`,
+			token: token(ecKey, "viewer", "editor"), expectedStatusCode: 201},
+		{name: "editor deletes", method: "DELETE", url: "/api/v1/feeds/" + feed.ID, token: token(ecKey, "editor"),
+			expectedStatusCode: 403},
+		{name: "editor manages api keys", method: "GET", url: "/api/v1/apikeys", token: token(ecKey, "editor"),
+			expectedStatusCode: 403},
+		{name: "mapped admin deletes", method: "DELETE", url: "/api/v1/feeds/" + feed.ID,
+			token: token(rsaKey, "feeds-admins"), expectedStatusCode: 204},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			require.NoError(t, err)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestAPIKeysHandlers(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.Auth = &middleware.Authenticator{
+		Logger: logger,
+		StaticKeys: map[string]entities.APIKey{
+			middleware.HashAPIKey("admin-key"): {Name: "admin", Scopes: []string{entities.ScopeAdmin}},
+		},
+		Store: repo,
+	}
+	router := server.Router
+
+	send := func(method string, url string, body string, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", key)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Validation
+	for _, body := range []string{
+		`{"name": "ci"}`,
+		`{"name": "ci", "scopes": []}`,
+		`{"name": "ci", "scopes": ["read", "superuser"]}`,
+		`{"scopes": ["read"]}`,
+	} {
+		w := send("POST", "/api/v1/apikeys", body, "admin-key")
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	// Issue
+	w := send("POST", "/api/v1/apikeys", `{"name": "ci", "scopes": ["write"]}`, "admin-key")
+	require.Equal(t, 201, w.Code)
+	issued := struct {
+		entities.APIKey
+		Key string `json:"key"`
+	}{}
+	err := json.Unmarshal(w.Body.Bytes(), &issued)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", issued.Name)
+	assert.Equal(t, []string{"write"}, issued.Scopes)
+	assert.Len(t, issued.Key, 64)
+
+	w = send("POST", "/api/v1/apikeys", `{"name": "ci", "scopes": ["read"]}`, "admin-key")
+	assert.Equal(t, 409, w.Code)
+
+	// Keys are only stored by their hash
+	key, err := repo.GetAPIKeyByHash(middleware.HashAPIKey(issued.Key))
+	require.NoError(t, err)
+	assert.Equal(t, issued.ID, key.ID)
+
+	// Use
+	w = send("POST", "/api/v1/categories", `{"name": "Sport"}`, issued.Key)
+	assert.Equal(t, 201, w.Code)
+	w = send("GET", "/api/v1/apikeys", "", issued.Key)
+	assert.Equal(t, 403, w.Code)
+
+	// List
+	w = send("GET", "/api/v1/apikeys", "", "admin-key")
+	require.Equal(t, 200, w.Code)
+	keys := entities.APIKeys{}
+	err = json.Unmarshal(w.Body.Bytes(), &keys)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, issued.ID, keys[0].ID)
+	assert.NotContains(t, w.Body.String(), issued.Key)
+
+	// Revoke
+	w = send("DELETE", "/api/v1/apikeys/"+issued.ID, "", "admin-key")
+	assert.Equal(t, 204, w.Code)
+	w = send("DELETE", "/api/v1/apikeys/"+issued.ID, "", "admin-key")
+	assert.Equal(t, 404, w.Code)
+	w = send("POST", "/api/v1/categories", `{"name": "Music"}`, issued.Key)
+	assert.Equal(t, 401, w.Code)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// GetAuditEntries handles requests to list the changes made to feeds, the most recent first.
+// Entries can be filtered by feed, by actor and by the time they were recorded at, with 'since' inclusive and 'until'
+// exclusive, both in RFC 3339 format.
+func (s *Server) GetAuditEntries(c *gin.Context) {
+	queryParams := struct {
+		FeedID string    `form:"feed_id"`
+		Actor  string    `form:"actor"`
+		Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+		Until  time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+		Limit  int       `form:"limit" binding:"min=1,max=1000"`
+		Cursor string    `form:"cursor"`
+	}{
+		Limit: 100,
+	}
+
+	if err := c.ShouldBindQuery(&queryParams); err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing query parameters: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	if queryParams.FeedID != "" && !isValidFeedID(queryParams.FeedID) {
+		s.Logger.Info("feed_id provided is not valid")
+		RespondWithError(c, 400, "feed_id provided is not valid")
+		return
+	}
+
+	page, err := s.Repo.GetAuditEntries(entities.AuditQuery{
+		FeedID: queryParams.FeedID,
+		Actor:  queryParams.Actor,
+		Since:  queryParams.Since,
+		Until:  queryParams.Until,
+		Limit:  queryParams.Limit,
+		Cursor: queryParams.Cursor,
+	})
+	if errT, ok := err.(*repository.DBInvalidCursorError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 400, "cursor provided is not valid")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, page)
+}
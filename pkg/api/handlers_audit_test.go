@@ -0,0 +1,98 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api/middleware"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditHandlers(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.Auth = &middleware.Authenticator{
+		Logger: logger,
+		StaticKeys: map[string]entities.APIKey{
+			middleware.HashAPIKey("writer-key"): {Name: "writer", Scopes: []string{entities.ScopeWrite}},
+			middleware.HashAPIKey("admin-key"):  {Name: "admin", Scopes: []string{entities.ScopeAdmin}},
+		},
+	}
+	router := server.Router
+
+	send := func(method string, url string, body string, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set("X-Request-ID", "req-"+method)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	getAudit := func(query string) entities.AuditPage {
+		w := send("GET", "/api/v1/audit?"+query, "", "admin-key")
+		require.Equal(t, 200, w.Code, w.Body.String())
+		page := entities.AuditPage{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+
+	start := time.Now()
+
+	w := send("POST", "/api/v1/feeds", `{"url": "http://example.com/rss.xml", "provider": "Example", "category": "UK"}`,
+		"writer-key")
+	require.Equal(t, 201, w.Code)
+	feed := entities.Feed{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &feed))
+
+	w = send("PATCH", "/api/v1/feeds/"+feed.ID, `{"enabled": false}`, "writer-key")
+	require.Equal(t, 200, w.Code)
+	w = send("DELETE", "/api/v1/feeds/"+feed.ID, "", "admin-key")
+	require.Equal(t, 204, w.Code)
+
+	// Only admins read the audit log
+	w = send("GET", "/api/v1/audit", "", "writer-key")
+	assert.Equal(t, 403, w.Code)
+
+	page := getAudit("feed_id=" + feed.ID)
+	require.Len(t, page.Entries, 3)
+	assert.Equal(t, entities.AuditActionDelete, page.Entries[0].Action)
+	assert.Equal(t, "apikey:admin", page.Entries[0].Actor)
+	assert.Equal(t, "req-DELETE", page.Entries[0].RequestID)
+	assert.Equal(t, entities.AuditActionUpdate, page.Entries[1].Action)
+	assert.True(t, page.Entries[1].Before.Enabled)
+	assert.False(t, page.Entries[1].After.Enabled)
+	assert.Equal(t, entities.AuditActionCreate, page.Entries[2].Action)
+	assert.Equal(t, "apikey:writer", page.Entries[2].Actor)
+	assert.Equal(t, "req-POST", page.Entries[2].RequestID)
+
+	page = getAudit("actor=apikey:writer")
+	assert.Len(t, page.Entries, 2)
+
+	page = getAudit("since=" + url.QueryEscape(start.Format(time.RFC3339Nano)))
+	assert.Len(t, page.Entries, 3)
+	page = getAudit("until=" + url.QueryEscape(start.Format(time.RFC3339Nano)))
+	assert.Len(t, page.Entries, len(GenData()))
+
+	page = getAudit("limit=2")
+	require.Len(t, page.Entries, 2)
+	require.NotEmpty(t, page.NextCursor)
+	page = getAudit("limit=2&cursor=" + page.NextCursor)
+	assert.Equal(t, entities.AuditActionCreate, page.Entries[0].Action)
+
+	for _, query := range []string{"feed_id=abc", "since=yesterday", "limit=0", "cursor=abc"} {
+		w = send("GET", "/api/v1/audit?"+query, "", "admin-key")
+		assert.Equal(t, 400, w.Code, query)
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// categories returns the categories resource.
+func (s *Server) categories() namedResource {
+	return namedResource{
+		noun:   "category",
+		getAll: func() (interface{}, error) { return s.Repo.GetCategories() },
+		add:    func(name string) (interface{}, error) { return s.Repo.AddCategory(name) },
+		rename: s.Repo.RenameCategory,
+		delete: s.Repo.DeleteCategory,
+	}
+}
+
+// GetCategories handles requests to get all categories.
+func (s *Server) GetCategories(c *gin.Context) {
+	s.getNamedRecords(c, s.categories())
+}
+
+// AddCategory handles requests to add a new category.
+func (s *Server) AddCategory(c *gin.Context) {
+	s.addNamedRecord(c, s.categories())
+}
+
+// RenameCategory handles requests to rename a category.
+func (s *Server) RenameCategory(c *gin.Context) {
+	s.renameNamedRecord(c, s.categories())
+}
+
+// DeleteCategory handles requests to delete a category.
+// Categories with feeds are only deleted, along with their feeds, if the 'cascade' query parameter is true.
+func (s *Server) DeleteCategory(c *gin.Context) {
+	s.deleteNamedRecord(c, s.categories())
+}
@@ -1,23 +1,40 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api/middleware"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/formats"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
 )
 
 // GetFeeds handles requests to get feeds.
+// Feeds are listed as JSON by default, or streamed as CSV or NDJSON when the 'Accept' header asks for them.
 func (s *Server) GetFeeds(c *gin.Context) {
 	queryParams := struct {
-		Enabled  bool   `form:"enabled"`
+		// Enabled accepts a boolean or 'all' to list feeds in any state
+		Enabled  string `form:"enabled"`
 		Provider string `form:"provider"`
 		Category string `form:"category"`
+		Language string `form:"language"`
+		// IncludeDeleted lists deleted feeds, not purged yet, too
+		IncludeDeleted bool   `form:"include_deleted"`
+		Limit          int    `form:"limit" binding:"min=1,max=1000"`
+		Cursor         string `form:"cursor"`
+		Sort           string `form:"sort" binding:"oneof=url -url provider -provider category -category created_at -created_at"`
 	}{
-		Enabled: true,
+		Enabled: "true",
+		Limit:   100,
+		Sort:    entities.FeedSortURL,
 	}
 
 	if err := c.ShouldBindQuery(&queryParams); err != nil {
@@ -26,22 +43,64 @@ func (s *Server) GetFeeds(c *gin.Context) {
 		return
 	}
 
-	feeds, err := s.Repo.GetFeeds(queryParams.Provider, queryParams.Category, queryParams.Enabled)
-	if err != nil {
+	query := entities.FeedQuery{
+		Provider:       queryParams.Provider,
+		Category:       queryParams.Category,
+		Language:       queryParams.Language,
+		IncludeDeleted: queryParams.IncludeDeleted,
+		Limit:          queryParams.Limit,
+		Cursor:         queryParams.Cursor,
+		Sort:           queryParams.Sort,
+	}
+
+	if queryParams.Enabled != "all" {
+		enabled, err := strconv.ParseBool(queryParams.Enabled)
+		if err != nil {
+			s.Logger.Info(fmt.Sprintf("error parsing query parameters: %s", err.Error()))
+			RespondWithError(c, 400, "enabled must be a boolean or 'all'")
+			return
+		}
+		query.Enabled = &enabled
+	}
+
+	format := c.NegotiateFormat(gin.MIMEJSON, formats.CSVContentType, formats.NDJSONContentType)
+	if format == formats.CSVContentType || format == formats.NDJSONContentType {
+		// Streamed formats list every feed, unless a limit is asked for explicitly
+		if _, ok := c.GetQuery("limit"); !ok {
+			query.Limit = 0
+		}
+		s.streamFeeds(c, query, format)
+		return
+	}
+
+	page, err := s.Repo.GetFeeds(query)
+	if errT, ok := err.(*repository.DBInvalidCursorError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 400, "cursor provided is not valid")
+		return
+	} else if err != nil {
 		s.Logger.Error(err.Error())
 		RespondWithError(c, 500, "Internal error")
 		return
 	}
 
-	c.JSON(200, feeds)
+	c.JSON(200, page)
 }
 
 // AddFeed handles requests to add a new feed.
+//
+// With 'autodiscover' set, the URL may point to a website instead, and the first feed found on it is added.
+// When the server validates feeds, or 'fetch_metadata' is set, the feed is fetched first. It is rejected if it
+// doesn't hold a feed, and its metadata is taken from the feed document otherwise.
 func (s *Server) AddFeed(c *gin.Context) {
 	bodyData := struct {
-		URL      string `json:"url" binding:"required"`
-		Provider string `json:"provider" binding:"required"`
-		Category string `json:"category" binding:"required"`
+		URL           string `json:"url" binding:"required"`
+		Provider      string `json:"provider" binding:"required"`
+		Category      string `json:"category" binding:"required"`
+		Autodiscover  bool   `json:"autodiscover"`
+		FetchMetadata bool   `json:"fetch_metadata"`
+		// PollInterval is in seconds
+		PollInterval int64 `json:"poll_interval"`
 	}{}
 
 	err := c.ShouldBindJSON(&bodyData)
@@ -51,21 +110,157 @@ func (s *Server) AddFeed(c *gin.Context) {
 		return
 	}
 
-	// Need to make sure URL is absolute and scheme is HTTP or HTTPS
-	if !core.IsValideAbsoluteURL(bodyData.URL) {
-		s.Logger.Info("url provided is not valid")
+	feed := entities.Feed{
+		URL:          bodyData.URL,
+		Provider:     bodyData.Provider,
+		Category:     bodyData.Category,
+		Enabled:      true,
+		PollInterval: bodyData.PollInterval,
+	}
+
+	if msg, ok := validateNewFeed(feed); !ok {
+		s.Logger.Info(msg)
+		RespondWithError(c, 400, msg)
+		return
+	}
+
+	if msg, ok := s.validatePollInterval(feed.PollInterval); !ok {
+		s.Logger.Info(msg)
+		RespondWithError(c, 400, msg)
+		return
+	}
+
+	// Discovering and validating the feed share the same deadline
+	ctx, cancel := s.fetchContext(c)
+	defer cancel()
+
+	if msg, ok := s.checkURLPolicy(ctx, feed.URL); !ok {
+		s.Logger.Info(msg)
+		RespondWithError(c, 400, msg)
+		return
+	}
+
+	if bodyData.Autodiscover {
+		candidates, err := s.Fetcher.Discover(ctx, feed.URL)
+		if err != nil {
+			s.Logger.Info(fmt.Sprintf("feed discovery failed: %s", err.Error()))
+			RespondWithError(c, 422, fmt.Sprintf("could not discover feeds: %s", err.Error()))
+			return
+		} else if len(candidates) == 0 {
+			s.Logger.Info(fmt.Sprintf("no feeds found at <%s>", feed.URL))
+			RespondWithError(c, 422, "no feeds found at URL provided")
+			return
+		}
+
+		// Websites may link to feeds anywhere
+		if msg, ok := s.checkURLPolicy(ctx, candidates[0].URL); !ok {
+			s.Logger.Info(msg)
+			RespondWithError(c, 400, fmt.Sprintf("feed found at <%s> is not allowed: %s", candidates[0].URL, msg))
+			return
+		}
+
+		feed.URL = candidates[0].URL
+	}
+
+	if s.ValidateFeeds || bodyData.FetchMetadata {
+		doc, err := s.Fetcher.Fetch(ctx, feed.URL)
+		if err != nil {
+			s.Logger.Info(fmt.Sprintf("feed validation failed: %s", err.Error()))
+			RespondWithError(c, 422, fmt.Sprintf("URL provided is not a valid feed: %s", err.Error()))
+			return
+		}
+		feed.FeedMetadata = doc.Metadata()
+	}
+
+	feed.CanonicalURL, err = core.CanonicalizeURL(feed.URL, s.IgnoreURLScheme)
+	if err != nil {
+		s.Logger.Info(err.Error())
 		RespondWithError(c, 400, "url provided is not valid")
 		return
 	}
 
-	feed := entities.Feed{
-		URL:      bodyData.URL,
-		Provider: bodyData.Provider,
-		Category: bodyData.Category,
-		Enabled:  true,
+	created, err := s.Repo.AddFeed(feed, middleware.RequestActor(c))
+	if errT, ok := err.(*repository.DBDUPError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 409, "RSS URL feed already exists in the database")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(201, created)
+}
+
+// maxFeedBatchSize is the maximum number of feeds that can be added in a single batch.
+const maxFeedBatchSize = 1000
+
+// FeedsAction handles custom method requests on the feeds collection, e.g. 'POST /feeds:batch'.
+func (s *Server) FeedsAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":batch":
+		s.AddFeedsBatch(c)
+	default:
+		NoRoute(c)
+	}
+}
+
+// AddFeedsBatch handles requests to add several feeds at once.
+// It responds with the outcome for each feed, in the same order they were provided.
+//
+// Feeds are added independently, unless 'atomic=true' is provided, in which case either all feeds are added
+// or none of them are. With 'dry_run=true', nothing is added but the outcome is reported all the same.
+func (s *Server) AddFeedsBatch(c *gin.Context) {
+	queryParams := struct {
+		Atomic bool `form:"atomic"`
+		DryRun bool `form:"dry_run"`
+	}{}
+
+	if err := c.ShouldBindQuery(&queryParams); err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing query parameters: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	bodyData := []struct {
+		URL      string `json:"url"`
+		Provider string `json:"provider"`
+		Category string `json:"category"`
+		// PollInterval is in seconds
+		PollInterval int64 `json:"poll_interval"`
+	}{}
+
+	err := c.ShouldBindJSON(&bodyData)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing body: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	feeds := make(entities.Feeds, 0, len(bodyData))
+	for _, item := range bodyData {
+		feeds = append(feeds, entities.Feed{URL: item.URL, Provider: item.Provider, Category: item.Category, Enabled: true,
+			PollInterval: item.PollInterval})
+	}
+
+	s.respondWithFeedBatch(c, feeds, entities.FeedBatchOptions{Atomic: queryParams.Atomic, DryRun: queryParams.DryRun})
+}
+
+// respondWithFeedBatch adds a batch of feeds and responds with the outcome for each of them.
+func (s *Server) respondWithFeedBatch(c *gin.Context, feeds entities.Feeds, opts entities.FeedBatchOptions) {
+	if len(feeds) == 0 || len(feeds) > maxFeedBatchSize {
+		s.Logger.Info(fmt.Sprintf("invalid batch size: %d", len(feeds)))
+		RespondWithError(c, 400, fmt.Sprintf("between 1 and %d feeds must be provided", maxFeedBatchSize))
+		return
 	}
 
-	err = s.Repo.AddFeed(feed)
+	// Feeds may be fetched to validate them, so the batch shares the deadline of single feeds. Each fetch still has
+	// its own timeout, and feeds not checked before the deadline are reported as timed out rather than invalid.
+	ctx, cancel := s.fetchContext(c)
+	defer cancel()
+
+	report, err := s.addFeeds(ctx, feeds, opts, middleware.RequestActor(c))
 	if errT, ok := err.(*repository.DBDUPError); ok {
 		s.Logger.Error(errT.Error())
 		RespondWithError(c, 409, "RSS URL feed already exists in the database")
@@ -76,14 +271,524 @@ func (s *Server) AddFeed(c *gin.Context) {
 		return
 	}
 
-	c.Status(204)
+	if opts.Atomic && report.Failed != 0 {
+		// Atomic batches that only failed because feeds timed out may be added by trying again
+		if report.TimedOut != 0 && !hasFeedBatchStatus(report.Results, entities.FeedBatchInvalid) {
+			c.JSON(504, report)
+			return
+		}
+		c.JSON(422, report)
+		return
+	}
+
+	c.JSON(200, report)
+}
+
+// addFeeds validates and adds a batch of feeds on behalf of the actor provided, and reports the outcome for each of
+// them.
+// Invalid feeds are never passed on to the repository, and in atomic mode they prevent all feeds from being added.
+func (s *Server) addFeeds(ctx context.Context, feeds entities.Feeds, opts entities.FeedBatchOptions,
+	actor entities.Actor) (report entities.FeedBatchReport, err error) {
+	report.Results = make(entities.FeedBatchResults, len(feeds))
+
+	checkedFeeds, msgs, timedOut := s.checkNewFeeds(ctx, feeds)
+
+	validFeeds := make(entities.Feeds, 0, len(feeds))
+	validIndexes := make([]int, 0, len(feeds))
+
+	for i, feed := range checkedFeeds {
+		if timedOut[i] {
+			report.Results[i] = entities.FeedBatchResult{Status: entities.FeedBatchTimedOut, Feed: feed}
+			continue
+		} else if msgs[i] != "" {
+			report.Results[i] = entities.FeedBatchResult{Status: entities.FeedBatchInvalid, Error: msgs[i], Feed: feed}
+			continue
+		}
+
+		validFeeds = append(validFeeds, feed)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if opts.Atomic && len(validFeeds) != len(feeds) {
+		for _, i := range validIndexes {
+			report.Results[i] = entities.FeedBatchResult{Status: entities.FeedBatchSkipped, Feed: feeds[i]}
+		}
+	} else if len(validFeeds) != 0 {
+		results, err := s.Repo.AddFeeds(validFeeds, opts, actor)
+		if err != nil {
+			return report, err
+		}
+
+		for j, result := range results {
+			report.Results[validIndexes[j]] = result
+		}
+	}
+
+	for i, result := range report.Results {
+		switch result.Status {
+		case entities.FeedBatchCreated:
+			report.Created++
+			continue
+		case entities.FeedBatchValid:
+			report.Valid++
+			continue
+		case entities.FeedBatchDuplicate:
+			result.Error = "RSS URL feed already exists in the database"
+		case entities.FeedBatchSkipped:
+			result.Error = "not added because other feeds in the batch could not be added"
+		case entities.FeedBatchTimedOut:
+			result.Error = "not checked before the request deadline, try again with fewer feeds"
+			report.TimedOut++
+		case entities.FeedBatchFailed:
+			s.Logger.Error(result.Error)
+			result.Error = "Internal error"
+		}
+
+		report.Results[i] = result
+		report.Failed++
+	}
+
+	return report, nil
+}
+
+// feedBatchCheckConcurrency is the maximum number of feeds in a batch checked at the same time.
+const feedBatchCheckConcurrency = 8
+
+// checkNewFeeds checks whether each of the feeds in a batch can be added, as checkNewFeed does, a few at a time.
+// It returns the feeds with their canonical URL set, along with the reason why each of them can't be added, empty
+// for those that can.
+// Feeds that could not be checked before the context is done are flagged as timed out as well.
+func (s *Server) checkNewFeeds(ctx context.Context, feeds entities.Feeds) (checkedFeeds entities.Feeds,
+	msgs []string, timedOut []bool) {
+	checkedFeeds = make(entities.Feeds, len(feeds))
+	msgs = make([]string, len(feeds))
+	timedOut = make([]bool, len(feeds))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < feedBatchCheckConcurrency && w < len(feeds); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				checkedFeeds[i], msgs[i], timedOut[i] = s.checkNewFeed(ctx, feeds[i])
+			}
+		}()
+	}
+
+	for i := range feeds {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return checkedFeeds, msgs, timedOut
+}
+
+// hasFeedBatchStatus reports whether any of the results has the status provided.
+func hasFeedBatchStatus(results entities.FeedBatchResults, status string) bool {
+	for _, result := range results {
+		if result.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNewFeed checks whether a feed in a batch can be added, the same way AddFeed does, returning it with its
+// canonical URL set, or the reason why it can't be added otherwise.
+// When the server validates feeds, the feed is fetched and its metadata taken from the feed document.
+// The check times out when the context is done before the host is resolved or the feed fetched, as opposed to the
+// fetch timing out on its own, which makes the feed invalid.
+func (s *Server) checkNewFeed(ctx context.Context, feed entities.Feed) (checkedFeed entities.Feed, msg string,
+	timedOut bool) {
+	if msg, ok := validateNewFeed(feed); !ok {
+		return feed, msg, false
+	}
+
+	if msg, ok := s.validatePollInterval(feed.PollInterval); !ok {
+		return feed, msg, false
+	}
+
+	// The host is resolved, and the feed may be fetched, which is why feeds are checked concurrently
+	if msg, ok := s.checkURLPolicy(ctx, feed.URL); !ok {
+		return feed, msg, ctx.Err() != nil
+	}
+
+	if s.ValidateFeeds {
+		doc, err := s.Fetcher.Fetch(ctx, feed.URL)
+		if err != nil {
+			return feed, fmt.Sprintf("URL provided is not a valid feed: %s", err.Error()), ctx.Err() != nil
+		}
+		feed.FeedMetadata = doc.Metadata()
+	}
+
+	canonicalURL, err := core.CanonicalizeURL(feed.URL, s.IgnoreURLScheme)
+	if err != nil {
+		return feed, "url provided is not valid", false
+	}
+	feed.CanonicalURL = canonicalURL
+
+	return feed, "", false
+}
+
+// validateNewFeed checks whether a feed can be added, returning the reason why not otherwise.
+func validateNewFeed(feed entities.Feed) (msg string, ok bool) {
+	if feed.URL == "" || feed.Provider == "" || feed.Category == "" {
+		return "url, provider and category are required", false
+	}
+
+	// Need to make sure URL is absolute and scheme is HTTP or HTTPS
+	if !core.IsValideAbsoluteURL(feed.URL) {
+		return "url provided is not valid", false
+	}
+
+	return "", true
+}
+
+// checkURLPolicy checks whether the URL policy allows a feed or web page URL, returning the reason why not otherwise.
+func (s *Server) checkURLPolicy(ctx context.Context, rawURL string) (msg string, ok bool) {
+	if s.URLPolicy == nil {
+		return "", true
+	}
+
+	if err := s.URLPolicy.Check(ctx, rawURL); err != nil {
+		return err.Error(), false
+	}
+	return "", true
+}
+
+// fetchContext returns the context for the fetches made while serving a request, which all share a single deadline.
+func (s *Server) fetchContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request.Context(), s.FetchDeadline)
+}
+
+// GetFeed handles requests to get a single feed.
+// The path holds either the feed ID or the percent-encoded feed URL, which finds the feed under any of its variants.
+func (s *Server) GetFeed(c *gin.Context) {
+	ref := c.Param("ref")
+
+	var feed entities.Feed
+	var err error
+
+	if isValidFeedID(ref) {
+		feed, err = s.Repo.GetFeed(ref)
+	} else if canonicalURL, urlErr := core.CanonicalizeURL(ref, s.IgnoreURLScheme); urlErr == nil {
+		feed, err = s.Repo.GetFeedByURL(canonicalURL)
+	} else {
+		s.Logger.Info("id or url provided is not valid")
+		RespondWithError(c, 400, "id or url provided is not valid")
+		return
+	}
+
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, feed)
 }
 
-// SetFeedState handles requests to change a feed enabled state.
-func (s *Server) SetFeedState(c *gin.Context) {
-	url := c.Param("url")
-	url = strings.TrimPrefix(url, "/")
+// UpdateFeed handles requests to change a feed URL, provider, category or enabled state.
+// Fields left out of the request body are left unchanged.
+func (s *Server) UpdateFeed(c *gin.Context) {
+	id := c.Param("id")
+
+	if !isValidFeedID(id) {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
+		return
+	}
+
+	bodyData := struct {
+		URL      *string `json:"url"`
+		Provider *string `json:"provider" binding:"omitempty,min=1,max=30"`
+		Category *string `json:"category" binding:"omitempty,min=1,max=30"`
+		Enabled  *bool   `json:"enabled"`
+		// PollInterval is in seconds
+		PollInterval *int64 `json:"poll_interval"`
+	}{}
+
+	err := c.ShouldBindJSON(&bodyData)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing body: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	if bodyData.URL == nil && bodyData.Provider == nil && bodyData.Category == nil && bodyData.Enabled == nil &&
+		bodyData.PollInterval == nil {
+		s.Logger.Info("no fields provided to update")
+		RespondWithError(c, 400, "at least one of url, provider, category, enabled or poll_interval must be provided")
+		return
+	}
 
+	// Need to make sure URL is absolute and scheme is HTTP or HTTPS
+	if bodyData.URL != nil && !core.IsValideAbsoluteURL(*bodyData.URL) {
+		s.Logger.Info("url provided is not valid")
+		RespondWithError(c, 400, "url provided is not valid")
+		return
+	}
+
+	if bodyData.PollInterval != nil {
+		if msg, ok := s.validatePollInterval(*bodyData.PollInterval); !ok {
+			s.Logger.Info(msg)
+			RespondWithError(c, 400, msg)
+			return
+		}
+	}
+
+	update := entities.FeedUpdate{
+		URL:          bodyData.URL,
+		Provider:     bodyData.Provider,
+		Category:     bodyData.Category,
+		Enabled:      bodyData.Enabled,
+		PollInterval: bodyData.PollInterval,
+	}
+
+	if bodyData.URL != nil {
+		if msg, ok := s.checkURLPolicy(c.Request.Context(), *bodyData.URL); !ok {
+			s.Logger.Info(msg)
+			RespondWithError(c, 400, msg)
+			return
+		}
+
+		canonicalURL, err := core.CanonicalizeURL(*bodyData.URL, s.IgnoreURLScheme)
+		if err != nil {
+			s.Logger.Info(err.Error())
+			RespondWithError(c, 400, "url provided is not valid")
+			return
+		}
+		update.CanonicalURL = &canonicalURL
+	}
+
+	feed, err := s.Repo.UpdateFeed(id, update, middleware.RequestActor(c))
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if errT, ok := err.(*repository.DBDUPError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 409, "RSS URL feed already exists in the database")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, feed)
+}
+
+// FeedAction handles custom method requests on a single feed, e.g. 'POST /feeds/{id}:refresh'.
+func (s *Server) FeedAction(c *gin.Context) {
+	ref := c.Param("ref")
+
+	i := strings.LastIndex(ref, ":")
+	if i == -1 {
+		NoRoute(c)
+		return
+	}
+
+	switch id, action := ref[:i], ref[i:]; action {
+	case ":refresh":
+		s.RefreshFeed(c, id)
+	case ":restore":
+		s.RestoreFeed(c, id)
+	default:
+		NoRoute(c)
+	}
+}
+
+// RefreshFeed handles requests to update the metadata of a feed from its feed document.
+// All metadata fields are replaced, including the ones no longer in the document.
+func (s *Server) RefreshFeed(c *gin.Context, id string) {
+	if !isValidFeedID(id) {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
+		return
+	}
+
+	feed, err := s.Repo.GetFeed(id)
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	ctx, cancel := s.fetchContext(c)
+	defer cancel()
+
+	doc, err := s.Fetcher.Fetch(ctx, feed.URL)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("feed refresh failed: %s", err.Error()))
+		RespondWithError(c, 422, fmt.Sprintf("feed could not be refreshed: %s", err.Error()))
+		return
+	}
+
+	metadata := doc.Metadata()
+	feed, err = s.Repo.UpdateFeed(id, entities.FeedUpdate{Metadata: &metadata}, middleware.RequestActor(c))
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, feed)
+}
+
+// RestoreFeed handles requests to restore a deleted feed, as long as it wasn't purged yet.
+// Restoring a feed that is not deleted succeeds without changing it.
+func (s *Server) RestoreFeed(c *gin.Context, id string) {
+	if !isValidFeedID(id) {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
+		return
+	}
+
+	feed, err := s.Repo.RestoreFeed(id, middleware.RequestActor(c))
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, feed)
+}
+
+// GetFeedHealth handles requests to retrieve the outcome of the latest health checks of a feed.
+func (s *Server) GetFeedHealth(c *gin.Context) {
+	id := c.Param("ref")
+
+	if !isValidFeedID(id) {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
+		return
+	}
+
+	health, err := s.Repo.GetFeedHealth(id)
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, health)
+}
+
+// PutFeed handles PUT requests on a single feed, e.g. 'PUT /feeds/{id}/fetch-state'.
+// Paths holding a feed URL are handled by the deprecated SetFeedStateByURL.
+func (s *Server) PutFeed(c *gin.Context) {
+	ref := strings.TrimPrefix(c.Param("ref"), "/")
+
+	if id := strings.TrimSuffix(ref, "/fetch-state"); id != ref && !core.IsValideAbsoluteURL(ref) {
+		s.SetFeedFetchState(c, id)
+		return
+	}
+
+	s.SetFeedStateByURL(c, ref)
+}
+
+// SetFeedFetchState handles requests from the fetchers to report the fetch state of a feed.
+// The whole fetch state is replaced, so fields left out of the request body are cleared. The next fetch of the feed
+// is scheduled one poll interval from now, which also ends any lease on the feed.
+func (s *Server) SetFeedFetchState(c *gin.Context, id string) {
+	if !isValidFeedID(id) {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
+		return
+	}
+
+	bodyData := struct {
+		ETag            string `json:"etag"`
+		LastModified    string `json:"last_modified"`
+		ContentHash     string `json:"content_hash"`
+		UpdateFrequency int64  `json:"update_frequency"`
+	}{}
+
+	err := c.ShouldBindJSON(&bodyData)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing body: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"etag", bodyData.ETag},
+		{"last_modified", bodyData.LastModified},
+		{"content_hash", bodyData.ContentHash},
+	} {
+		if len([]rune(field.value)) > entities.MaxFeedFetchStateLength {
+			msg := fmt.Sprintf("%s is longer than %d characters", field.name, entities.MaxFeedFetchStateLength)
+			s.Logger.Info(msg)
+			RespondWithError(c, 400, msg)
+			return
+		}
+	}
+
+	if bodyData.UpdateFrequency < 0 {
+		s.Logger.Info("update_frequency provided is not valid")
+		RespondWithError(c, 400, "update_frequency provided is not valid")
+		return
+	}
+
+	state := entities.FeedFetchState{
+		ETag:            bodyData.ETag,
+		LastModified:    bodyData.LastModified,
+		ContentHash:     bodyData.ContentHash,
+		UpdateFrequency: bodyData.UpdateFrequency,
+	}
+
+	feed, err := s.Repo.GetFeed(id)
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	err = s.Repo.SetFeedFetchState(id, state, time.Now().Add(s.pollInterval(feed)))
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.Status(204)
+}
+
+// SetFeedStateByURL handles requests to change a feed enabled state, with the feed URL in the path.
+//
+// Deprecated: URLs don't survive being embedded in a path unscathed, use UpdateFeed instead.
+func (s *Server) SetFeedStateByURL(c *gin.Context, url string) {
 	// Need to make sure URL is absolute and scheme is HTTP or HTTPS
 	if !core.IsValideAbsoluteURL(url) {
 		s.Logger.Info("url provided is not valid")
@@ -91,6 +796,16 @@ func (s *Server) SetFeedState(c *gin.Context) {
 		return
 	}
 
+	id, ok := s.resolveFeedURL(c, url)
+	if !ok {
+		return
+	}
+
+	s.setFeedState(c, id)
+}
+
+// setFeedState parses the request body and changes the enabled state of the feed with the ID provided.
+func (s *Server) setFeedState(c *gin.Context, id string) {
 	bodyData := struct {
 		// Need to make Enabled a bool pointer to validate it exists
 		Enabled *bool `json:"enabled" binding:"required"`
@@ -103,10 +818,10 @@ func (s *Server) SetFeedState(c *gin.Context) {
 		return
 	}
 
-	err = s.Repo.SetFeedState(url, *bodyData.Enabled)
+	err = s.Repo.SetFeedState(id, *bodyData.Enabled, middleware.RequestActor(c))
 	if errT, ok := err.(*repository.DBNotFoundError); ok {
-		s.Logger.Error(errT.Error())
-		RespondWithError(c, 404, "URL not found")
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
 		return
 	} else if err != nil {
 		s.Logger.Error(err.Error())
@@ -117,22 +832,26 @@ func (s *Server) SetFeedState(c *gin.Context) {
 	c.Status(204)
 }
 
-// DeleteFeed handles requests to delete a feed.
+// DeleteFeed handles requests to delete a feed. Feeds are soft-deleted, and can be restored until they are purged.
+// The path holds either the feed ID or, on the deprecated route, the feed URL.
 func (s *Server) DeleteFeed(c *gin.Context) {
-	url := c.Param("url")
-	url = strings.TrimPrefix(url, "/")
+	id := strings.TrimPrefix(c.Param("ref"), "/")
 
-	// Need to make sure URL is absolute and scheme is HTTP or HTTPS
-	if !core.IsValideAbsoluteURL(url) {
-		s.Logger.Info("url provided is not valid")
-		RespondWithError(c, 400, "url provided is not valid")
+	if core.IsValideAbsoluteURL(id) {
+		var ok bool
+		if id, ok = s.resolveFeedURL(c, id); !ok {
+			return
+		}
+	} else if !isValidFeedID(id) {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
 		return
 	}
 
-	err := s.Repo.DeleteFeed(url)
+	err := s.Repo.DeleteFeed(id, middleware.RequestActor(c))
 	if errT, ok := err.(*repository.DBNotFoundError); ok {
-		s.Logger.Error(errT.Error())
-		RespondWithError(c, 404, "URL not found")
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "feed not found")
 		return
 	} else if err != nil {
 		s.Logger.Error(err.Error())
@@ -142,3 +861,36 @@ func (s *Server) DeleteFeed(c *gin.Context) {
 
 	c.Status(204)
 }
+
+// resolveFeedURL returns the ID of the feed with the URL provided, or any URL with the same canonical form, for the
+// deprecated URL routes. It responds with an error and returns false if the feed can't be found.
+func (s *Server) resolveFeedURL(c *gin.Context, url string) (id string, ok bool) {
+	c.Header("Deprecation", "true")
+
+	canonicalURL, err := core.CanonicalizeURL(url, s.IgnoreURLScheme)
+	if err != nil {
+		s.Logger.Info(err.Error())
+		RespondWithError(c, 400, "url provided is not valid")
+		return "", false
+	}
+
+	feed, err := s.Repo.GetFeedByURL(canonicalURL)
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Info(errT.Error())
+		RespondWithError(c, 404, "URL not found")
+		return "", false
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return "", false
+	}
+
+	c.Header("Link", fmt.Sprintf("</api/v1/feeds/%s>; rel=\"successor-version\"", feed.ID))
+	return feed.ID, true
+}
+
+// isValidFeedID reports whether id is a well-formed feed ID.
+func isValidFeedID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+)
+
+// DiscoverFeeds handles requests to find the feeds of a website, given the URL of any of its pages.
+// Feeds found are not added.
+func (s *Server) DiscoverFeeds(c *gin.Context) {
+	bodyData := struct {
+		URL string `json:"url" binding:"required"`
+	}{}
+
+	err := c.ShouldBindJSON(&bodyData)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing body: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	if !core.IsValideAbsoluteURL(bodyData.URL) {
+		s.Logger.Info("url provided is not valid")
+		RespondWithError(c, 400, "url provided is not valid")
+		return
+	}
+
+	ctx, cancel := s.fetchContext(c)
+	defer cancel()
+
+	if msg, ok := s.checkURLPolicy(ctx, bodyData.URL); !ok {
+		s.Logger.Info(msg)
+		RespondWithError(c, 400, msg)
+		return
+	}
+
+	candidates, err := s.Fetcher.Discover(ctx, bodyData.URL)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("feed discovery failed: %s", err.Error()))
+		RespondWithError(c, 422, fmt.Sprintf("could not discover feeds: %s", err.Error()))
+		return
+	}
+
+	c.JSON(200, gin.H{"candidates": candidates})
+}
@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupWebsite returns a server holding a website whose feed is linked from its homepage.
+func setupWebsite() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/news", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" title="News" ` +
+			`href="/news/rss.xml"></head></html>`))
+	})
+	mux.HandleFunc("/news/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<rss version="2.0"><channel><title>News</title></channel></rss>`))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Nothing here</title></head></html>`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDiscoverFeedsHandler(t *testing.T) {
+	website := setupWebsite()
+	defer website.Close()
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	tests := map[string]struct {
+		url                string
+		expectedStatusCode int
+		expectedCandidates entities.FeedCandidates
+	}{
+		"invalid url": {url: "invalid_url", expectedStatusCode: 400},
+		"not found":   {url: website.URL + "/missing", expectedStatusCode: 422},
+		"no feeds":    {url: website.URL + "/empty", expectedStatusCode: 200, expectedCandidates: entities.FeedCandidates{}},
+		"feeds": {
+			url:                website.URL + "/news",
+			expectedStatusCode: 200,
+			expectedCandidates: entities.FeedCandidates{
+				{URL: website.URL + "/news/rss.xml", Title: "News", Format: "rss"},
+			}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			body := `{"url": "` + test.url + `"}`
+			req, err := http.NewRequest("POST", "/api/v1/feeds/discover", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatusCode, w.Code)
+			if w.Code != 200 {
+				return
+			}
+
+			responseBody := struct {
+				Candidates entities.FeedCandidates `json:"candidates"`
+			}{}
+			err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCandidates, responseBody.Candidates)
+		})
+	}
+}
+
+func TestAddFeedHandlerAutodiscover(t *testing.T) {
+	website := setupWebsite()
+	defer website.Close()
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.ValidateFeeds = true
+	router := server.Router
+
+	tests := map[string]struct {
+		url                string
+		expectedStatusCode int
+	}{
+		"no feeds": {url: website.URL + "/empty", expectedStatusCode: 422},
+		"website":  {url: website.URL + "/news", expectedStatusCode: 201},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			body := `{"url": "` + test.url + `", "provider": "Example", "category": "News", "autodiscover": true}`
+			req, err := http.NewRequest("POST", "/api/v1/feeds", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatusCode, w.Code)
+			if w.Code != 201 {
+				return
+			}
+
+			responseBody := entities.Feed{}
+			err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+			require.NoError(t, err)
+			assert.Equal(t, website.URL+"/news/rss.xml", responseBody.URL)
+		})
+	}
+}
+
+func TestAddFeedHandlerAutodiscoverDeadline(t *testing.T) {
+	// Each request takes well under the fetch timeout, but discovering and validating the feed together take longer
+	// than the request deadline
+	website := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte(`<rss version="2.0"><channel><title>News</title></channel></rss>`))
+	}))
+	defer website.Close()
+
+	logger := log.NullLogger{}
+	server := api.NewServer("", 9999, false, logger, setupMemoryRepo(t))
+	server.Fetcher = fetcher.NewFetcher(time.Second, 1<<20, nil)
+	server.ValidateFeeds = true
+	server.FetchDeadline = 250 * time.Millisecond
+	router := server.Router
+
+	w := httptest.NewRecorder()
+	body := `{"url": "` + website.URL + `/rss.xml", "provider": "Example", "category": "News", "autodiscover": true}`
+	req, err := http.NewRequest("POST", "/api/v1/feeds", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 422, w.Code)
+
+	// Either fetch alone fits in the deadline
+	server.ValidateFeeds = false
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/v1/feeds", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 201, w.Code)
+}
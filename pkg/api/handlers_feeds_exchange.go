@@ -0,0 +1,123 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/formats"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// maxImportSize is the maximum size in bytes of documents feeds can be imported from.
+const maxImportSize = 10 << 20
+
+// ExportFeedsOPML handles requests to export all feeds as an OPML document, grouped by category.
+// The document is streamed as feeds are read from the repository.
+func (s *Server) ExportFeedsOPML(c *gin.Context) {
+	s.streamFeeds(c, entities.FeedQuery{Sort: entities.FeedSortCategory}, formats.OPMLContentType)
+}
+
+// streamFeeds writes the feeds matching the query as CSV, NDJSON or OPML, as they are read from the repository.
+//
+// The response status is only sent with the first feed, so that errors found before then can still be reported
+// properly. Errors found afterwards can only be logged.
+func (s *Server) streamFeeds(c *gin.Context, query entities.FeedQuery, contentType string) {
+	newWriter := formats.NewCSVWriter
+	switch contentType {
+	case formats.NDJSONContentType:
+		newWriter = formats.NewNDJSONWriter
+	case formats.OPMLContentType:
+		newWriter = func(w io.Writer) formats.FeedWriter { return formats.NewOPMLWriter(w, "News feeds") }
+	}
+
+	var writer formats.FeedWriter
+	start := func() {
+		c.Header("Content-Type", contentType+"; charset=utf-8")
+		if contentType == formats.OPMLContentType {
+			c.Header("Content-Disposition", `attachment; filename="feeds.opml"`)
+		}
+		c.Status(200)
+		writer = newWriter(c.Writer)
+	}
+
+	err := s.Repo.IterateFeeds(query, func(feed entities.Feed) error {
+		if writer == nil {
+			start()
+		}
+		return writer.Write(feed)
+	})
+	if err != nil {
+		if writer != nil {
+			s.Logger.Error(fmt.Sprintf("error streaming feeds: %s", err.Error()))
+			return
+		}
+
+		if errT, ok := err.(*repository.DBInvalidCursorError); ok {
+			s.Logger.Info(errT.Error())
+			RespondWithError(c, 400, "cursor provided is not valid")
+			return
+		}
+
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	if writer == nil {
+		start()
+	}
+
+	if err := writer.Flush(); err != nil {
+		s.Logger.Error(fmt.Sprintf("error streaming feeds: %s", err.Error()))
+	}
+}
+
+// ImportFeeds handles requests to import feeds from an OPML, CSV or NDJSON document, picked by the 'Content-Type'
+// header. Documents without a content type are parsed as OPML.
+// It responds with the outcome for each feed, in the order they appear in the document.
+//
+// Feeds without a provider or category get the ones in the 'provider' and 'category' query parameters.
+// The 'atomic' and 'dry_run' query parameters behave as in AddFeedsBatch.
+func (s *Server) ImportFeeds(c *gin.Context) {
+	queryParams := struct {
+		Provider string `form:"provider"`
+		Category string `form:"category"`
+		Atomic   bool   `form:"atomic"`
+		DryRun   bool   `form:"dry_run"`
+	}{}
+
+	if err := c.ShouldBindQuery(&queryParams); err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing query parameters: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	var parse func(r io.Reader, defaultProvider string, defaultCategory string) (entities.Feeds, error)
+
+	switch c.ContentType() {
+	case "", formats.OPMLContentType, "text/xml", "application/xml":
+		parse = formats.ParseOPML
+	case formats.CSVContentType:
+		parse = formats.ParseCSV
+	case formats.NDJSONContentType:
+		parse = formats.ParseNDJSON
+	default:
+		s.Logger.Info(fmt.Sprintf("unsupported content type <%s>", c.ContentType()))
+		RespondWithError(c, 415, "content type must be OPML, CSV or NDJSON")
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	feeds, err := parse(body, queryParams.Provider, queryParams.Category)
+	if err != nil {
+		s.Logger.Info(err.Error())
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	s.respondWithFeedBatch(c, feeds, entities.FeedBatchOptions{Atomic: queryParams.Atomic, DryRun: queryParams.DryRun})
+}
@@ -0,0 +1,336 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/formats"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFeedsStreamingHandler(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	tests := map[string]struct {
+		accept              string
+		query               string
+		expectedStatus      int
+		expectedContentType string
+		expectedURLs        []string
+	}{
+		"csv": {
+			accept:              "text/csv",
+			query:               "enabled=all",
+			expectedStatus:      200,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedURLs: []string{
+				"http://feeds.bbci.co.uk/news/technology/rss.xml",
+				"http://feeds.bbci.co.uk/news/uk/rss.xml",
+				"http://feeds.skynews.com/feeds/rss/technology.xml",
+				"http://feeds.skynews.com/feeds/rss/uk.xml"}},
+		"ndjson with limit": {
+			accept:              "application/x-ndjson",
+			query:               "limit=1&sort=-url",
+			expectedStatus:      200,
+			expectedContentType: "application/x-ndjson; charset=utf-8",
+			expectedURLs:        []string{"http://feeds.skynews.com/feeds/rss/technology.xml"}},
+		"no match": {
+			accept:              "text/csv",
+			query:               "provider=Unknown",
+			expectedStatus:      200,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedURLs:        []string{}},
+		"json preferred": {
+			accept:              "application/json, text/csv;q=0.5",
+			expectedStatus:      200,
+			expectedContentType: "application/json; charset=utf-8"},
+		"invalid cursor": {
+			accept:         "text/csv",
+			query:          "cursor=invalid",
+			expectedStatus: 400},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/api/v1/feeds?"+test.query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept", test.accept)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatus, w.Code)
+			if test.expectedContentType != "" {
+				assert.Equal(t, test.expectedContentType, w.Header().Get("Content-Type"))
+			}
+			if test.expectedURLs == nil {
+				return
+			}
+
+			var feeds entities.Feeds
+			if test.accept == "text/csv" {
+				feeds, err = formats.ParseCSV(w.Body, "", "")
+			} else {
+				feeds, err = formats.ParseNDJSON(w.Body, "", "")
+			}
+			require.NoError(t, err)
+
+			urls := []string{}
+			for _, feed := range feeds {
+				urls = append(urls, feed.URL)
+			}
+			assert.Equal(t, test.expectedURLs, urls)
+		})
+	}
+}
+
+func TestExportFeedsOPMLHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/v1/feeds/export.opml", nil)
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, 200, w.Code)
+	assert.Equal("text/x-opml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(`attachment; filename="feeds.opml"`, w.Header().Get("Content-Disposition"))
+
+	feeds, err := formats.ParseOPML(w.Body, "", "")
+	require.NoError(t, err)
+	assert.Equal(entities.Feeds{
+		{URL: "http://feeds.bbci.co.uk/news/technology/rss.xml", Provider: "BBC News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.skynews.com/feeds/rss/technology.xml", Provider: "Sky News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK", Enabled: true},
+		{URL: "http://feeds.skynews.com/feeds/rss/uk.xml", Provider: "Sky News", Category: "UK", Enabled: true},
+	}, feeds)
+}
+
+func TestImportFeedsHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	baseURL := "/api/v1/feeds/import"
+
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <body>
+    <outline text="UK">
+      <outline type="rss" text="BBC UK" xmlUrl="http://feeds.bbci.co.uk/news/uk/rss.xml" provider="BBC News"/>
+      <outline type="rss" text="Example" xmlUrl="http://example.com/rss.xml"/>
+      <outline type="rss" text="Invalid" xmlUrl="invalid_url" provider="Example"/>
+    </outline>
+  </body>
+</opml>`
+
+	countFeeds := func() int64 {
+		page, err := repo.GetFeeds(entities.FeedQuery{})
+		require.NoError(t, err)
+		return page.Total
+	}
+
+	statuses := func(report entities.FeedBatchReport) []string {
+		result := []string{}
+		for _, r := range report.Results {
+			result = append(result, r.Status)
+		}
+		return result
+	}
+
+	t.Run("invalid document", func(t *testing.T) {
+		for _, body := range []string{"not opml", "<opml><body></body></opml>"} {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", baseURL, strings.NewReader(body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(400, w.Code, body)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("POST", baseURL+"?provider=Example&dry_run=true", bytes.NewBufferString(doc))
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, 200, w.Code)
+		report := entities.FeedBatchReport{}
+		err = json.Unmarshal(w.Body.Bytes(), &report)
+		require.NoError(t, err)
+		assert.Equal(0, report.Created)
+		assert.Equal(1, report.Valid)
+		assert.Equal(2, report.Failed)
+		assert.Equal([]string{entities.FeedBatchDuplicate, entities.FeedBatchValid, entities.FeedBatchInvalid}, statuses(report))
+		assert.EqualValues(4, countFeeds())
+	})
+
+	t.Run("missing provider", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("POST", baseURL+"?dry_run=true", bytes.NewBufferString(doc))
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, 200, w.Code)
+		report := entities.FeedBatchReport{}
+		err = json.Unmarshal(w.Body.Bytes(), &report)
+		require.NoError(t, err)
+		assert.Equal([]string{entities.FeedBatchDuplicate, entities.FeedBatchInvalid, entities.FeedBatchInvalid}, statuses(report))
+	})
+
+	t.Run("import", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("POST", baseURL+"?provider=Example", bytes.NewBufferString(doc))
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, 200, w.Code)
+		report := entities.FeedBatchReport{}
+		err = json.Unmarshal(w.Body.Bytes(), &report)
+		require.NoError(t, err)
+		assert.Equal(1, report.Created)
+		assert.Equal([]string{entities.FeedBatchDuplicate, entities.FeedBatchCreated, entities.FeedBatchInvalid}, statuses(report))
+		assert.EqualValues(5, countFeeds())
+
+		feed, err := repo.GetFeedByURL("https://example.com/rss.xml")
+		require.NoError(t, err)
+		assert.Equal("Example", feed.Provider)
+		assert.Equal("UK", feed.Category)
+		assert.True(feed.Enabled)
+	})
+}
+
+func TestImportFeedsFormatsHandler(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	baseURL := "/api/v1/feeds/import?provider=Example&category=Blogs"
+
+	tests := map[string]struct {
+		contentType      string
+		body             string
+		expectedStatus   int
+		expectedStatuses []string
+	}{
+		"csv": {
+			contentType: "text/csv; charset=utf-8",
+			body: "url,provider,category,enabled\n" +
+				"https://csv.example.com/rss.xml,,,false\n" +
+				"http://feeds.bbci.co.uk/news/uk/rss.xml,BBC News,UK,\n" +
+				"invalid_url,,,\n",
+			expectedStatus:   200,
+			expectedStatuses: []string{entities.FeedBatchCreated, entities.FeedBatchDuplicate, entities.FeedBatchInvalid}},
+		"ndjson": {
+			contentType:      "application/x-ndjson",
+			body:             `{"url": "https://ndjson.example.com/rss.xml", "category": "Tech"}` + "\n",
+			expectedStatus:   200,
+			expectedStatuses: []string{entities.FeedBatchCreated}},
+		"invalid csv": {
+			contentType:    "text/csv",
+			body:           "provider,category\nBBC News,UK\n",
+			expectedStatus: 400},
+		"invalid ndjson": {
+			contentType:    "application/x-ndjson",
+			body:           "not json",
+			expectedStatus: 400},
+		"unsupported content type": {
+			contentType:    "application/json",
+			body:           `[{"url": "https://example.com/rss.xml"}]`,
+			expectedStatus: 415},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", baseURL, strings.NewReader(test.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", test.contentType)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatus, w.Code)
+			if test.expectedStatuses == nil {
+				return
+			}
+
+			report := entities.FeedBatchReport{}
+			err = json.Unmarshal(w.Body.Bytes(), &report)
+			require.NoError(t, err)
+
+			statuses := []string{}
+			for _, r := range report.Results {
+				statuses = append(statuses, r.Status)
+			}
+			assert.Equal(t, test.expectedStatuses, statuses)
+		})
+	}
+
+	feed, err := repo.GetFeedByURL("https://csv.example.com/rss.xml")
+	require.NoError(t, err)
+	assert.Equal(t, "Example", feed.Provider)
+	assert.Equal(t, "Blogs", feed.Category)
+	assert.False(t, feed.Enabled)
+
+	feed, err = repo.GetFeedByURL("https://ndjson.example.com/rss.xml")
+	require.NoError(t, err)
+	assert.Equal(t, "Tech", feed.Category)
+	assert.True(t, feed.Enabled)
+}
+
+func TestImportFeedsValidationHandler(t *testing.T) {
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rss.xml" {
+			w.Write([]byte(`<rss version="2.0"><channel><title>Example</title></channel></rss>`))
+			return
+		}
+		w.Write([]byte(`<!DOCTYPE html><html><body>Example</body></html>`))
+	}))
+	defer feedServer.Close()
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.Fetcher = fetcher.NewFetcher(time.Second, 1<<20, nil)
+	server.ValidateFeeds = true
+	router := server.Router
+
+	body := `{"url": "` + feedServer.URL + `/rss.xml"}` + "\n" + `{"url": "` + feedServer.URL + `/index.html"}` + "\n"
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/api/v1/feeds/import?provider=Example&category=Blogs",
+		strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, 200, w.Code)
+	report := entities.FeedBatchReport{}
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, entities.FeedBatchCreated, report.Results[0].Status)
+	assert.Equal(t, entities.FeedBatchInvalid, report.Results[1].Status)
+	assert.Contains(t, report.Results[1].Error, "URL provided is not a valid feed")
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+)
+
+// GetDueFeeds handles requests from the fetchers for the next feeds to fetch.
+//
+// The feeds returned are leased to the fetcher until their 'next_fetch_at' time, and won't be returned to anyone
+// else until then. Fetchers are expected to report back through SetFeedFetchState, which schedules the next fetch.
+func (s *Server) GetDueFeeds(c *gin.Context) {
+	queryParams := struct {
+		Limit int `form:"limit" binding:"min=1,max=100"`
+	}{
+		Limit: 10,
+	}
+
+	if err := c.ShouldBindQuery(&queryParams); err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing query parameters: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	feeds, err := s.Repo.LeaseDueFeeds(queryParams.Limit, s.LeaseTimeout)
+	if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, gin.H{"feeds": feeds})
+}
+
+// validatePollInterval checks that a poll interval, in seconds, is within the bounds allowed.
+// Zero is always valid, as it stands for the default poll interval.
+func (s *Server) validatePollInterval(seconds int64) (msg string, ok bool) {
+	interval := time.Duration(seconds) * time.Second
+	if seconds == 0 || (interval >= s.MinPollInterval && interval <= s.MaxPollInterval) {
+		return "", true
+	}
+
+	return fmt.Sprintf("poll_interval must be between %d and %d seconds", int64(s.MinPollInterval.Seconds()),
+		int64(s.MaxPollInterval.Seconds())), false
+}
+
+// pollInterval returns the time between fetches of a feed.
+// Poll intervals set before the bounds allowed changed are brought within the new bounds.
+func (s *Server) pollInterval(feed entities.Feed) time.Duration {
+	if feed.PollInterval == 0 {
+		return s.DefaultPollInterval
+	}
+
+	interval := time.Duration(feed.PollInterval) * time.Second
+	if interval < s.MinPollInterval {
+		return s.MinPollInterval
+	} else if interval > s.MaxPollInterval {
+		return s.MaxPollInterval
+	}
+	return interval
+}
@@ -0,0 +1,127 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDueFeedsHandler(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	getDueFeeds := func(query string) (int, entities.Feeds) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/api/v1/feeds/due"+query, nil)
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			return w.Code, nil
+		}
+
+		body := struct {
+			Feeds entities.Feeds `json:"feeds"`
+		}{}
+		err = json.Unmarshal(w.Body.Bytes(), &body)
+		require.NoError(t, err)
+		return w.Code, body.Feeds
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?limit=abc"} {
+		code, _ := getDueFeeds(query)
+		assert.Equal(t, 400, code, query)
+	}
+
+	code, feeds := getDueFeeds("?limit=2")
+	require.Equal(t, 200, code)
+	assert.Len(t, feeds, 2)
+
+	// Leased feeds are not handed out again, and disabled feeds are never due
+	code, feeds = getDueFeeds("")
+	require.Equal(t, 200, code)
+	require.Len(t, feeds, 1)
+	feed := feeds[0]
+	assert.True(t, feed.Enabled)
+
+	code, feeds = getDueFeeds("")
+	require.Equal(t, 200, code)
+	assert.Empty(t, feeds)
+
+	// Reporting the fetch state schedules the next fetch one poll interval from now
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("PUT", "/api/v1/feeds/"+feed.ID+"/fetch-state", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+	require.Equal(t, 204, w.Code)
+
+	updated, err := repo.GetFeed(feed.ID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(server.DefaultPollInterval), updated.NextFetchAt, time.Minute)
+}
+
+func TestPollIntervalHandlers(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.MinPollInterval = time.Minute
+	server.MaxPollInterval = time.Hour
+	router := server.Router
+
+	feed, err := repo.GetFeedByURL("https://feeds.bbci.co.uk/news/uk/rss.xml")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		method             string
+		url                string
+		body               string
+		expectedStatusCode int
+	}{
+		"add too short": {method: "POST", url: "/api/v1/feeds",
+			body:               `{"url": "https://example.com/a.xml", "provider": "BBC News", "category": "UK", "poll_interval": 30}`,
+			expectedStatusCode: 400},
+		"add too long": {method: "POST", url: "/api/v1/feeds",
+			body:               `{"url": "https://example.com/b.xml", "provider": "BBC News", "category": "UK", "poll_interval": 7200}`,
+			expectedStatusCode: 400},
+		"add": {method: "POST", url: "/api/v1/feeds",
+			body:               `{"url": "https://example.com/c.xml", "provider": "BBC News", "category": "UK", "poll_interval": 600}`,
+			expectedStatusCode: 201},
+		"add default": {method: "POST", url: "/api/v1/feeds",
+			body:               `{"url": "https://example.com/d.xml", "provider": "BBC News", "category": "UK", "poll_interval": 0}`,
+			expectedStatusCode: 201},
+		"update too short": {method: "PATCH", url: "/api/v1/feeds/" + feed.ID, body: `{"poll_interval": -1}`,
+			expectedStatusCode: 400},
+		"update": {method: "PATCH", url: "/api/v1/feeds/" + feed.ID, body: `{"poll_interval": 1800}`,
+			expectedStatusCode: 200},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+
+	added, err := repo.GetFeedByURL("https://example.com/c.xml")
+	require.NoError(t, err)
+	assert.EqualValues(t, 600, added.PollInterval)
+
+	updated, err := repo.GetFeed(feed.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1800, updated.PollInterval)
+}
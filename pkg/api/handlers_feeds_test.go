@@ -7,18 +7,30 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/mocks"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository/memory"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/urlpolicy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+// Feed IDs the mock repository treats specially.
+const (
+	newFeedID       = "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f05"
+	unknownFeedID   = "00000000-0000-0000-0000-000000000000"
+	errorCondFeedID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+)
+
 func TestGetFeedsHandler(t *testing.T) {
 	type ErrorResponseBody struct {
 		Message string `json:"message"`
@@ -56,30 +68,45 @@ func TestGetFeedsHandler(t *testing.T) {
 			Category:           "",
 			Enabled:            &trueV,
 			expectedStatusCode: 200,
-			expectedResponseBody: entities.Feeds{
-				entities.Feed{
-					URL:      "http://feeds.bbci.co.uk/news/technology/rss.xml",
-					Provider: "BBC News",
-					Category: "Technology"},
-				entities.Feed{
-					URL:      "http://feeds.bbci.co.uk/news/uk/rss.xml",
-					Provider: "BBC News",
-					Category: "UK"},
-				entities.Feed{
-					URL:      "http://feeds.skynews.com/feeds/rss/technology.xml",
-					Provider: "Sky News",
-					Category: "Technology"}}},
+			expectedResponseBody: entities.FeedsPage{
+				Feeds: entities.Feeds{
+					entities.Feed{
+						ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f01",
+						URL:          "http://feeds.bbci.co.uk/news/technology/rss.xml",
+						CanonicalURL: "https://feeds.bbci.co.uk/news/technology/rss.xml",
+						Provider:     "BBC News",
+						Category:     "Technology",
+						Enabled:      true},
+					entities.Feed{
+						ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f02",
+						URL:          "http://feeds.bbci.co.uk/news/uk/rss.xml",
+						CanonicalURL: "https://feeds.bbci.co.uk/news/uk/rss.xml",
+						Provider:     "BBC News",
+						Category:     "UK",
+						Enabled:      true},
+					entities.Feed{
+						ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f03",
+						URL:          "http://feeds.skynews.com/feeds/rss/technology.xml",
+						CanonicalURL: "https://feeds.skynews.com/feeds/rss/technology.xml",
+						Provider:     "Sky News",
+						Category:     "Technology",
+						Enabled:      true}},
+				Total: 3}},
 		"test 3": {
 			Provider:           "Sky News",
 			Category:           "",
 			Enabled:            &falseV,
 			expectedStatusCode: 200,
-			expectedResponseBody: entities.Feeds{
-				entities.Feed{
-					URL:      "http://feeds.skynews.com/feeds/rss/uk.xml",
-					Provider: "Sky News",
-					Category: "UK",
-					Enabled:  false}}},
+			expectedResponseBody: entities.FeedsPage{
+				Feeds: entities.Feeds{
+					entities.Feed{
+						ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f04",
+						URL:          "http://feeds.skynews.com/feeds/rss/uk.xml",
+						CanonicalURL: "https://feeds.skynews.com/feeds/rss/uk.xml",
+						Provider:     "Sky News",
+						Category:     "UK",
+						Enabled:      false}},
+				Total: 1}},
 	}
 
 	for name, test := range tests {
@@ -96,7 +123,7 @@ func TestGetFeedsHandler(t *testing.T) {
 
 			switch w.Code {
 			case 200:
-				responseBody := entities.Feeds{}
+				responseBody := entities.FeedsPage{}
 				err = json.Unmarshal(w.Body.Bytes(), &responseBody)
 				require.NoError(t, err)
 				assert.Equal(test.expectedResponseBody, responseBody)
@@ -110,6 +137,114 @@ func TestGetFeedsHandler(t *testing.T) {
 	}
 }
 
+func TestGetFeedsEnabledFilterHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	baseURL := "/api/v1/feeds"
+
+	tests := map[string]struct {
+		query              string
+		expectedStatusCode int
+		expectedEnabled    int
+		expectedDisabled   int
+	}{
+		"default":       {query: "", expectedStatusCode: 200, expectedEnabled: 3},
+		"enabled true":  {query: "?enabled=true", expectedStatusCode: 200, expectedEnabled: 3},
+		"enabled false": {query: "?enabled=false", expectedStatusCode: 200, expectedDisabled: 1},
+		"enabled all":   {query: "?enabled=all", expectedStatusCode: 200, expectedEnabled: 3, expectedDisabled: 1},
+		"invalid":       {query: "?enabled=maybe", expectedStatusCode: 400},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", baseURL+test.query, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatusCode, w.Code)
+			if w.Code != 200 {
+				return
+			}
+
+			responseBody := entities.FeedsPage{}
+			err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+			require.NoError(t, err)
+
+			enabled, disabled := 0, 0
+			for _, feed := range responseBody.Feeds {
+				if feed.Enabled {
+					enabled++
+				} else {
+					disabled++
+				}
+			}
+			assert.Equal(test.expectedEnabled, enabled)
+			assert.Equal(test.expectedDisabled, disabled)
+		})
+	}
+}
+
+func TestGetFeedsPaginationHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	baseURL := "/api/v1/feeds"
+
+	t.Run("invalid query parameters", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=1001", "limit=abc", "sort=unknown", "cursor=invalid"} {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", baseURL+"?"+query, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(400, w.Code, query)
+		}
+	})
+
+	t.Run("walk through pages", func(t *testing.T) {
+		urls := []string{}
+		cursor := ""
+
+		for {
+			w := httptest.NewRecorder()
+			rawURL := baseURL + "?limit=2&sort=-url&cursor=" + url.QueryEscape(cursor)
+			req, err := http.NewRequest("GET", rawURL, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+			require.Equal(t, 200, w.Code)
+
+			responseBody := entities.FeedsPage{}
+			err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+			require.NoError(t, err)
+			assert.EqualValues(3, responseBody.Total)
+
+			for _, feed := range responseBody.Feeds {
+				urls = append(urls, feed.URL)
+			}
+
+			if responseBody.NextCursor == "" {
+				break
+			}
+			cursor = responseBody.NextCursor
+		}
+
+		assert.Equal([]string{
+			"http://feeds.skynews.com/feeds/rss/technology.xml",
+			"http://feeds.bbci.co.uk/news/uk/rss.xml",
+			"http://feeds.bbci.co.uk/news/technology/rss.xml"}, urls)
+	})
+}
+
 func TestAddFeedHandler(t *testing.T) {
 	assert := assert.New(t)
 
@@ -155,7 +290,7 @@ func TestAddFeedHandler(t *testing.T) {
 			URL:                "http://example.com",
 			Provider:           "provider1",
 			Category:           "category1",
-			expectedStatusCode: 204,
+			expectedStatusCode: 201,
 		},
 	}
 
@@ -170,11 +305,846 @@ func TestAddFeedHandler(t *testing.T) {
 			router.ServeHTTP(w, req)
 
 			assert.Equal(test.expectedStatusCode, w.Code)
+
+			if w.Code == 201 {
+				responseBody := entities.Feed{}
+				err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+				require.NoError(t, err)
+				assert.Equal(newFeedID, responseBody.ID)
+				assert.Equal(test.URL, responseBody.URL)
+				assert.True(responseBody.Enabled)
+			}
+		})
+	}
+}
+
+func TestFeedCanonicalURLHandler(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := memory.NewRepository()
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	send := func(method string, url string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/v1/feeds",
+		`{"url": "http://Example.com:80/rss.xml?b=2&a=1", "provider": "Example", "category": "UK"}`)
+	require.Equal(t, 201, w.Code)
+	created := entities.Feed{}
+	err := json.Unmarshal(w.Body.Bytes(), &created)
+	require.NoError(t, err)
+	// The original URL is kept for display
+	assert.Equal(t, "http://Example.com:80/rss.xml?b=2&a=1", created.URL)
+	assert.Equal(t, "https://example.com/rss.xml?a=1&b=2", created.CanonicalURL)
+
+	for _, url := range []string{
+		"https://example.com/rss.xml?a=1&b=2",
+		"http://example.com/rss.xml/?a=1&b=2&utm_source=twitter",
+		"HTTP://EXAMPLE.COM/rss.xml?b=2&a=1#latest",
+	} {
+		w = send("POST", "/api/v1/feeds", `{"url": "`+url+`", "provider": "Example", "category": "UK"}`)
+		assert.Equal(t, 409, w.Code, url)
+	}
+
+	w = send("POST", "/api/v1/feeds:batch", `[{"url": "https://example.com/rss.xml?a=1&b=2&fbclid=x", `+
+		`"provider": "Example", "category": "UK"}, {"url": "http://example.com/other.xml", "provider": "Example", `+
+		`"category": "UK"}, {"url": "https://example.com/other.xml/", "provider": "Example", "category": "UK"}]`)
+	require.Equal(t, 200, w.Code)
+	report := entities.FeedBatchReport{}
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 3)
+	assert.Equal(t, entities.FeedBatchDuplicate, report.Results[0].Status)
+	assert.Equal(t, entities.FeedBatchCreated, report.Results[1].Status)
+	assert.Equal(t, entities.FeedBatchDuplicate, report.Results[2].Status)
+
+	w = send("PATCH", "/api/v1/feeds/"+created.ID, `{"url": "https://example.com/other.xml"}`)
+	assert.Equal(t, 409, w.Code)
+
+	// With the scheme taken into account, http and https URLs are different feeds
+	server.IgnoreURLScheme = false
+	w = send("POST", "/api/v1/feeds", `{"url": "http://example.com/rss.xml?a=1&b=2", "provider": "Example", `+
+		`"category": "UK"}`)
+	assert.Equal(t, 201, w.Code)
+}
+
+func TestURLPolicyHandlers(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.URLPolicy = urlpolicy.NewPolicy()
+	server.URLPolicy.DeniedHosts = []string{"internal.example.com"}
+	router := server.Router
+
+	send := func(method string, url string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// IP addresses are used so that no host names need resolving
+	for _, url := range []string{
+		"http://127.0.0.1/rss.xml",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/rss.xml",
+		"http://93.184.216.34:8080/rss.xml",
+		"https://feeds.internal.example.com/rss.xml",
+	} {
+		w := send("POST", "/api/v1/feeds", `{"url": "`+url+`", "provider": "Example", "category": "UK"}`)
+		assert.Equal(t, 400, w.Code, url)
+		assert.Contains(t, w.Body.String(), "url not allowed", url)
+	}
+
+	w := send("POST", "/api/v1/feeds", `{"url": "http://93.184.216.34/rss.xml", "provider": "Example", `+
+		`"category": "UK"}`)
+	require.Equal(t, 201, w.Code)
+
+	w = send("POST", "/api/v1/feeds:batch", `[{"url": "http://10.0.0.1/rss.xml", "provider": "Example", `+
+		`"category": "UK"}, {"url": "https://93.184.216.34/other.xml", "provider": "Example", "category": "UK"}]`)
+	require.Equal(t, 200, w.Code)
+	report := entities.FeedBatchReport{}
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, entities.FeedBatchInvalid, report.Results[0].Status)
+	assert.Contains(t, report.Results[0].Error, "url not allowed")
+	assert.Equal(t, entities.FeedBatchCreated, report.Results[1].Status)
+
+	// Feeds are checked concurrently, but reported in order
+	var urls, items []string
+	for i := 0; i < 20; i++ {
+		host := "93.184.216.34"
+		if i%2 == 0 {
+			host = "10.0.0.1"
+		}
+		urls = append(urls, "http://"+host+"/batch/"+strconv.Itoa(i)+".xml")
+		items = append(items, `{"url": "`+urls[i]+`", "provider": "Example", "category": "UK"}`)
+	}
+	w = send("POST", "/api/v1/feeds:batch", "["+strings.Join(items, ",")+"]")
+	require.Equal(t, 200, w.Code)
+	report = entities.FeedBatchReport{}
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 20)
+	for i, result := range report.Results {
+		assert.Equal(t, urls[i], result.Feed.URL)
+		if i%2 == 0 {
+			assert.Equal(t, entities.FeedBatchInvalid, result.Status)
+		} else {
+			assert.Equal(t, entities.FeedBatchCreated, result.Status)
+		}
+	}
+
+	w = send("POST", "/api/v1/feeds/discover", `{"url": "http://192.168.1.1/"}`)
+	assert.Equal(t, 400, w.Code)
+
+	feed, err := repo.GetFeedByURL("https://feeds.bbci.co.uk/news/technology/rss.xml")
+	require.NoError(t, err)
+	w = send("PATCH", "/api/v1/feeds/"+feed.ID, `{"url": "http://127.0.0.1/rss.xml"}`)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "url not allowed")
+
+	feed, err = repo.GetFeed(feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "http://feeds.bbci.co.uk/news/technology/rss.xml", feed.URL)
+}
+
+func TestAddFeedHandlerValidation(t *testing.T) {
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss.xml":
+			w.Write([]byte(`<rss version="2.0"><channel><title>Example</title></channel></rss>`))
+		case "/index.html":
+			w.Write([]byte(`<!DOCTYPE html><html><body>Example</body></html>`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer feedServer.Close()
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.Fetcher = fetcher.NewFetcher(time.Second, 1<<20, nil)
+	server.ValidateFeeds = true
+	router := server.Router
+
+	// Tests run in order, as the duplicated feed is the one added by the first test
+	tests := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+	}{
+		{name: "feed", path: "/rss.xml", expectedStatusCode: 201},
+		{name: "html page", path: "/index.html", expectedStatusCode: 422},
+		{name: "not found", path: "/missing.xml", expectedStatusCode: 422},
+		{name: "duplicated", path: "/rss.xml", expectedStatusCode: 409},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			body := `{"url": "` + feedServer.URL + test.path + `", "provider": "Example", "category": "Blogs"}`
+			req, err := http.NewRequest("POST", "/api/v1/feeds", bytes.NewBufferString(body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestFeedMetadataHandlers(t *testing.T) {
+	feedDoc := `<rss version="2.0"><channel><title>Example</title><language>en-GB</language></channel></rss>`
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(410)
+			return
+		}
+		w.Write([]byte(feedDoc))
+	}))
+	defer feedServer.Close()
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	goneFeed, err := repo.AddFeed(entities.Feed{URL: feedServer.URL + "/gone", Provider: "Example", Category: "Blogs"}, entities.Actor{})
+	require.NoError(t, err)
+
+	// Metadata is taken from the feed document on creation
+	w := httptest.NewRecorder()
+	body := `{"url": "` + feedServer.URL + `", "provider": "Example", "category": "Blogs", "fetch_metadata": true}`
+	req, err := http.NewRequest("POST", "/api/v1/feeds", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, 201, w.Code)
+	feed := entities.Feed{}
+	err = json.Unmarshal(w.Body.Bytes(), &feed)
+	require.NoError(t, err)
+	assert.Equal(t, entities.FeedMetadata{Title: "Example", Language: "en-gb"}, feed.FeedMetadata)
+
+	// And listed by language
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/api/v1/feeds?language=en", nil)
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, 200, w.Code)
+	page := entities.FeedsPage{}
+	err = json.Unmarshal(w.Body.Bytes(), &page)
+	require.NoError(t, err)
+	require.Len(t, page.Feeds, 1)
+	assert.Equal(t, feed.ID, page.Feeds[0].ID)
+
+	// Refreshing replaces the metadata with the one in the document
+	feedDoc = `<feed xmlns="http://www.w3.org/2005/Atom"><title>Example Atom</title></feed>`
+
+	tests := map[string]struct {
+		path               string
+		expectedStatusCode int
+	}{
+		"invalid id":     {path: "abc:refresh", expectedStatusCode: 400},
+		"unknown id":     {path: unknownFeedID + ":refresh", expectedStatusCode: 404},
+		"unknown action": {path: feed.ID + ":unknown", expectedStatusCode: 404},
+		"no action":      {path: feed.ID, expectedStatusCode: 404},
+		"unreachable":    {path: goneFeed.ID + ":refresh", expectedStatusCode: 422},
+		"refresh":        {path: feed.ID + ":refresh", expectedStatusCode: 200},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", "/api/v1/feeds/"+test.path, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatusCode, w.Code)
+			if w.Code != 200 {
+				return
+			}
+
+			refreshed := entities.Feed{}
+			err = json.Unmarshal(w.Body.Bytes(), &refreshed)
+			require.NoError(t, err)
+			assert.Equal(t, entities.FeedMetadata{Title: "Example Atom"}, refreshed.FeedMetadata)
+		})
+	}
+}
+
+func TestAddFeedsBatchHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	baseURL := "/api/v1/feeds:batch"
+
+	countFeeds := func() int64 {
+		page, err := repo.GetFeeds(entities.FeedQuery{})
+		require.NoError(t, err)
+		return page.Total
+	}
+
+	// Order matters, as created feeds are not removed between tests
+	tests := []struct {
+		name               string
+		Query              string
+		Body               string
+		expectedStatusCode int
+		expectedStatuses   []string
+		expectedCreated    int64
+	}{
+		{
+			name:               "not an array",
+			Body:               `{"url": "http://example.com/rss.xml", "provider": "Example", "category": "UK"}`,
+			expectedStatusCode: 400,
+		},
+		{
+			name:               "empty",
+			Body:               `[]`,
+			expectedStatusCode: 400,
+		},
+		{
+			name:  "atomic with invalid feed",
+			Query: "?atomic=true",
+			Body: `[{"url": "http://example.com/1.xml", "provider": "Example", "category": "UK"},
+				{"url": "invalid_url", "provider": "Example", "category": "UK"}]`,
+			expectedStatusCode: 422,
+			expectedStatuses:   []string{entities.FeedBatchSkipped, entities.FeedBatchInvalid},
+		},
+		{
+			name:  "atomic with duplicate feed",
+			Query: "?atomic=true",
+			Body: `[{"url": "http://example.com/1.xml", "provider": "Example", "category": "UK"},
+				{"url": "http://feeds.bbci.co.uk/news/uk/rss.xml", "provider": "BBC News", "category": "UK"}]`,
+			expectedStatusCode: 422,
+			expectedStatuses:   []string{entities.FeedBatchSkipped, entities.FeedBatchDuplicate},
+		},
+		{
+			name:  "atomic",
+			Query: "?atomic=true",
+			Body: `[{"url": "http://example.com/1.xml", "provider": "Example", "category": "UK"},
+				{"url": "http://example.com/2.xml", "provider": "Example", "category": "UK"}]`,
+			expectedStatusCode: 200,
+			expectedStatuses:   []string{entities.FeedBatchCreated, entities.FeedBatchCreated},
+			expectedCreated:    2,
+		},
+		{
+			name: "independent",
+			Body: `[{"url": "http://example.com/3.xml", "provider": "Example", "category": "UK"},
+				{"url": "http://feeds.bbci.co.uk/news/uk/rss.xml", "provider": "BBC News", "category": "UK"},
+				{"url": "invalid_url", "provider": "Example", "category": "UK"},
+				{"url": "http://example.com/4.xml", "category": "UK"},
+				{"url": "http://example.com/3.xml", "provider": "Example", "category": "UK"}]`,
+			expectedStatusCode: 200,
+			expectedStatuses: []string{
+				entities.FeedBatchCreated,
+				entities.FeedBatchDuplicate,
+				entities.FeedBatchInvalid,
+				entities.FeedBatchInvalid,
+				entities.FeedBatchDuplicate},
+			expectedCreated: 1,
+		},
+		{
+			name: "poll interval out of bounds",
+			Body: `[{"url": "http://example.com/5.xml", "provider": "Example", "category": "UK", "poll_interval": 1},
+				{"url": "http://example.com/6.xml", "provider": "Example", "category": "UK", "poll_interval": 3600}]`,
+			expectedStatusCode: 200,
+			expectedStatuses:   []string{entities.FeedBatchInvalid, entities.FeedBatchCreated},
+			expectedCreated:    1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := countFeeds()
+
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", baseURL+test.Query, bytes.NewBufferString(test.Body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatusCode, w.Code)
+			assert.Equal(before+test.expectedCreated, countFeeds())
+
+			if test.expectedStatuses == nil {
+				return
+			}
+
+			report := entities.FeedBatchReport{}
+			err = json.Unmarshal(w.Body.Bytes(), &report)
+			require.NoError(t, err)
+			assert.EqualValues(test.expectedCreated, report.Created)
+			assert.Equal(len(test.expectedStatuses)-int(test.expectedCreated), report.Failed)
+
+			statuses := []string{}
+			for _, result := range report.Results {
+				statuses = append(statuses, result.Status)
+				if result.Status == entities.FeedBatchCreated {
+					assert.NotEmpty(result.Feed.ID)
+				} else {
+					assert.NotEmpty(result.Error)
+				}
+			}
+			assert.Equal(test.expectedStatuses, statuses)
+		})
+	}
+
+	t.Run("unknown action", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("POST", "/api/v1/feeds:unknown", bytes.NewBufferString(`[]`))
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(404, w.Code)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		router := api.NewServer("", 9999, false, logger, setupMockDB()).Router
+
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("POST", baseURL, bytes.NewBufferString(`[{"url": "http://errorCond.com", "provider": "errorCond", "category": "errorCond"}]`))
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(500, w.Code)
+	})
+}
+
+func TestAddFeedsBatchHandlerValidation(t *testing.T) {
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss.xml":
+			w.Write([]byte(`<rss version="2.0"><channel><title>Example</title></channel></rss>`))
+		case "/index.html":
+			w.Write([]byte(`<!DOCTYPE html><html><body>Example</body></html>`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer feedServer.Close()
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	server.Fetcher = fetcher.NewFetcher(time.Second, 1<<20, nil)
+	server.ValidateFeeds = true
+	router := server.Router
+
+	body := `[{"url": "` + feedServer.URL + `/rss.xml", "provider": "Example", "category": "Blogs"},
+		{"url": "` + feedServer.URL + `/index.html", "provider": "Example", "category": "Blogs"},
+		{"url": "` + feedServer.URL + `/missing.xml", "provider": "Example", "category": "Blogs"}]`
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/api/v1/feeds:batch", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, 200, w.Code)
+	report := entities.FeedBatchReport{}
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 3)
+
+	assert.Equal(t, entities.FeedBatchCreated, report.Results[0].Status)
+	assert.Equal(t, "Example", report.Results[0].Feed.Title)
+	for _, result := range report.Results[1:] {
+		assert.Equal(t, entities.FeedBatchInvalid, result.Status)
+		assert.Contains(t, result.Error, "URL provided is not a valid feed")
+	}
+}
+
+func TestAddFeedsBatchHandlerDeadline(t *testing.T) {
+	// Slow feeds only respond once the request deadline is gone
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow.xml":
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+			w.Write([]byte(`<rss version="2.0"><channel><title>Slow</title></channel></rss>`))
+		case "/index.html":
+			w.Write([]byte(`<!DOCTYPE html><html><body>Example</body></html>`))
+		default:
+			w.Write([]byte(`<rss version="2.0"><channel><title>Example</title></channel></rss>`))
+		}
+	}))
+	defer feedServer.Close()
+
+	feedItem := func(path string) string {
+		return `{"url": "` + feedServer.URL + path + `", "provider": "Example", "category": "Blogs"}`
+	}
+
+	tests := map[string]struct {
+		query              string
+		paths              []string
+		fetchTimeout       time.Duration
+		expectedStatusCode int
+		expectedStatuses   []string
+		expectedTimedOut   int
+	}{
+		"request deadline": {
+			paths:              []string{"/1.xml", "/slow.xml"},
+			fetchTimeout:       time.Second,
+			expectedStatusCode: 200,
+			expectedStatuses:   []string{entities.FeedBatchCreated, entities.FeedBatchTimedOut},
+			expectedTimedOut:   1,
+		},
+		"fetch timeout": {
+			paths:              []string{"/2.xml", "/slow.xml"},
+			fetchTimeout:       100 * time.Millisecond,
+			expectedStatusCode: 200,
+			expectedStatuses:   []string{entities.FeedBatchCreated, entities.FeedBatchInvalid},
+		},
+		"atomic with timed out feed": {
+			query:              "?atomic=true",
+			paths:              []string{"/3.xml", "/slow.xml"},
+			fetchTimeout:       time.Second,
+			expectedStatusCode: 504,
+			expectedStatuses:   []string{entities.FeedBatchSkipped, entities.FeedBatchTimedOut},
+			expectedTimedOut:   1,
+		},
+		"atomic with timed out and invalid feeds": {
+			query:              "?atomic=true",
+			paths:              []string{"/4.xml", "/slow.xml", "/index.html"},
+			fetchTimeout:       time.Second,
+			expectedStatusCode: 422,
+			expectedStatuses: []string{
+				entities.FeedBatchSkipped,
+				entities.FeedBatchTimedOut,
+				entities.FeedBatchInvalid},
+			expectedTimedOut: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			logger := log.NullLogger{}
+			server := api.NewServer("", 9999, false, logger, setupMemoryRepo(t))
+			server.Fetcher = fetcher.NewFetcher(test.fetchTimeout, 1<<20, nil)
+			server.ValidateFeeds = true
+			server.FetchDeadline = 500 * time.Millisecond
+			router := server.Router
+
+			items := []string{}
+			for _, path := range test.paths {
+				items = append(items, feedItem(path))
+			}
+
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", "/api/v1/feeds:batch"+test.query,
+				bytes.NewBufferString("["+strings.Join(items, ",")+"]"))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatusCode, w.Code)
+			report := entities.FeedBatchReport{}
+			err = json.Unmarshal(w.Body.Bytes(), &report)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedTimedOut, report.TimedOut)
+
+			statuses := []string{}
+			for _, result := range report.Results {
+				statuses = append(statuses, result.Status)
+			}
+			assert.Equal(t, test.expectedStatuses, statuses)
+		})
+	}
+}
+
+func TestGetFeedHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	mockDB := setupMockDB()
+	server := api.NewServer("", 9999, false, logger, mockDB)
+	router := server.Router
+
+	baseURL := "/api/v1/feeds/"
+
+	tests := map[string]struct {
+		Ref                string
+		expectedStatusCode int
+	}{
+		"invalid id": {
+			Ref:                "invalid_id",
+			expectedStatusCode: 400,
+		},
+		"not found": {
+			Ref:                unknownFeedID,
+			expectedStatusCode: 404,
+		},
+		"error": {
+			Ref:                errorCondFeedID,
+			expectedStatusCode: 500,
+		},
+		"found": {
+			Ref:                GenData()[1].ID,
+			expectedStatusCode: 200,
+		},
+		"url not found": {
+			Ref:                url.QueryEscape("http://url.does.not.exist.com/rss?page=1"),
+			expectedStatusCode: 404,
+		},
+		"url error": {
+			Ref:                url.QueryEscape("http://errorCond.com"),
+			expectedStatusCode: 500,
+		},
+		"url found": {
+			Ref:                url.QueryEscape(GenData()[1].URL),
+			expectedStatusCode: 200,
+		},
+		"url variant found": {
+			Ref:                url.QueryEscape("https://FEEDS.bbci.co.uk:443/news/uk/rss.xml/?utm_source=twitter"),
+			expectedStatusCode: 200,
+		},
+		"url variant with semicolon not found": {
+			Ref:                url.QueryEscape("http://feeds.bbci.co.uk/news/uk/rss.xml?page=1;size=10"),
+			expectedStatusCode: 404,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			req, err := http.NewRequest("GET", baseURL+test.Ref, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatusCode, w.Code)
+
+			if w.Code == 200 {
+				responseBody := entities.Feed{}
+				err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+				require.NoError(t, err)
+				assert.Equal(GenData()[1], responseBody)
+			}
+		})
+	}
+}
+
+func TestGetFeedHealthHandler(t *testing.T) {
+	logger := log.NullLogger{}
+	mockDB := setupMockDB()
+	server := api.NewServer("", 9999, false, logger, mockDB)
+	router := server.Router
+
+	tests := map[string]struct {
+		ID                 string
+		expectedStatusCode int
+	}{
+		"invalid id": {ID: "invalid_id", expectedStatusCode: 400},
+		"not found":  {ID: unknownFeedID, expectedStatusCode: 404},
+		"error":      {ID: errorCondFeedID, expectedStatusCode: 500},
+		"found":      {ID: GenData()[1].ID, expectedStatusCode: 200},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/api/v1/feeds/"+test.ID+"/health", nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatusCode, w.Code)
+			if w.Code != 200 {
+				return
+			}
+
+			health := entities.FeedHealth{}
+			err = json.Unmarshal(w.Body.Bytes(), &health)
+			require.NoError(t, err)
+			assert.Equal(t, test.ID, health.FeedID)
+			assert.Equal(t, 404, health.LastStatusCode)
+			assert.Equal(t, 3, health.ConsecutiveFailures)
+			assert.Nil(t, health.LastSuccessAt)
+		})
+	}
+}
+
+func TestUpdateFeedHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	mockDB := setupMockDB()
+	server := api.NewServer("", 9999, false, logger, mockDB)
+	router := server.Router
+
+	baseURL := "/api/v1/feeds/"
+	data := GenData()
+
+	tests := map[string]struct {
+		ID                   string
+		Body                 string
+		expectedStatusCode   int
+		expectedResponseBody entities.Feed
+	}{
+		"empty body": {
+			ID:                 data[0].ID,
+			Body:               `{}`,
+			expectedStatusCode: 400,
+		},
+		"invalid id": {
+			ID:                 "invalid_id",
+			Body:               `{"enabled": false}`,
+			expectedStatusCode: 400,
+		},
+		"invalid url": {
+			ID:                 data[0].ID,
+			Body:               `{"url": "invalid_url"}`,
+			expectedStatusCode: 400,
+		},
+		"empty provider": {
+			ID:                 data[0].ID,
+			Body:               `{"provider": ""}`,
+			expectedStatusCode: 400,
+		},
+		"not found": {
+			ID:                 unknownFeedID,
+			Body:               `{"enabled": false}`,
+			expectedStatusCode: 404,
+		},
+		"error": {
+			ID:                 errorCondFeedID,
+			Body:               `{"enabled": false}`,
+			expectedStatusCode: 500,
+		},
+		"duplicate url": {
+			ID:                 data[0].ID,
+			Body:               `{"url": "http://feeds.bbci.co.uk/news/uk/rss.xml"}`,
+			expectedStatusCode: 409,
+		},
+		"enabled": {
+			ID:                 data[0].ID,
+			Body:               `{"enabled": false}`,
+			expectedStatusCode: 200,
+			expectedResponseBody: entities.Feed{
+				ID:           data[0].ID,
+				URL:          data[0].URL,
+				CanonicalURL: data[0].CanonicalURL,
+				Provider:     data[0].Provider,
+				Category:     data[0].Category,
+				Enabled:      false},
+		},
+		"url, provider and category": {
+			ID:                 data[3].ID,
+			Body:               `{"url": "https://feeds.skynews.com/feeds/rss/uk.xml", "provider": "Sky", "category": "World"}`,
+			expectedStatusCode: 200,
+			expectedResponseBody: entities.Feed{
+				ID:           data[3].ID,
+				URL:          "https://feeds.skynews.com/feeds/rss/uk.xml",
+				CanonicalURL: "https://feeds.skynews.com/feeds/rss/uk.xml",
+				Provider:     "Sky",
+				Category:     "World",
+				Enabled:      false},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			req, err := http.NewRequest("PATCH", baseURL+test.ID, bytes.NewBufferString(test.Body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatusCode, w.Code)
+
+			if w.Code == 200 {
+				responseBody := entities.Feed{}
+				err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+				require.NoError(t, err)
+				assert.Equal(test.expectedResponseBody, responseBody)
+			}
 		})
 	}
 }
 
-func TestSetFeedStateHandler(t *testing.T) {
+func TestSetFeedFetchStateHandler(t *testing.T) {
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	feed, err := repo.GetFeedByURL("https://feeds.bbci.co.uk/news/uk/rss.xml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		ID                 string
+		Body               string
+		expectedStatusCode int
+		expectedState      entities.FeedFetchState
+	}{
+		{name: "invalid id", ID: "invalid_id", Body: `{}`, expectedStatusCode: 400},
+		{name: "unknown id", ID: unknownFeedID, Body: `{}`, expectedStatusCode: 404},
+		{name: "bad json", ID: feed.ID, Body: `{"etag": 1}`, expectedStatusCode: 400},
+		{name: "etag too long", ID: feed.ID, Body: `{"etag": "` + strings.Repeat("a", 251) + `"}`,
+			expectedStatusCode: 400},
+		{name: "negative update frequency", ID: feed.ID, Body: `{"update_frequency": -60}`,
+			expectedStatusCode: 400},
+		{
+			name: "report",
+			ID:   feed.ID,
+			Body: `{"etag": "\"5e8f-1a2b\"", "last_modified": "Sat, 01 May 2021 10:00:00 GMT", ` +
+				`"content_hash": "sha256:9f86d081", "update_frequency": 1800}`,
+			expectedStatusCode: 204,
+			expectedState: entities.FeedFetchState{ETag: `"5e8f-1a2b"`, LastModified: "Sat, 01 May 2021 10:00:00 GMT",
+				ContentHash: "sha256:9f86d081", UpdateFrequency: 1800},
+		},
+		{
+			name:               "fields left out are cleared",
+			ID:                 feed.ID,
+			Body:               `{"etag": "\"5e90-0c3d\""}`,
+			expectedStatusCode: 204,
+			expectedState:      entities.FeedFetchState{ETag: `"5e90-0c3d"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("PUT", "/api/v1/feeds/"+test.ID+"/fetch-state", strings.NewReader(test.Body))
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, test.expectedStatusCode, w.Code)
+			if w.Code != 204 {
+				return
+			}
+
+			// The fetch state is listed along with the feed
+			w = httptest.NewRecorder()
+			req, err = http.NewRequest("GET", "/api/v1/feeds?provider=BBC+News&category=UK", nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, 200, w.Code)
+			page := entities.FeedsPage{}
+			err = json.Unmarshal(w.Body.Bytes(), &page)
+			require.NoError(t, err)
+			require.Len(t, page.Feeds, 1)
+			assert.Equal(t, test.expectedState, page.Feeds[0].FetchState)
+		})
+	}
+}
+
+func TestSetFeedStateByURLHandler(t *testing.T) {
 	assert := assert.New(t)
 
 	falseV := false
@@ -216,6 +1186,11 @@ func TestSetFeedStateHandler(t *testing.T) {
 			Enabled:            &falseV,
 			expectedStatusCode: 204,
 		},
+		"test 6": {
+			URL:                "https://feeds.bbci.co.uk/news/technology/rss.xml?utm_medium=rss",
+			Enabled:            &falseV,
+			expectedStatusCode: 204,
+		},
 	}
 
 	for name, test := range tests {
@@ -231,6 +1206,11 @@ func TestSetFeedStateHandler(t *testing.T) {
 			router.ServeHTTP(w, req)
 
 			assert.Equal(test.expectedStatusCode, w.Code)
+
+			if w.Code == 204 {
+				assert.Equal("true", w.Header().Get("Deprecation"))
+				assert.Contains(w.Header().Get("Link"), "/api/v1/feeds/"+GenData()[0].ID)
+			}
 		})
 	}
 }
@@ -265,6 +1245,18 @@ func TestDeleteFeedHandler(t *testing.T) {
 			URL:                "http://feeds.bbci.co.uk/news/technology/rss.xml",
 			expectedStatusCode: 204,
 		},
+		"id not found": {
+			URL:                unknownFeedID,
+			expectedStatusCode: 404,
+		},
+		"id error": {
+			URL:                errorCondFeedID,
+			expectedStatusCode: 500,
+		},
+		"id": {
+			URL:                GenData()[2].ID,
+			expectedStatusCode: 204,
+		},
 	}
 
 	for name, test := range tests {
@@ -282,6 +1274,86 @@ func TestDeleteFeedHandler(t *testing.T) {
 	}
 }
 
+func TestRestoreFeedHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	logger := log.NullLogger{}
+	repo := setupMemoryRepo(t)
+	server := api.NewServer("", 9999, false, logger, repo)
+	router := server.Router
+
+	feed, err := repo.GetFeedByURL("https://feeds.bbci.co.uk/news/uk/rss.xml")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest("DELETE", "/api/v1/feeds/"+feed.ID, nil)
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+	require.Equal(t, 204, w.Code)
+
+	// Deleted feeds are only listed when asked for
+	listTests := map[string]struct {
+		query              string
+		expectedStatusCode int
+		expectedFeeds      int
+	}{
+		"default":               {query: "", expectedStatusCode: 200, expectedFeeds: 2},
+		"include deleted":       {query: "?include_deleted=true", expectedStatusCode: 200, expectedFeeds: 3},
+		"include deleted false": {query: "?include_deleted=false", expectedStatusCode: 200, expectedFeeds: 2},
+		"invalid":               {query: "?include_deleted=maybe", expectedStatusCode: 400},
+	}
+
+	for name, test := range listTests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", "/api/v1/feeds"+test.query, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatusCode, w.Code)
+			if w.Code != 200 {
+				return
+			}
+
+			responseBody := entities.FeedsPage{}
+			err = json.Unmarshal(w.Body.Bytes(), &responseBody)
+			require.NoError(t, err)
+			assert.Len(responseBody.Feeds, test.expectedFeeds)
+		})
+	}
+
+	w = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/api/v1/feeds/"+feed.ID, nil)
+	require.NoError(t, err)
+	router.ServeHTTP(w, req)
+	assert.Equal(404, w.Code)
+
+	tests := map[string]struct {
+		path               string
+		expectedStatusCode int
+	}{
+		"invalid id": {path: "abc:restore", expectedStatusCode: 400},
+		"unknown id": {path: unknownFeedID + ":restore", expectedStatusCode: 404},
+		"restore":    {path: feed.ID + ":restore", expectedStatusCode: 200},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("POST", "/api/v1/feeds/"+test.path, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatusCode, w.Code)
+		})
+	}
+
+	restored, err := repo.GetFeed(feed.ID)
+	require.NoError(t, err)
+	assert.Nil(restored.DeletedAt)
+	assert.Equal("BBC News", restored.Provider)
+}
+
 func BuildQueryParams(rawURL string, provider string, category string, enabled *bool) string {
 	v := url.Values{}
 
@@ -306,129 +1378,215 @@ func BuildQueryParams(rawURL string, provider string, category string, enabled *
 func GenData() entities.Feeds {
 	data := entities.Feeds{
 		entities.Feed{
-			URL:      "http://feeds.bbci.co.uk/news/technology/rss.xml",
-			Provider: "BBC News",
-			Category: "Technology",
-			Enabled:  true},
+			ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f01",
+			URL:          "http://feeds.bbci.co.uk/news/technology/rss.xml",
+			CanonicalURL: "https://feeds.bbci.co.uk/news/technology/rss.xml",
+			Provider:     "BBC News",
+			Category:     "Technology",
+			Enabled:      true},
 		entities.Feed{
-			URL:      "http://feeds.bbci.co.uk/news/uk/rss.xml",
-			Provider: "BBC News",
-			Category: "UK",
-			Enabled:  true},
+			ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f02",
+			URL:          "http://feeds.bbci.co.uk/news/uk/rss.xml",
+			CanonicalURL: "https://feeds.bbci.co.uk/news/uk/rss.xml",
+			Provider:     "BBC News",
+			Category:     "UK",
+			Enabled:      true},
 		entities.Feed{
-			URL:      "http://feeds.skynews.com/feeds/rss/technology.xml",
-			Provider: "Sky News",
-			Category: "Technology",
-			Enabled:  true},
+			ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f03",
+			URL:          "http://feeds.skynews.com/feeds/rss/technology.xml",
+			CanonicalURL: "https://feeds.skynews.com/feeds/rss/technology.xml",
+			Provider:     "Sky News",
+			Category:     "Technology",
+			Enabled:      true},
 		entities.Feed{
-			URL:      "http://feeds.skynews.com/feeds/rss/uk.xml",
-			Provider: "Sky News",
-			Category: "UK",
-			Enabled:  false},
+			ID:           "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f04",
+			URL:          "http://feeds.skynews.com/feeds/rss/uk.xml",
+			CanonicalURL: "https://feeds.skynews.com/feeds/rss/uk.xml",
+			Provider:     "Sky News",
+			Category:     "UK",
+			Enabled:      false},
 	}
 
 	return data
 }
 
+func setupMemoryRepo(t *testing.T) *memory.Repository {
+	repo := memory.NewRepository()
+
+	for _, feed := range GenData() {
+		_, err := repo.AddFeed(feed, entities.Actor{})
+		require.NoError(t, err)
+	}
+
+	return repo
+}
+
 func setupMockDB() *mocks.Repository {
 	mockDB := &mocks.Repository{}
 
 	data := GenData()
 
-	mockGetFeedsFn := func(provider string, category string, enabled bool) (feeds entities.Feeds) {
-		feeds = entities.Feeds{}
+	findFeed := func(match func(feed entities.Feed) bool) (entities.Feed, bool) {
+		for _, item := range data {
+			if match(item) {
+				return item, true
+			}
+		}
+		return entities.Feed{}, false
+	}
+
+	mockGetFeedsFn := func(query entities.FeedQuery) (page entities.FeedsPage) {
+		page.Feeds = entities.Feeds{}
 
 		for _, item := range data {
-			if provider != "" && provider != item.Provider {
+			if query.Provider != "" && query.Provider != item.Provider {
 				continue
 			}
 
-			if category != "" && category != item.Category {
+			if query.Category != "" && query.Category != item.Category {
 				continue
 			}
 
-			if enabled != item.Enabled {
+			if query.Enabled != nil && *query.Enabled != item.Enabled {
 				continue
 			}
 
-			feeds = append(feeds, item)
+			page.Feeds = append(page.Feeds, item)
 		}
 
-		return feeds
+		page.Total = int64(len(page.Feeds))
+		return page
 	}
 
-	mockAddFeedFn := func(feed entities.Feed) (err error) {
-		// Error condition
-		if feed.URL == "http://errorCond.com" && feed.Provider == "errorCond" && feed.Category == "errorCond" {
-			return &repository.DBServiceError{}
-		}
+	mockGetFeedFn := func(id string) entities.Feed {
+		feed, _ := findFeed(func(item entities.Feed) bool { return item.ID == id })
+		return feed
+	}
 
-		for _, item := range data {
-			if item.URL == feed.URL {
-				return &repository.DBDUPError{}
-			}
-		}
-		return nil
+	mockGetFeedByURLFn := func(canonicalURL string) entities.Feed {
+		feed, _ := findFeed(func(item entities.Feed) bool { return item.CanonicalURL == canonicalURL })
+		return feed
 	}
 
-	mockSetFeedStateFn := func(url string, enabled bool) (err error) {
-		// Error condition
-		if url == "http://errorCond.com" {
-			return &repository.DBServiceError{}
-		}
+	mockUpdateFeedFn := func(id string, update entities.FeedUpdate, actor entities.Actor) entities.Feed {
+		feed := mockGetFeedFn(id)
 
-		notFound := true
-		for _, item := range data {
-			if item.URL == url {
-				notFound = false
-			}
+		if update.URL != nil {
+			feed.URL = *update.URL
 		}
-
-		if notFound {
-			return &repository.DBNotFoundError{}
+		if update.Provider != nil {
+			feed.Provider = *update.Provider
 		}
-		return nil
-	}
-
-	mockDeleteFeedFn := func(url string) (err error) {
-		// Error condition
-		if url == "http://errorCond.com" {
-			return &repository.DBServiceError{}
+		if update.Category != nil {
+			feed.Category = *update.Category
 		}
-
-		notFound := true
-		for _, item := range data {
-			if item.URL == url {
-				notFound = false
-			}
+		if update.Enabled != nil {
+			feed.Enabled = *update.Enabled
 		}
+		return feed
+	}
 
-		if notFound {
-			return &repository.DBNotFoundError{}
-		}
-		return nil
+	mockAddFeedFn := func(feed entities.Feed, actor entities.Actor) entities.Feed {
+		feed.ID = newFeedID
+		return feed
 	}
 
+	knownID := mock.MatchedBy(func(id string) bool {
+		_, ok := findFeed(func(item entities.Feed) bool { return item.ID == id })
+		return ok
+	})
+
+	knownURL := mock.MatchedBy(func(canonicalURL string) bool {
+		_, ok := findFeed(func(item entities.Feed) bool { return item.CanonicalURL == canonicalURL })
+		return ok
+	})
+
 	// GetFeeds mock -------------------------------------
 	// Error condition
-	call := mockDB.On("GetFeeds", "errorCond", "errorCond", true)
-	call = call.Return(nil, &repository.DBServiceError{})
+	call := mockDB.On("GetFeeds", mock.MatchedBy(func(query entities.FeedQuery) bool {
+		return query.Provider == "errorCond" && query.Category == "errorCond"
+	}))
+	call = call.Return(entities.FeedsPage{}, &repository.DBServiceError{})
 
 	// For every other case
-	call = call.On("GetFeeds", mock.Anything, mock.Anything, mock.Anything)
+	call = call.On("GetFeeds", mock.Anything)
 	call = call.Return(mockGetFeedsFn, nil)
 
+	// GetFeed mock -------------------------------------
+	call = call.On("GetFeed", errorCondFeedID)
+	call = call.Return(entities.Feed{}, &repository.DBServiceError{})
+	call = call.On("GetFeed", knownID)
+	call = call.Return(mockGetFeedFn, nil)
+	call = call.On("GetFeed", mock.Anything)
+	call = call.Return(entities.Feed{}, &repository.DBNotFoundError{})
+
+	// GetFeedByURL mock -------------------------------------
+	call = call.On("GetFeedByURL", "https://errorcond.com/")
+	call = call.Return(entities.Feed{}, &repository.DBServiceError{})
+	call = call.On("GetFeedByURL", knownURL)
+	call = call.Return(mockGetFeedByURLFn, nil)
+	call = call.On("GetFeedByURL", mock.Anything)
+	call = call.Return(entities.Feed{}, &repository.DBNotFoundError{})
+
 	// AddFeed mock -------------------------------------
-	call = call.On("AddFeed", mock.Anything)
-	call = call.Return(mockAddFeedFn)
+	call = call.On("AddFeed", mock.MatchedBy(func(feed entities.Feed) bool {
+		return feed.URL == "http://errorCond.com" && feed.Provider == "errorCond" && feed.Category == "errorCond"
+	}), mock.Anything)
+	call = call.Return(entities.Feed{}, &repository.DBServiceError{})
+	call = call.On("AddFeed", mock.MatchedBy(func(feed entities.Feed) bool {
+		_, ok := findFeed(func(item entities.Feed) bool { return item.URL == feed.URL })
+		return ok
+	}), mock.Anything)
+	call = call.Return(entities.Feed{}, &repository.DBDUPError{})
+	call = call.On("AddFeed", mock.Anything, mock.Anything)
+	call = call.Return(mockAddFeedFn, nil)
+
+	// AddFeeds mock -------------------------------------
+	call = call.On("AddFeeds", mock.Anything, mock.Anything, mock.Anything)
+	call = call.Return(nil, &repository.DBServiceError{})
 
 	// SetFeedState mock -------------------------------------
-	call = call.On("SetFeedState", mock.Anything, mock.Anything)
-	call = call.Return(mockSetFeedStateFn)
+	call = call.On("SetFeedState", errorCondFeedID, mock.Anything, mock.Anything)
+	call = call.Return(&repository.DBServiceError{})
+	call = call.On("SetFeedState", knownID, mock.Anything, mock.Anything)
+	call = call.Return(nil)
+	call = call.On("SetFeedState", mock.Anything, mock.Anything, mock.Anything)
+	call = call.Return(&repository.DBNotFoundError{})
+
+	// UpdateFeed mock -------------------------------------
+	call = call.On("UpdateFeed", errorCondFeedID, mock.Anything, mock.Anything)
+	call = call.Return(entities.Feed{}, &repository.DBServiceError{})
+	call = call.On("UpdateFeed", knownID, mock.MatchedBy(func(update entities.FeedUpdate) bool {
+		if update.URL == nil {
+			return false
+		}
+		_, ok := findFeed(func(item entities.Feed) bool { return item.URL == *update.URL })
+		return ok
+	}), mock.Anything)
+	call = call.Return(entities.Feed{}, &repository.DBDUPError{})
+	call = call.On("UpdateFeed", knownID, mock.Anything, mock.Anything)
+	call = call.Return(mockUpdateFeedFn, nil)
+	call = call.On("UpdateFeed", mock.Anything, mock.Anything, mock.Anything)
+	call = call.Return(entities.Feed{}, &repository.DBNotFoundError{})
 
 	// DeleteFeed mock -------------------------------------
-	call = call.On("DeleteFeed", mock.Anything)
-	call = call.Return(mockDeleteFeedFn)
+	call = call.On("DeleteFeed", errorCondFeedID, mock.Anything)
+	call = call.Return(&repository.DBServiceError{})
+	call = call.On("DeleteFeed", knownID, mock.Anything)
+	call = call.Return(nil)
+	call = call.On("DeleteFeed", mock.Anything, mock.Anything)
+	call = call.Return(&repository.DBNotFoundError{})
+
+	// GetFeedHealth mock -------------------------------------
+	call = call.On("GetFeedHealth", errorCondFeedID)
+	call = call.Return(entities.FeedHealth{}, &repository.DBServiceError{})
+	call = call.On("GetFeedHealth", knownID)
+	call = call.Return(func(id string) entities.FeedHealth {
+		return entities.FeedHealth{FeedID: id, LastStatusCode: 404, LastError: "unexpected status code 404",
+			ConsecutiveFailures: 3}
+	}, nil)
+	call = call.On("GetFeedHealth", mock.Anything)
+	call = call.Return(entities.FeedHealth{}, &repository.DBNotFoundError{})
 
 	return mockDB
 }
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api/middleware"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// namedResource holds the repository methods of a resource made of named records feeds belong to, i.e. providers
+// and categories, which are otherwise handled the same way.
+type namedResource struct {
+	// noun names a single record in error messages, e.g. 'provider'.
+	noun   string
+	getAll func() (interface{}, error)
+	add    func(name string) (interface{}, error)
+	rename func(id uint64, name string) error
+	delete func(id uint64, cascade bool, actor entities.Actor) error
+}
+
+// getNamedRecords handles requests to get all the records of a named resource.
+func (s *Server) getNamedRecords(c *gin.Context, resource namedResource) {
+	records, err := resource.getAll()
+	if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(200, records)
+}
+
+// addNamedRecord handles requests to add a new record to a named resource.
+func (s *Server) addNamedRecord(c *gin.Context, resource namedResource) {
+	bodyData := struct {
+		Name string `json:"name" binding:"required,max=30"`
+	}{}
+
+	err := c.ShouldBindJSON(&bodyData)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing body: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	record, err := resource.add(bodyData.Name)
+	if errT, ok := err.(*repository.DBDUPError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 409, fmt.Sprintf("%s already exists in the database", resource.noun))
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.JSON(201, record)
+}
+
+// renameNamedRecord handles requests to rename a record of a named resource.
+func (s *Server) renameNamedRecord(c *gin.Context, resource namedResource) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
+		return
+	}
+
+	bodyData := struct {
+		Name string `json:"name" binding:"required,max=30"`
+	}{}
+
+	err = c.ShouldBindJSON(&bodyData)
+	if err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing body: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	err = resource.rename(id, bodyData.Name)
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 404, fmt.Sprintf("%s not found", resource.noun))
+		return
+	} else if errT, ok := err.(*repository.DBDUPError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 409, fmt.Sprintf("%s already exists in the database", resource.noun))
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.Status(204)
+}
+
+// deleteNamedRecord handles requests to delete a record of a named resource.
+// Records with feeds are only deleted, along with their feeds, if the 'cascade' query parameter is true.
+func (s *Server) deleteNamedRecord(c *gin.Context, resource namedResource) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		s.Logger.Info("id provided is not valid")
+		RespondWithError(c, 400, "id provided is not valid")
+		return
+	}
+
+	queryParams := struct {
+		Cascade bool `form:"cascade"`
+	}{}
+
+	if err := c.ShouldBindQuery(&queryParams); err != nil {
+		s.Logger.Info(fmt.Sprintf("error parsing query parameters: %s", err.Error()))
+		RespondWithError(c, 400, err.Error())
+		return
+	}
+
+	err = resource.delete(id, queryParams.Cascade, middleware.RequestActor(c))
+	if errT, ok := err.(*repository.DBNotFoundError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 404, fmt.Sprintf("%s not found", resource.noun))
+		return
+	} else if errT, ok := err.(*repository.DBInUseError); ok {
+		s.Logger.Error(errT.Error())
+		RespondWithError(c, 409, fmt.Sprintf("%s still has feeds, use cascade=true to delete them too",
+			resource.noun))
+		return
+	} else if err != nil {
+		s.Logger.Error(err.Error())
+		RespondWithError(c, 500, "Internal error")
+		return
+	}
+
+	c.Status(204)
+}
@@ -0,0 +1,180 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/api"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedResourceTest describes a resource handled by the named resource handlers, along with the records it has in
+// the memory repo.
+type namedResourceTest struct {
+	path string
+	// existingName is the name of the record with ID 1.
+	existingName string
+	newName      string
+	// response and expected hold the resource listing type, to decode responses into and compare with.
+	response func() interface{}
+	expected interface{}
+}
+
+var namedResourceTests = map[string]namedResourceTest{
+	"providers": {
+		path:         "/api/v1/providers",
+		existingName: "BBC News",
+		newName:      "CNN",
+		response:     func() interface{} { return &entities.Providers{} },
+		expected: &entities.Providers{
+			{ID: 1, Name: "BBC News", FeedCount: 2},
+			{ID: 2, Name: "Sky News", FeedCount: 2}},
+	},
+	"categories": {
+		path:         "/api/v1/categories",
+		existingName: "Technology",
+		newName:      "Sport",
+		response:     func() interface{} { return &entities.Categories{} },
+		expected: &entities.Categories{
+			{ID: 1, Name: "Technology", FeedCount: 2},
+			{ID: 2, Name: "UK", FeedCount: 2}},
+	},
+}
+
+func TestGetNamedResourcesHandler(t *testing.T) {
+	for name, resource := range namedResourceTests {
+		t.Run(name, func(t *testing.T) {
+			logger := log.NullLogger{}
+			repo := setupMemoryRepo(t)
+			server := api.NewServer("", 9999, false, logger, repo)
+			router := server.Router
+
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", resource.path, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, 200, w.Code)
+			responseBody := resource.response()
+			err = json.Unmarshal(w.Body.Bytes(), responseBody)
+			require.NoError(t, err)
+			assert.Equal(t, resource.expected, responseBody)
+		})
+	}
+}
+
+func TestAddNamedResourceHandler(t *testing.T) {
+	for name, resource := range namedResourceTests {
+		t.Run(name, func(t *testing.T) {
+			logger := log.NullLogger{}
+			repo := setupMemoryRepo(t)
+			server := api.NewServer("", 9999, false, logger, repo)
+			router := server.Router
+
+			tests := map[string]struct {
+				body               string
+				expectedStatusCode int
+			}{
+				"missing name":  {body: `{}`, expectedStatusCode: 400},
+				"name too long": {body: `{"name": "0123456789012345678901234567890"}`, expectedStatusCode: 400},
+				"duplicate":     {body: `{"name": "` + resource.existingName + `"}`, expectedStatusCode: 409},
+				"new record":    {body: `{"name": "` + resource.newName + `"}`, expectedStatusCode: 201},
+			}
+
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					w := httptest.NewRecorder()
+					req, err := http.NewRequest("POST", resource.path, bytes.NewBufferString(test.body))
+					require.NoError(t, err)
+					router.ServeHTTP(w, req)
+
+					assert.Equal(t, test.expectedStatusCode, w.Code)
+				})
+			}
+		})
+	}
+}
+
+func TestRenameNamedResourceHandler(t *testing.T) {
+	for name, resource := range namedResourceTests {
+		t.Run(name, func(t *testing.T) {
+			logger := log.NullLogger{}
+			repo := setupMemoryRepo(t)
+			server := api.NewServer("", 9999, false, logger, repo)
+			router := server.Router
+
+			newBody := `{"name": "` + resource.newName + `"}`
+			tests := map[string]struct {
+				id                 string
+				body               string
+				expectedStatusCode int
+			}{
+				"invalid id":   {id: "abc", body: newBody, expectedStatusCode: 400},
+				"missing name": {id: "2", body: `{}`, expectedStatusCode: 400},
+				"not found":    {id: "9999", body: newBody, expectedStatusCode: 404},
+				"duplicate":    {id: "2", body: `{"name": "` + resource.existingName + `"}`, expectedStatusCode: 409},
+				"renamed":      {id: "2", body: newBody, expectedStatusCode: 204},
+			}
+
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					w := httptest.NewRecorder()
+					req, err := http.NewRequest("PATCH", resource.path+"/"+test.id, bytes.NewBufferString(test.body))
+					require.NoError(t, err)
+					router.ServeHTTP(w, req)
+
+					assert.Equal(t, test.expectedStatusCode, w.Code)
+				})
+			}
+		})
+	}
+}
+
+func TestDeleteNamedResourceHandler(t *testing.T) {
+	for name, resource := range namedResourceTests {
+		t.Run(name, func(t *testing.T) {
+			logger := log.NullLogger{}
+			repo := setupMemoryRepo(t)
+			server := api.NewServer("", 9999, false, logger, repo)
+			router := server.Router
+
+			// Run in order, as the last request deletes the record
+			tests := []struct {
+				path               string
+				expectedStatusCode int
+			}{
+				{path: "abc", expectedStatusCode: 400},
+				{path: "9999", expectedStatusCode: 404},
+				{path: "2", expectedStatusCode: 409},
+				{path: "2?cascade=false", expectedStatusCode: 409},
+				{path: "2?cascade=true", expectedStatusCode: 204},
+				{path: "2", expectedStatusCode: 404},
+			}
+
+			for _, test := range tests {
+				w := httptest.NewRecorder()
+				req, err := http.NewRequest("DELETE", resource.path+"/"+test.path, nil)
+				require.NoError(t, err)
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, test.expectedStatusCode, w.Code, test.path)
+			}
+
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest("GET", resource.path, nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			records := []json.RawMessage{}
+			err = json.Unmarshal(w.Body.Bytes(), &records)
+			require.NoError(t, err)
+			assert.Len(t, records, 1)
+		})
+	}
+}
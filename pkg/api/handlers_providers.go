@@ -0,0 +1,37 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// providers returns the providers resource.
+func (s *Server) providers() namedResource {
+	return namedResource{
+		noun:   "provider",
+		getAll: func() (interface{}, error) { return s.Repo.GetProviders() },
+		add:    func(name string) (interface{}, error) { return s.Repo.AddProvider(name) },
+		rename: s.Repo.RenameProvider,
+		delete: s.Repo.DeleteProvider,
+	}
+}
+
+// GetProviders handles requests to get all providers.
+func (s *Server) GetProviders(c *gin.Context) {
+	s.getNamedRecords(c, s.providers())
+}
+
+// AddProvider handles requests to add a new provider.
+func (s *Server) AddProvider(c *gin.Context) {
+	s.addNamedRecord(c, s.providers())
+}
+
+// RenameProvider handles requests to rename a provider.
+func (s *Server) RenameProvider(c *gin.Context) {
+	s.renameNamedRecord(c, s.providers())
+}
+
+// DeleteProvider handles requests to delete a provider.
+// Providers with feeds are only deleted, along with their feeds, if the 'cascade' query parameter is true.
+func (s *Server) DeleteProvider(c *gin.Context) {
+	s.deleteNamedRecord(c, s.providers())
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/jwt"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// APIKeyHeader is the header clients send their API key in.
+const APIKeyHeader = "X-API-Key"
+
+// AnonymousActor is the actor changes made by requests without credentials are recorded as in the audit log.
+const AnonymousActor = "anonymous"
+
+// Gin context keys set by the Authenticator, so that they can be logged.
+const (
+	// APIKeyNameContextKey holds the name of the API key requests were authenticated with.
+	APIKeyNameContextKey = "apikey-name"
+	// UserContextKey holds the subject of the bearer token requests were authenticated with.
+	UserContextKey = "user"
+)
+
+// DefaultRolesClaim is the bearer token claim holding the roles of users by default.
+const DefaultRolesClaim = "roles"
+
+// apiKeyLength is the number of random bytes in API keys issued.
+const apiKeyLength = 32
+
+// KeyStore looks up API keys by the hash of the key.
+type KeyStore interface {
+	// GetAPIKeyByHash returns repository.DBNotFoundError if there is no key with the hash provided.
+	GetAPIKeyByHash(keyHash string) (key entities.APIKey, err error)
+}
+
+// Authenticator authenticates requests by their API key or bearer token, and checks that they were granted the scope
+// needed.
+type Authenticator struct {
+	Logger log.Logger
+	// StaticKeys holds the keys provided through the configuration, by key hash. They are looked up before the
+	// ones in Store.
+	StaticKeys map[string]entities.APIKey
+	// Store holds the keys issued through the API. It is optional.
+	Store KeyStore
+	// AnonymousRead lets requests without credentials through, as long as they only need the read scope.
+	AnonymousRead bool
+
+	// Tokens verifies bearer tokens. Bearer tokens are not accepted when nil.
+	Tokens *jwt.Verifier
+	// RolesClaim is the claim of bearer tokens holding the roles of users. Nested claims are dot separated
+	// (e.g. 'realm_access.roles').
+	RolesClaim string
+	// RoleMapping maps the values of the roles claim to roles. Values named after a role map to it, unless listed.
+	RoleMapping map[string]string
+}
+
+// Authorize authenticates the request by its API key, sent in the APIKeyHeader header, or its bearer token, and
+// aborts it unless the scope provided was granted.
+//
+// Requests without credentials get a 401 status code, unless only reading and AnonymousRead is on.
+// Requests with credentials not valid get a 401 status code too, and the ones lacking the scope a 403.
+// The name of the API key, or the subject of the token, is set in the context so that it can be logged.
+func (a *Authenticator) Authorize(c *gin.Context, scope string) {
+	var scopes []string
+
+	if rawKey := c.GetHeader(APIKeyHeader); rawKey != "" {
+		key, found, err := a.lookup(HashAPIKey(rawKey))
+		if err != nil {
+			a.Logger.Error(fmt.Sprintf("error looking up api key: %s", err.Error()))
+			c.AbortWithStatusJSON(500, gin.H{"message": "Internal error"})
+			return
+		} else if !found {
+			a.Logger.Info("request with unknown api key")
+			c.AbortWithStatusJSON(401, gin.H{"message": "api key not valid"})
+			return
+		}
+
+		c.Set(APIKeyNameContextKey, key.Name)
+		scopes = key.Scopes
+	} else if token, ok := bearerToken(c); ok && a.Tokens != nil {
+		claims, err := a.Tokens.Verify(c.Request.Context(), token)
+		if errT, ok := err.(*jwt.ValidationError); ok {
+			a.Logger.Info(errT.Error())
+			c.AbortWithStatusJSON(401, gin.H{"message": "bearer token not valid"})
+			return
+		} else if err != nil {
+			a.Logger.Error(fmt.Sprintf("error verifying bearer token: %s", err.Error()))
+			c.AbortWithStatusJSON(500, gin.H{"message": "Internal error"})
+			return
+		}
+
+		c.Set(UserContextKey, claims.Subject())
+		scopes = a.roleScopes(claims)
+	} else {
+		if scope == entities.ScopeRead && a.AnonymousRead {
+			return
+		}
+
+		a.Logger.Info("request without credentials")
+		c.AbortWithStatusJSON(401, gin.H{"message": "api key or bearer token required"})
+		return
+	}
+
+	if !entities.HasScope(scopes, scope) {
+		a.Logger.Info(fmt.Sprintf("request lacks the %s scope", scope))
+		c.AbortWithStatusJSON(403, gin.H{"message": fmt.Sprintf("%s scope required", scope)})
+		return
+	}
+}
+
+// lookup returns the key with the hash provided, from either the static keys or the store.
+func (a *Authenticator) lookup(keyHash string) (key entities.APIKey, found bool, err error) {
+	if key, ok := a.StaticKeys[keyHash]; ok {
+		return key, true, nil
+	}
+
+	if a.Store == nil {
+		return key, false, nil
+	}
+
+	key, err = a.Store.GetAPIKeyByHash(keyHash)
+	if _, ok := err.(*repository.DBNotFoundError); ok {
+		return key, false, nil
+	} else if err != nil {
+		return key, false, err
+	}
+
+	return key, true, nil
+}
+
+// roleScopes returns the scopes granted by the roles in the claims of a bearer token.
+func (a *Authenticator) roleScopes(claims jwt.Claims) (scopes []string) {
+	rolesClaim := a.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = DefaultRolesClaim
+	}
+
+	for _, value := range claims.Strings(rolesClaim) {
+		role, ok := a.RoleMapping[value]
+		if !ok {
+			role = value
+		}
+
+		if scope, ok := entities.RoleScopes[role]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// RequestActor returns who is making the request, as recorded in the audit log: 'apikey:<name>' or 'user:<subject>'
+// for authenticated requests, and AnonymousActor otherwise. The request ID is taken from the 'X-Request-ID' header.
+func RequestActor(c *gin.Context) entities.Actor {
+	actor := entities.Actor{Name: AnonymousActor, RequestID: c.GetHeader("X-Request-ID")}
+
+	if keyName := c.GetString(APIKeyNameContextKey); keyName != "" {
+		actor.Name = "apikey:" + keyName
+	} else if user := c.GetString(UserContextKey); user != "" {
+		actor.Name = "user:" + user
+	}
+
+	return actor
+}
+
+// bearerToken returns the token in the Authorization header, if it holds a bearer token.
+func bearerToken(c *gin.Context) (token string, ok bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(header[7:])
+	return token, token != ""
+}
+
+// NewAPIKey generates a new random API key, hex encoded.
+func NewAPIKey() (string, error) {
+	b := make([]byte, apiKeyLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash of an API key, which is how keys are stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
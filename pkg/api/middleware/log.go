@@ -53,6 +53,14 @@ func GinReqLogger(logger log.Logger, timeFormat string, msg string, msgType stri
 				fields["requestid"] = requestID
 			}
 
+			// Authenticated requests log who made them
+			if keyName := c.GetString(APIKeyNameContextKey); keyName != "" {
+				fields["apikey"] = keyName
+			}
+			if user := c.GetString(UserContextKey); user != "" {
+				fields["user"] = user
+			}
+
 			if msgType != "" {
 				fields["type"] = msgType
 			}
@@ -1,23 +1,52 @@
 package core
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/urlpolicy"
 )
 
 // TODO: We would replace this with a proper config library like Viper.
 
 const AppPrefix = "NEWS_APP_FEEDS_MGMT"
 
+// Database drivers supported.
+const (
+	DatabaseDriverMySQL    = "mysql"
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverSQLite   = "sqlite"
+	DatabaseDriverMemory   = "memory"
+)
+
+// Defaults used when scheduling feed fetches.
+const (
+	DefaultPollInterval     = 15 * time.Minute
+	DefaultMinPollInterval  = time.Minute
+	DefaultMaxPollInterval  = 7 * 24 * time.Hour
+	DefaultFeedLeaseTimeout = 5 * time.Minute
+)
+
+// DefaultIgnoreURLScheme treats the 'http' and 'https' URLs of a feed as the same feed by default.
+const DefaultIgnoreURLScheme = true
+
 // Configuration holds the entire configuration
 type Configuration struct {
-	Webserver WebserverConfiguration
-	Options   OptionsConfiguration
-	Database  DatabaseConfiguration
+	Webserver   WebserverConfiguration
+	Options     OptionsConfiguration
+	Database    DatabaseConfiguration
+	HealthCheck HealthCheckConfiguration
+	Purge       PurgeConfiguration
+	URLPolicy   URLPolicyConfiguration
+	Auth        AuthConfiguration
 }
 
 // WebserverConfiguration holds configuration related to the webserver
@@ -33,15 +62,111 @@ type OptionsConfiguration struct {
 	DevMode bool
 
 	LogLevel log.Level
+
+	// ValidateFeeds fetches the URL of new feeds and rejects the ones that don't hold an RSS, Atom or JSON feed.
+	ValidateFeeds bool
+	// FeedFetchTimeout is the time allowed to fetch a feed or web page, when validating or discovering feeds.
+	FeedFetchTimeout time.Duration
+	// FeedFetchMaxSize is the maximum size in bytes of the feeds and web pages fetched.
+	FeedFetchMaxSize int64
+
+	// DefaultPollInterval is the time between fetches of feeds that don't have their own poll interval.
+	// Poll intervals set on feeds must be between MinPollInterval and MaxPollInterval.
+	DefaultPollInterval time.Duration
+	MinPollInterval     time.Duration
+	MaxPollInterval     time.Duration
+	// FeedLeaseTimeout is the time fetchers have to report back on the feeds leased to them, before they are leased
+	// again.
+	FeedLeaseTimeout time.Duration
+
+	// IgnoreURLScheme treats the 'http' and 'https' URLs of a feed as the same feed, when telling whether a feed
+	// exists already.
+	IgnoreURLScheme bool
 }
 
 // DatabaseConfiguration holds configuration related to the database
 type DatabaseConfiguration struct {
+	// Driver selects the repository backend. The 'memory' driver keeps all data in memory and
+	// ignores all the other database settings.
+	Driver string
+
 	Host     string
 	Port     int
 	Username string
 	Password string
 	DBName   string
+
+	// SSLMode is only used by the 'postgres' driver.
+	SSLMode string
+
+	// Path is the database file used by the 'sqlite' driver.
+	Path string
+
+	// AutoMigrate applies all pending schema migrations at startup.
+	AutoMigrate bool
+}
+
+// HealthCheckConfiguration holds configuration related to the background feed health checker
+type HealthCheckConfiguration struct {
+	// Enabled starts the health checker along with the webserver.
+	Enabled bool
+	// Interval is the time between the start of consecutive rounds of checks.
+	Interval time.Duration
+	// Concurrency is the maximum number of feeds checked at the same time.
+	Concurrency int
+	// DisableAfter disables feeds after that many consecutive failed checks. Zero never disables feeds.
+	DisableAfter int
+}
+
+// PurgeConfiguration holds configuration related to the background purge of deleted feeds
+type PurgeConfiguration struct {
+	// Enabled starts the purge job along with the webserver. Deleted feeds are kept forever otherwise.
+	Enabled bool
+	// Interval is the time between the start of consecutive purges.
+	Interval time.Duration
+	// Retention is the time deleted feeds are kept for, during which they can be restored.
+	Retention time.Duration
+}
+
+// URLPolicyConfiguration holds configuration related to the URLs of feeds and web pages the service may fetch
+type URLPolicyConfiguration struct {
+	// AllowPrivateAddresses allows hosts resolving to private, loopback and link-local addresses.
+	AllowPrivateAddresses bool
+	// AllowedHosts, if not empty, only allows these hosts and their subdomains.
+	AllowedHosts []string
+	// DeniedHosts never allows these hosts and their subdomains.
+	DeniedHosts []string
+	// AllowedPorts, if not empty, only allows these ports.
+	AllowedPorts []int
+}
+
+// AuthConfiguration holds configuration related to API key authentication
+type AuthConfiguration struct {
+	// Enabled requires an API key with the right scope on every request but health checks.
+	Enabled bool
+	// AnonymousRead allows requests that only read without an API key.
+	AnonymousRead bool
+	// Keys holds API keys in addition to the ones issued through the API. They can't be revoked through the API.
+	Keys []APIKeyConfiguration
+
+	// JWKSURL or JWKSFile, if set, accept bearer tokens signed with the keys of the key set, e.g. by an OIDC provider.
+	JWKSURL  string
+	JWKSFile string
+	// TokenIssuer and TokenAudience, if set, must match the 'iss' and 'aud' claims of bearer tokens.
+	TokenIssuer   string
+	TokenAudience string
+	// RolesClaim is the claim of bearer tokens holding the roles of users. Nested claims are dot separated.
+	RolesClaim string
+	// RoleMapping maps values of the roles claim to the 'viewer', 'editor' and 'admin' roles.
+	RoleMapping map[string]string
+}
+
+// APIKeyConfiguration holds an API key provided through the configuration
+type APIKeyConfiguration struct {
+	Name string
+	// KeyHash is the hex encoded SHA-256 hash of the key.
+	KeyHash string
+	Scopes  []string
 }
 
 // NewConfig returns new default configuration
@@ -78,6 +203,249 @@ func (config *Configuration) LoadConfig() (err error) {
 		}
 	}
 
+	if validateFeeds, ok := os.LookupEnv(AppPrefix + "_OPTIONS_VALIDATE_FEEDS"); ok {
+		config.Options.ValidateFeeds, err = strconv.ParseBool(validateFeeds)
+		if err != nil {
+			return fmt.Errorf("configuration error: [options validatefeeds] unrecognizable boolean <%s>", validateFeeds)
+		}
+	}
+
+	if fetchTimeout, ok := os.LookupEnv(AppPrefix + "_OPTIONS_FEED_FETCH_TIMEOUT"); ok {
+		config.Options.FeedFetchTimeout, err = time.ParseDuration(fetchTimeout)
+		if err != nil || config.Options.FeedFetchTimeout <= 0 {
+			return fmt.Errorf("configuration error: [options feedfetchtimeout] input not allowed <%s>", fetchTimeout)
+		}
+	}
+
+	if fetchMaxSize, ok := os.LookupEnv(AppPrefix + "_OPTIONS_FEED_FETCH_MAX_SIZE"); ok {
+		config.Options.FeedFetchMaxSize, err = strconv.ParseInt(fetchMaxSize, 10, 64)
+		if err != nil || config.Options.FeedFetchMaxSize <= 0 {
+			return fmt.Errorf("configuration error: [options feedfetchmaxsize] input not allowed <%s>", fetchMaxSize)
+		}
+	}
+
+	if pollInterval, ok := os.LookupEnv(AppPrefix + "_OPTIONS_DEFAULT_POLL_INTERVAL"); ok {
+		config.Options.DefaultPollInterval, err = time.ParseDuration(pollInterval)
+		if err != nil || config.Options.DefaultPollInterval <= 0 {
+			return fmt.Errorf("configuration error: [options defaultpollinterval] input not allowed <%s>", pollInterval)
+		}
+	}
+
+	if minPollInterval, ok := os.LookupEnv(AppPrefix + "_OPTIONS_MIN_POLL_INTERVAL"); ok {
+		config.Options.MinPollInterval, err = time.ParseDuration(minPollInterval)
+		if err != nil || config.Options.MinPollInterval <= 0 {
+			return fmt.Errorf("configuration error: [options minpollinterval] input not allowed <%s>", minPollInterval)
+		}
+	}
+
+	if maxPollInterval, ok := os.LookupEnv(AppPrefix + "_OPTIONS_MAX_POLL_INTERVAL"); ok {
+		config.Options.MaxPollInterval, err = time.ParseDuration(maxPollInterval)
+		if err != nil || config.Options.MaxPollInterval <= 0 {
+			return fmt.Errorf("configuration error: [options maxpollinterval] input not allowed <%s>", maxPollInterval)
+		}
+	}
+
+	if config.Options.DefaultPollInterval < config.Options.MinPollInterval ||
+		config.Options.DefaultPollInterval > config.Options.MaxPollInterval {
+		return fmt.Errorf("configuration error: [options defaultpollinterval] must be between the min and max " +
+			"poll intervals")
+	}
+
+	if leaseTimeout, ok := os.LookupEnv(AppPrefix + "_OPTIONS_FEED_LEASE_TIMEOUT"); ok {
+		config.Options.FeedLeaseTimeout, err = time.ParseDuration(leaseTimeout)
+		if err != nil || config.Options.FeedLeaseTimeout <= 0 {
+			return fmt.Errorf("configuration error: [options feedleasetimeout] input not allowed <%s>", leaseTimeout)
+		}
+	}
+
+	if ignoreURLScheme, ok := os.LookupEnv(AppPrefix + "_OPTIONS_IGNORE_URL_SCHEME"); ok {
+		config.Options.IgnoreURLScheme, err = strconv.ParseBool(ignoreURLScheme)
+		if err != nil {
+			return fmt.Errorf("configuration error: [options ignoreurlscheme] unrecognizable boolean <%s>", ignoreURLScheme)
+		}
+	}
+
+	if healthCheckEnabled, ok := os.LookupEnv(AppPrefix + "_HEALTHCHECK_ENABLED"); ok {
+		config.HealthCheck.Enabled, err = strconv.ParseBool(healthCheckEnabled)
+		if err != nil {
+			return fmt.Errorf("configuration error: [healthcheck enabled] unrecognizable boolean <%s>", healthCheckEnabled)
+		}
+	}
+
+	if healthCheckInterval, ok := os.LookupEnv(AppPrefix + "_HEALTHCHECK_INTERVAL"); ok {
+		config.HealthCheck.Interval, err = time.ParseDuration(healthCheckInterval)
+		if err != nil || config.HealthCheck.Interval <= 0 {
+			return fmt.Errorf("configuration error: [healthcheck interval] input not allowed <%s>", healthCheckInterval)
+		}
+	}
+
+	if healthCheckConcurrency, ok := os.LookupEnv(AppPrefix + "_HEALTHCHECK_CONCURRENCY"); ok {
+		config.HealthCheck.Concurrency, err = strconv.Atoi(healthCheckConcurrency)
+		if err != nil || config.HealthCheck.Concurrency <= 0 {
+			return fmt.Errorf("configuration error: [healthcheck concurrency] input not allowed <%s>",
+				healthCheckConcurrency)
+		}
+	}
+
+	if healthCheckDisableAfter, ok := os.LookupEnv(AppPrefix + "_HEALTHCHECK_DISABLE_AFTER"); ok {
+		config.HealthCheck.DisableAfter, err = strconv.Atoi(healthCheckDisableAfter)
+		if err != nil || config.HealthCheck.DisableAfter < 0 {
+			return fmt.Errorf("configuration error: [healthcheck disableafter] input not allowed <%s>",
+				healthCheckDisableAfter)
+		}
+	}
+
+	if purgeEnabled, ok := os.LookupEnv(AppPrefix + "_PURGE_ENABLED"); ok {
+		config.Purge.Enabled, err = strconv.ParseBool(purgeEnabled)
+		if err != nil {
+			return fmt.Errorf("configuration error: [purge enabled] unrecognizable boolean <%s>", purgeEnabled)
+		}
+	}
+
+	if purgeInterval, ok := os.LookupEnv(AppPrefix + "_PURGE_INTERVAL"); ok {
+		config.Purge.Interval, err = time.ParseDuration(purgeInterval)
+		if err != nil || config.Purge.Interval <= 0 {
+			return fmt.Errorf("configuration error: [purge interval] input not allowed <%s>", purgeInterval)
+		}
+	}
+
+	if purgeRetention, ok := os.LookupEnv(AppPrefix + "_PURGE_RETENTION"); ok {
+		config.Purge.Retention, err = time.ParseDuration(purgeRetention)
+		if err != nil || config.Purge.Retention < 0 {
+			return fmt.Errorf("configuration error: [purge retention] input not allowed <%s>", purgeRetention)
+		}
+	}
+
+	if allowPrivate, ok := os.LookupEnv(AppPrefix + "_URLPOLICY_ALLOW_PRIVATE_ADDRESSES"); ok {
+		config.URLPolicy.AllowPrivateAddresses, err = strconv.ParseBool(allowPrivate)
+		if err != nil {
+			return fmt.Errorf("configuration error: [urlpolicy allowprivateaddresses] unrecognizable boolean <%s>",
+				allowPrivate)
+		}
+	}
+
+	if allowedHosts, ok := os.LookupEnv(AppPrefix + "_URLPOLICY_ALLOWED_HOSTS"); ok {
+		config.URLPolicy.AllowedHosts = parseList(allowedHosts)
+	}
+
+	if deniedHosts, ok := os.LookupEnv(AppPrefix + "_URLPOLICY_DENIED_HOSTS"); ok {
+		config.URLPolicy.DeniedHosts = parseList(deniedHosts)
+	}
+
+	if allowedPorts, ok := os.LookupEnv(AppPrefix + "_URLPOLICY_ALLOWED_PORTS"); ok {
+		config.URLPolicy.AllowedPorts = nil
+		for _, item := range parseList(allowedPorts) {
+			port, err := strconv.Atoi(item)
+			if err != nil || port <= 0 || port > 1<<16-1 {
+				return fmt.Errorf("configuration error: [urlpolicy allowedports] input not allowed <%s>", allowedPorts)
+			}
+			config.URLPolicy.AllowedPorts = append(config.URLPolicy.AllowedPorts, port)
+		}
+	}
+
+	if authEnabled, ok := os.LookupEnv(AppPrefix + "_AUTH_ENABLED"); ok {
+		config.Auth.Enabled, err = strconv.ParseBool(authEnabled)
+		if err != nil {
+			return fmt.Errorf("configuration error: [auth enabled] unrecognizable boolean <%s>", authEnabled)
+		}
+	}
+
+	if anonymousRead, ok := os.LookupEnv(AppPrefix + "_AUTH_ANONYMOUS_READ"); ok {
+		config.Auth.AnonymousRead, err = strconv.ParseBool(anonymousRead)
+		if err != nil {
+			return fmt.Errorf("configuration error: [auth anonymousread] unrecognizable boolean <%s>", anonymousRead)
+		}
+	}
+
+	if authKeys, ok := os.LookupEnv(AppPrefix + "_AUTH_KEYS"); ok {
+		config.Auth.Keys = nil
+		for _, item := range parseList(authKeys) {
+			key, ok := parseAPIKey(item)
+			if !ok {
+				return fmt.Errorf("configuration error: [auth keys] input not allowed <%s>", item)
+			}
+			config.Auth.Keys = append(config.Auth.Keys, key)
+		}
+	}
+
+	if jwksURL, ok := os.LookupEnv(AppPrefix + "_AUTH_JWKS_URL"); ok {
+		config.Auth.JWKSURL = jwksURL
+	}
+
+	if jwksFile, ok := os.LookupEnv(AppPrefix + "_AUTH_JWKS_FILE"); ok {
+		config.Auth.JWKSFile = jwksFile
+	}
+
+	if config.Auth.JWKSURL != "" && config.Auth.JWKSFile != "" {
+		return fmt.Errorf("configuration error: [auth jwksurl] only one of the jwks url and file may be set")
+	}
+
+	if tokenIssuer, ok := os.LookupEnv(AppPrefix + "_AUTH_TOKEN_ISSUER"); ok {
+		config.Auth.TokenIssuer = tokenIssuer
+	}
+
+	if tokenAudience, ok := os.LookupEnv(AppPrefix + "_AUTH_TOKEN_AUDIENCE"); ok {
+		config.Auth.TokenAudience = tokenAudience
+	}
+
+	if rolesClaim, ok := os.LookupEnv(AppPrefix + "_AUTH_ROLES_CLAIM"); ok {
+		if rolesClaim == "" {
+			return fmt.Errorf("configuration error: [auth rolesclaim] input not allowed <%s>", rolesClaim)
+		}
+		config.Auth.RolesClaim = rolesClaim
+	}
+
+	if roleMapping, ok := os.LookupEnv(AppPrefix + "_AUTH_ROLE_MAPPING"); ok {
+		config.Auth.RoleMapping = map[string]string{}
+		for _, item := range parseList(roleMapping) {
+			// Claim values may hold colons themselves, roles don't
+			i := strings.LastIndex(item, ":")
+			if i <= 0 {
+				return fmt.Errorf("configuration error: [auth rolemapping] input not allowed <%s>", item)
+			}
+
+			role := item[i+1:]
+			if _, ok := entities.RoleScopes[role]; !ok {
+				return fmt.Errorf("configuration error: [auth rolemapping] unrecognized role <%s>", role)
+			}
+			config.Auth.RoleMapping[item[:i]] = role
+		}
+	}
+
+	if dbDriver, ok := os.LookupEnv(AppPrefix + "_DATABASE_DRIVER"); ok {
+		config.Database.Driver = strings.ToLower(dbDriver)
+		if config.Database.Driver != DatabaseDriverMySQL && config.Database.Driver != DatabaseDriverPostgres &&
+			config.Database.Driver != DatabaseDriverSQLite && config.Database.Driver != DatabaseDriverMemory {
+			return fmt.Errorf("configuration error: [database driver] unrecognized driver <%s>", dbDriver)
+		}
+
+		if config.Database.Driver == DatabaseDriverPostgres {
+			config.Database.Port = 5432
+		}
+	}
+
+	if dbAutoMigrate, ok := os.LookupEnv(AppPrefix + "_DATABASE_AUTO_MIGRATE"); ok {
+		config.Database.AutoMigrate, err = strconv.ParseBool(dbAutoMigrate)
+		if err != nil {
+			return fmt.Errorf("configuration error: [database automigrate] unrecognizable boolean <%s>", dbAutoMigrate)
+		}
+	}
+
+	// No other database settings are needed when running in memory
+	if config.Database.Driver == DatabaseDriverMemory {
+		return nil
+	}
+
+	// SQLite only needs the path to the database file
+	if config.Database.Driver == DatabaseDriverSQLite {
+		if dbPath, ok := os.LookupEnv(AppPrefix + "_DATABASE_PATH"); ok {
+			config.Database.Path = dbPath
+		} else {
+			return fmt.Errorf("configuration error: [database path] mandatory config parameter missing")
+		}
+		return nil
+	}
+
 	if dbHost, ok := os.LookupEnv(AppPrefix + "_DATABASE_HOST"); ok {
 		config.Database.Host = dbHost
 	} else {
@@ -109,6 +477,10 @@ func (config *Configuration) LoadConfig() (err error) {
 		return fmt.Errorf("configuration error: [database dbname] mandatory config parameter missing")
 	}
 
+	if dbSSLMode, ok := os.LookupEnv(AppPrefix + "_DATABASE_SSLMODE"); ok {
+		config.Database.SSLMode = dbSSLMode
+	}
+
 	return nil
 }
 
@@ -121,9 +493,75 @@ func (config *Configuration) setDefaults() {
 	// Options
 	config.Options.DevMode = false
 	config.Options.LogLevel = log.INFO
+	config.Options.ValidateFeeds = false
+	config.Options.FeedFetchTimeout = fetcher.DefaultTimeout
+	config.Options.FeedFetchMaxSize = fetcher.DefaultMaxSize
+	config.Options.DefaultPollInterval = DefaultPollInterval
+	config.Options.MinPollInterval = DefaultMinPollInterval
+	config.Options.MaxPollInterval = DefaultMaxPollInterval
+	config.Options.FeedLeaseTimeout = DefaultFeedLeaseTimeout
+	config.Options.IgnoreURLScheme = DefaultIgnoreURLScheme
+
+	// Health check
+	config.HealthCheck.Enabled = false
+	config.HealthCheck.Interval = time.Hour
+	config.HealthCheck.Concurrency = 4
+	config.HealthCheck.DisableAfter = 0
+
+	// Purge
+	config.Purge.Enabled = false
+	config.Purge.Interval = time.Hour
+	config.Purge.Retention = 30 * 24 * time.Hour
+
+	// URL policy
+	config.URLPolicy.AllowPrivateAddresses = false
+	config.URLPolicy.AllowedPorts = urlpolicy.DefaultAllowedPorts
+
+	// Auth
+	config.Auth.Enabled = false
+	config.Auth.AnonymousRead = false
+	config.Auth.RolesClaim = "roles"
 
 	// Database
+	config.Database.Driver = DatabaseDriverMySQL
 	config.Database.Port = 3306
+	config.Database.SSLMode = "disable"
+}
+
+// parseList parses a comma separated list, ignoring empty items.
+func parseList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseAPIKey parses an API key in the format 'name:sha256-hash:scope|scope'.
+func parseAPIKey(item string) (key APIKeyConfiguration, ok bool) {
+	parts := strings.Split(item, ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return key, false
+	}
+
+	keyHash := strings.ToLower(parts[1])
+	if len(keyHash) != 2*sha256.Size {
+		return key, false
+	}
+	if _, err := hex.DecodeString(keyHash); err != nil {
+		return key, false
+	}
+
+	key = APIKeyConfiguration{Name: parts[0], KeyHash: keyHash}
+	for _, scope := range strings.Split(parts[2], "|") {
+		if !entities.IsValidScope(scope) {
+			return key, false
+		}
+		key.Scopes = append(key.Scopes, scope)
+	}
+
+	return key, true
 }
 
 // ParseLogLevel parses a string and returns a log level enum.
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"fmt"
 	"net/url"
+	"sort"
+	"strings"
 )
 
 // IsValideAbsoluteURL checks if the URL provided is absolute and uses HTTP scheme.
@@ -17,3 +20,114 @@ func IsValideAbsoluteURL(rawURL string) bool {
 
 	return true
 }
+
+// trackingParams holds the query parameters that only track where visitors came from, other than the 'utm_' ones.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"_ga":     true,
+	"_gl":     true,
+}
+
+// defaultPorts maps the schemes supported to their default ports.
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// CanonicalizeURL returns the canonical form of an absolute HTTP URL, so that URLs pointing to the same resource
+// can be told apart from different ones.
+//
+// The host is lowercased, default ports, trailing slashes, fragments and tracking query parameters (e.g.
+// 'utm_source') are dropped, and the remaining query parameters are sorted. If ignoreScheme is true, 'http' URLs
+// have the same canonical form as their 'https' counterparts.
+func CanonicalizeURL(rawURL string, ignoreScheme bool) (string, error) {
+	if !IsValideAbsoluteURL(rawURL) {
+		return "", fmt.Errorf("url <%s> is not a valid absolute URL", rawURL)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := u.Scheme
+	if ignoreScheme {
+		scheme = "https"
+	}
+
+	// Both the default port of the scheme given and the one of the scheme canonicalized to are dropped, so that
+	// 'http://host:443' is the same as 'https://host' when the scheme is ignored
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	if path == "" {
+		path = "/"
+	}
+
+	canonical := scheme + "://"
+	if u.User != nil {
+		canonical += u.User.String() + "@"
+	}
+	canonical += host + path
+	if query := canonicalQuery(u.RawQuery); query != "" {
+		canonical += "?" + query
+	}
+
+	return canonical, nil
+}
+
+// queryParam holds a query parameter, as found in a URL, along with its decoded name.
+type queryParam struct {
+	name string
+	raw  string
+}
+
+// canonicalQuery returns the query provided without tracking parameters and sorted by parameter name.
+//
+// Parameters that can be decoded are encoded again the same way, the others, e.g. holding invalid escapes, are kept as
+// they are. Unlike url.ParseQuery, semicolons are not rejected, they are just part of the parameter.
+func canonicalQuery(rawQuery string) string {
+	var params []queryParam
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		rawName, rawValue := raw, ""
+		if i := strings.Index(raw, "="); i >= 0 {
+			rawName, rawValue = raw[:i], raw[i+1:]
+		}
+
+		name, nameErr := url.QueryUnescape(rawName)
+		value, valueErr := url.QueryUnescape(rawValue)
+		if nameErr != nil || valueErr != nil {
+			params = append(params, queryParam{name: rawName, raw: raw})
+			continue
+		}
+
+		lowerName := strings.ToLower(name)
+		if strings.HasPrefix(lowerName, "utm_") || trackingParams[lowerName] {
+			continue
+		}
+		params = append(params, queryParam{name: name, raw: url.QueryEscape(name) + "=" + url.QueryEscape(value)})
+	}
+
+	// Parameters with the same name keep their order, as it may matter
+	sort.SliceStable(params, func(i, j int) bool { return params[i].name < params[j].name })
+
+	query := make([]string, 0, len(params))
+	for _, param := range params {
+		query = append(query, param.raw)
+	}
+	return strings.Join(query, "&")
+}
@@ -24,3 +24,59 @@ func TestIsValidAbsoluteURL(t *testing.T) {
 		})
 	}
 }
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := map[string]struct {
+		rawURL         string
+		ignoreScheme   bool
+		expectedOutput string
+		expectedError  bool
+	}{
+		"already canonical": {rawURL: "http://feeds.bbci.co.uk/news/uk/rss.xml",
+			expectedOutput: "http://feeds.bbci.co.uk/news/uk/rss.xml"},
+		"uppercase host": {rawURL: "http://Feeds.BBCI.co.uk/news/UK/rss.xml",
+			expectedOutput: "http://feeds.bbci.co.uk/news/UK/rss.xml"},
+		"default port": {rawURL: "https://example.com:443/rss.xml",
+			expectedOutput: "https://example.com/rss.xml"},
+		"other port": {rawURL: "http://example.com:8080/rss.xml",
+			expectedOutput: "http://example.com:8080/rss.xml"},
+		"trailing slash": {rawURL: "https://example.com/feed/",
+			expectedOutput: "https://example.com/feed"},
+		"no path": {rawURL: "https://example.com",
+			expectedOutput: "https://example.com/"},
+		"fragment": {rawURL: "https://example.com/rss.xml#top",
+			expectedOutput: "https://example.com/rss.xml"},
+		"sorted query": {rawURL: "https://example.com/rss?b=2&a=1",
+			expectedOutput: "https://example.com/rss?a=1&b=2"},
+		"tracking params": {rawURL: "https://example.com/rss?utm_source=x&UTM_Medium=y&fbclid=z&id=3",
+			expectedOutput: "https://example.com/rss?id=3"},
+		"scheme kept": {rawURL: "http://example.com/rss.xml", ignoreScheme: false,
+			expectedOutput: "http://example.com/rss.xml"},
+		"scheme ignored": {rawURL: "http://example.com:80/rss.xml", ignoreScheme: true,
+			expectedOutput: "https://example.com/rss.xml"},
+		"https port with scheme ignored": {rawURL: "http://example.com:443/rss.xml", ignoreScheme: true,
+			expectedOutput: "https://example.com/rss.xml"},
+		"ipv6 host": {rawURL: "http://[::1]:80/rss.xml",
+			expectedOutput: "http://[::1]/rss.xml"},
+		"semicolon in query": {rawURL: "https://example.com/rss?b=2;c=3&a=1",
+			expectedOutput: "https://example.com/rss?a=1&b=2%3Bc%3D3"},
+		"invalid escape in query": {rawURL: "https://example.com/rss?b=%zz&utm_source=x&a=1",
+			expectedOutput: "https://example.com/rss?a=1&b=%zz"},
+		"repeated params": {rawURL: "https://example.com/rss?tag=b&id=1&tag=a",
+			expectedOutput: "https://example.com/rss?id=1&tag=b&tag=a"},
+		"relative url": {rawURL: "/rss.xml", expectedError: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			value, err := core.CanonicalizeURL(test.rawURL, test.ignoreScheme)
+			if test.expectedError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedOutput, value)
+		})
+	}
+}
@@ -1,10 +1,348 @@
 package entities
 
+import "time"
+
 type Feed struct {
-	URL      string `json:"url"`
-	Provider string `json:"provider"`
-	Category string `json:"category"`
-	Enabled  bool   `json:"-"`
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// CanonicalURL is the form of the URL used to tell feeds apart, so that variants of the same URL can't be added
+	// twice. Repositories use the URL itself when empty.
+	CanonicalURL string `json:"canonical_url"`
+	Provider     string `json:"provider"`
+	Category     string `json:"category"`
+	Enabled      bool   `json:"enabled"`
+	FeedMetadata
+	FetchState FeedFetchState `json:"fetch_state"`
+	// PollInterval is the number of seconds between fetches of the feed. Zero means the default interval.
+	PollInterval int64 `json:"poll_interval"`
+	// NextFetchAt is when the feed is due to be fetched next. Feeds leased to a fetcher are due again when the lease
+	// expires.
+	NextFetchAt time.Time `json:"next_fetch_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// DeletedAt is when the feed was deleted. Deleted feeds can be restored until they are purged.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type Feeds []Feed
+
+// FeedMetadata holds the details of a feed taken from the feed document.
+type FeedMetadata struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Language is a lowercase language tag (e.g. 'en-gb').
+	Language string `json:"language"`
+	// SiteURL is the URL of the website the feed belongs to.
+	SiteURL string `json:"site_url"`
+	IconURL string `json:"icon_url"`
+}
+
+// Maximum lengths of the feed metadata fields.
+const (
+	MaxFeedTitleLength       = 250
+	MaxFeedDescriptionLength = 1000
+	MaxFeedLanguageLength    = 35
+	// MaxFeedLinkLength applies to both SiteURL and IconURL.
+	MaxFeedLinkLength = 250
+)
+
+// FeedFetchState holds what the fetchers learnt about a feed the last time they fetched it, so that the next fetch
+// can be a conditional request.
+type FeedFetchState struct {
+	// ETag and LastModified hold the 'ETag' and 'Last-Modified' response headers, as received.
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+	// ContentHash is a hash of the feed document, in whatever format the fetchers choose.
+	ContentHash string `json:"content_hash"`
+	// UpdateFrequency is the average number of seconds between updates of the feed document, as observed by the
+	// fetchers. Zero if unknown.
+	UpdateFrequency int64 `json:"update_frequency"`
+}
+
+// MaxFeedFetchStateLength is the maximum length of the FeedFetchState text fields.
+const MaxFeedFetchStateLength = 250
+
+// Fields feeds can be sorted by.
+const (
+	FeedSortURL       = "url"
+	FeedSortProvider  = "provider"
+	FeedSortCategory  = "category"
+	FeedSortCreatedAt = "created_at"
+)
+
+// FeedUpdate holds the changes to apply to a feed. Nil fields are left unchanged.
+type FeedUpdate struct {
+	URL *string
+	// CanonicalURL is only used along with URL. The URL itself is used when nil.
+	CanonicalURL *string
+	Provider     *string
+	Category     *string
+	Enabled      *bool
+	// Metadata replaces all the feed metadata fields.
+	Metadata *FeedMetadata
+	// PollInterval only takes effect after the next fetch of the feed.
+	PollInterval *int64
+}
+
+// FeedQuery holds the criteria used to list feeds.
+type FeedQuery struct {
+	Provider string
+	Category string
+	// Language matches feeds in the language provided, or in any of its regional variants (e.g. 'en' matches
+	// 'en-gb').
+	Language string
+	// Enabled filters feeds by state. Nil matches feeds in any state.
+	Enabled *bool
+	// IncludeDeleted matches deleted feeds too.
+	IncludeDeleted bool
+
+	// Limit is the maximum number of feeds to return. Zero means no limit.
+	Limit int
+	// Cursor is the opaque position returned with the previous page. Empty for the first page.
+	Cursor string
+	// Sort is one of the FeedSort* fields, optionally prefixed with '-' for descending order.
+	// Feeds with the same sort value are always ordered by URL.
+	Sort string
+}
+
+// FeedsPage holds a page of feeds.
+type FeedsPage struct {
+	Feeds Feeds `json:"feeds"`
+	// NextCursor is empty when there are no more pages.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is the number of feeds matching the query filters, across all pages.
+	Total int64 `json:"total"`
+}
+
+// Outcomes of adding a feed as part of a batch.
+const (
+	FeedBatchCreated   = "created"
+	FeedBatchDuplicate = "duplicate"
+	FeedBatchInvalid   = "invalid"
+	FeedBatchFailed    = "failed"
+	// FeedBatchSkipped means the feed was not added because other feeds in an atomic batch could not be.
+	FeedBatchSkipped = "skipped"
+	// FeedBatchValid means the feed would have been added, had the batch not been a dry run.
+	FeedBatchValid = "valid"
+	// FeedBatchTimedOut means the feed could not be checked before the request deadline. Unlike invalid feeds, it
+	// may be added by trying again, in a smaller batch.
+	FeedBatchTimedOut = "timed_out"
+)
+
+// FeedBatchOptions holds the options used when adding feeds in a batch.
+type FeedBatchOptions struct {
+	// Atomic adds either all the feeds in the batch or none of them.
+	Atomic bool
+	// DryRun only reports the outcome for each feed, without adding any of them.
+	DryRun bool
+}
+
+// FeedBatchResult holds the outcome of adding a feed as part of a batch.
+type FeedBatchResult struct {
+	// Status is one of the FeedBatch* outcomes.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Feed holds the feed as provided, or as created when Status is FeedBatchCreated.
+	Feed Feed `json:"feed"`
+}
+
+type FeedBatchResults []FeedBatchResult
+
+// FeedBatchReport summarises the outcome of adding feeds in a batch.
+type FeedBatchReport struct {
+	Created int `json:"created"`
+	// Valid is the number of feeds that would have been created in a dry run.
+	Valid  int `json:"valid,omitempty"`
+	Failed int `json:"failed"`
+	// TimedOut is the number of failed feeds that timed out, which may be added by trying again.
+	TimedOut int              `json:"timed_out,omitempty"`
+	Results  FeedBatchResults `json:"results"`
+}
+
+// FeedCandidate represents a feed found on a website.
+type FeedCandidate struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// Format is one of 'rss', 'atom' or 'json'.
+	Format string `json:"format"`
+}
+
+type FeedCandidates []FeedCandidate
+
+// FeedHealth holds the outcome of the latest health checks of a feed.
+// Time fields are nil until the first check of that kind.
+type FeedHealth struct {
+	FeedID        string     `json:"feed_id"`
+	LastCheckedAt *time.Time `json:"last_checked_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	// LastStatusCode is the HTTP status code of the latest check, or zero if no response was received.
+	LastStatusCode int `json:"last_status_code"`
+	// LastError describes why the latest check failed. Empty if it succeeded.
+	LastError           string `json:"last_error"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// MaxFeedHealthErrorLength is the maximum length of FeedHealth.LastError.
+const MaxFeedHealthErrorLength = 250
+
+// FeedCheck holds the outcome of a single health check of a feed.
+type FeedCheck struct {
+	CheckedAt  time.Time
+	StatusCode int
+	// Error is empty if the check succeeded.
+	Error string
+}
+
+// Provider represents a feed provider (e.g. 'BBC News').
+type Provider struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	// FeedCount is the number of feeds from this provider.
+	FeedCount int64 `json:"feed_count"`
+}
+
+type Providers []Provider
+
+// Category represents a feed category (e.g. 'Technology').
+type Category struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	// FeedCount is the number of feeds in this category.
+	FeedCount int64 `json:"feed_count"`
+}
+
+type Categories []Category
+
+// Scopes granted to API keys, and to users through their roles. Each scope grants the ones before it too, e.g. keys
+// with the 'write' scope can also read.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// Scopes holds all scopes, from the least to the most privileged.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// IsValidScope checks whether the scope provided is one of Scopes.
+func IsValidScope(scope string) bool {
+	return scopeRank(scope) >= 0
+}
+
+// scopeRank returns the position of the scope in Scopes, or -1 if it's not a valid scope.
+func scopeRank(scope string) int {
+	for i, s := range Scopes {
+		if s == scope {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasScope checks whether the scopes granted include the scope provided, either directly or through a more privileged
+// scope.
+func HasScope(granted []string, scope string) bool {
+	rank := scopeRank(scope)
+	if rank < 0 {
+		return false
+	}
+
+	for _, s := range granted {
+		if scopeRank(s) >= rank {
+			return true
+		}
+	}
+	return false
+}
+
+// User roles, granted to users authenticating with bearer tokens.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// RoleScopes maps each role to the scope it grants.
+var RoleScopes = map[string]string{RoleViewer: ScopeRead, RoleEditor: ScopeWrite, RoleAdmin: ScopeAdmin}
+
+// APIKey represents a key clients authenticate with.
+// Only the hash of keys is stored, so keys themselves are only known when issued.
+type APIKey struct {
+	// ID is empty for keys provided through the configuration.
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasScope checks whether the key grants the scope provided, either directly or through a more privileged scope.
+func (k APIKey) HasScope(scope string) bool {
+	return HasScope(k.Scopes, scope)
+}
+
+type APIKeys []APIKey
+
+// Actions recorded in the audit log.
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionEnable  = "enable"
+	AuditActionDisable = "disable"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+)
+
+// Maximum lengths of the audit log text fields. Longer values are truncated.
+const (
+	MaxAuditActorLength     = 250
+	MaxAuditRequestIDLength = 100
+)
+
+// Actor identifies who makes a change, to record it in the audit log.
+type Actor struct {
+	// Name is e.g. 'apikey:<name>' or 'user:<subject>' for requests, or the name of the background job.
+	Name string
+	// RequestID is the ID of the request the change is made with. Empty for background jobs.
+	RequestID string
+}
+
+// AuditEntry records a change made to a feed.
+type AuditEntry struct {
+	ID     uint64 `json:"id"`
+	FeedID string `json:"feed_id"`
+	// Action is one of the AuditAction* actions.
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	RequestID string `json:"request_id"`
+	// Before and After hold the feed before and after the change. Before is nil for feeds created, and After for
+	// feeds purged.
+	Before    *Feed     `json:"before"`
+	After     *Feed     `json:"after"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditEntries []AuditEntry
+
+// AuditQuery holds the criteria used to list audit log entries.
+type AuditQuery struct {
+	FeedID string
+	Actor  string
+	// Since and Until bound the time entries were recorded at. Since is inclusive, Until is exclusive, and zero times
+	// leave the range open.
+	Since time.Time
+	Until time.Time
+
+	// Limit is the maximum number of entries to return. Zero means no limit.
+	Limit int
+	// Cursor is the opaque position returned with the previous page. Empty for the first page.
+	Cursor string
+}
+
+// AuditPage holds a page of audit log entries, the most recent first.
+type AuditPage struct {
+	Entries AuditEntries `json:"entries"`
+	// NextCursor is empty when there are no more pages.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
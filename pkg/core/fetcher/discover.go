@@ -0,0 +1,136 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"golang.org/x/net/html"
+)
+
+// linkTypeFormats maps the types of '<link rel="alternate">' tags pointing to feeds to the format of the feed.
+var linkTypeFormats = map[string]string{
+	"application/rss+xml":   FormatRSS,
+	"application/atom+xml":  FormatAtom,
+	"application/feed+json": FormatJSONFeed,
+}
+
+// commonFeedPaths holds the paths websites often serve their feed from.
+var commonFeedPaths = []string{"/feed", "/rss", "/feed.xml", "/rss.xml", "/atom.xml", "/index.xml", "/feed.json"}
+
+// Discover finds the feeds of the website at the URL provided.
+//
+// If the URL holds a feed, it is the only one returned. Otherwise, the feeds are taken from the
+// '<link rel="alternate">' tags in the page, in the order they appear. If the page has none, the paths websites
+// often serve their feed from are tried instead.
+// All errors returned are of type *InvalidFeedError.
+func (f *Fetcher) Discover(ctx context.Context, pageURL string) (entities.FeedCandidates, error) {
+	resp, err := f.get(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc, err := Parse(resp.body); err == nil {
+		return entities.FeedCandidates{{URL: resp.url.String(), Title: doc.Title, Format: doc.Format}}, nil
+	}
+
+	candidates := findFeedLinks(resp.body, resp.url)
+	if len(candidates) != 0 {
+		return candidates, nil
+	}
+
+	return f.probeFeedPaths(ctx, resp.url), nil
+}
+
+// findFeedLinks returns the feeds in the '<link rel="alternate">' tags of an HTML page.
+// Relative links are resolved against the page '<base>', if any, or its URL.
+func findFeedLinks(body []byte, pageURL *url.URL) entities.FeedCandidates {
+	candidates := entities.FeedCandidates{}
+	seen := map[string]bool{}
+	baseURL := pageURL
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return candidates
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		attrs := map[string]string{}
+		for _, attr := range token.Attr {
+			attrs[attr.Key] = strings.TrimSpace(attr.Val)
+		}
+
+		switch token.Data {
+		case "base":
+			if href, err := pageURL.Parse(attrs["href"]); err == nil && attrs["href"] != "" {
+				baseURL = href
+			}
+		case "link":
+			if !hasToken(attrs["rel"], "alternate") {
+				continue
+			}
+
+			linkType := strings.ToLower(strings.TrimSpace(strings.Split(attrs["type"], ";")[0]))
+			format, ok := linkTypeFormats[linkType]
+			if !ok || attrs["href"] == "" {
+				continue
+			}
+
+			href, err := baseURL.Parse(attrs["href"])
+			if err != nil || (href.Scheme != "http" && href.Scheme != "https") || seen[href.String()] {
+				continue
+			}
+
+			seen[href.String()] = true
+			candidates = append(candidates, entities.FeedCandidate{URL: href.String(), Title: attrs["title"],
+				Format: format})
+		}
+	}
+}
+
+// hasToken checks if the space separated list provided holds the token, regardless of case.
+func hasToken(list string, token string) bool {
+	for _, t := range strings.Fields(list) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeFeedPaths returns the feeds found at the paths websites often serve their feed from, in the order the paths
+// are listed. Paths are fetched concurrently.
+func (f *Fetcher) probeFeedPaths(ctx context.Context, siteURL *url.URL) entities.FeedCandidates {
+	found := make([]*entities.FeedCandidate, len(commonFeedPaths))
+
+	var wg sync.WaitGroup
+	for i, path := range commonFeedPaths {
+		wg.Add(1)
+		go func(i int, feedURL string) {
+			defer wg.Done()
+
+			doc, err := f.Fetch(ctx, feedURL)
+			if err != nil {
+				return
+			}
+			found[i] = &entities.FeedCandidate{URL: feedURL, Title: doc.Title, Format: doc.Format}
+		}(i, siteURL.ResolveReference(&url.URL{Path: path}).String())
+	}
+	wg.Wait()
+
+	candidates := entities.FeedCandidates{}
+	for _, candidate := range found {
+		if candidate != nil {
+			candidates = append(candidates, *candidate)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,95 @@
+package fetcher_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/news/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<!DOCTYPE html>
+<html>
+<head>
+  <title>News</title>
+  <link rel="stylesheet" href="/style.css">
+  <link rel="alternate" type="application/rss+xml" title="Top stories" href="rss.xml">
+  <link rel="Alternate" type="application/atom+xml; charset=utf-8" title="Atom" href="https://other.example.com/atom">
+  <link rel="alternate" type="application/rss+xml" title="Top stories again" href="/news/rss.xml">
+  <link rel="alternate" type="text/html" hreflang="fr" href="/fr/news/">
+</head>
+<body></body>
+</html>`))
+	})
+	mux.HandleFunc("/based/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><base href="/feeds/"><link rel="alternate" type="application/feed+json" ` +
+			`href="blog.json"></head></html>`))
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>Blog</title></head></html>`))
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(atomDoc))
+	})
+	mux.HandleFunc("/feed.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jsonFeedDoc))
+	})
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>Not a feed</body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := fetcher.NewFetcher(time.Second, 1<<20, nil)
+
+	tests := map[string]struct {
+		path               string
+		expectedCandidates entities.FeedCandidates
+		expectedErr        bool
+	}{
+		"link tags": {
+			path: "/news/",
+			expectedCandidates: entities.FeedCandidates{
+				{URL: server.URL + "/news/rss.xml", Title: "Top stories", Format: fetcher.FormatRSS},
+				{URL: "https://other.example.com/atom", Title: "Atom", Format: fetcher.FormatAtom},
+			}},
+		"base tag": {
+			path: "/based/",
+			expectedCandidates: entities.FeedCandidates{
+				{URL: server.URL + "/feeds/blog.json", Format: fetcher.FormatJSONFeed},
+			}},
+		"common paths": {
+			path: "/blog/",
+			expectedCandidates: entities.FeedCandidates{
+				{URL: server.URL + "/feed.xml", Title: "Example Blog", Format: fetcher.FormatAtom},
+				{URL: server.URL + "/feed.json", Title: "JSON Blog", Format: fetcher.FormatJSONFeed},
+			}},
+		"feed url": {
+			path: "/feed.xml",
+			expectedCandidates: entities.FeedCandidates{
+				{URL: server.URL + "/feed.xml", Title: "Example Blog", Format: fetcher.FormatAtom},
+			}},
+		"not found": {path: "/missing", expectedErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			candidates, err := f.Discover(context.Background(), server.URL+test.path)
+			if test.expectedErr {
+				assert.IsType(t, &fetcher.InvalidFeedError{}, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCandidates, candidates)
+		})
+	}
+}
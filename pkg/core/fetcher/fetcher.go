@@ -0,0 +1,168 @@
+// Package fetcher fetches feed documents and checks that they hold a feed in one of the formats supported.
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/urlpolicy"
+)
+
+// userAgent identifies this service to feed servers.
+const userAgent = "news-app-feeds-mgmt-service"
+
+// acceptHeader lists the MIME types of the formats supported, followed by the generic ones feeds are often served
+// with.
+const acceptHeader = "application/rss+xml, application/atom+xml, application/feed+json, " +
+	"application/xml;q=0.9, text/xml;q=0.9, application/json;q=0.9, */*;q=0.1"
+
+// InvalidFeedError is returned when a URL could not be fetched or doesn't hold a feed.
+type InvalidFeedError struct {
+	Msg string
+	Err error
+	// StatusCode is the HTTP status code of the response, or zero if no response was received.
+	StatusCode int
+}
+
+func (e *InvalidFeedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Msg, e.Err.Error())
+	}
+	return e.Msg
+}
+func (e *InvalidFeedError) Unwrap() error {
+	return e.Err
+}
+
+// Defaults used when fetching feeds.
+const (
+	DefaultTimeout       = 5 * time.Second
+	DefaultMaxSize int64 = 5 << 20
+)
+
+// Fetcher fetches feed documents over HTTP.
+type Fetcher struct {
+	Client *http.Client
+	// MaxSize is the maximum size in bytes of the documents fetched.
+	MaxSize int64
+}
+
+// NewFetcher returns a Fetcher that gives up on requests taking longer than the timeout provided, and on documents
+// larger than maxSize bytes.
+// If a policy is provided, it's enforced on every connection and redirect, so that documents are only ever fetched
+// from the addresses it allows. Requests don't go through proxies then.
+func NewFetcher(timeout time.Duration, maxSize int64, policy *urlpolicy.Policy) *Fetcher {
+	client := &http.Client{Timeout: timeout}
+
+	if policy != nil {
+		dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second, Control: policy.Control}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+		client.Transport = transport
+		client.CheckRedirect = policy.CheckRedirect
+	}
+
+	return &Fetcher{
+		Client:  client,
+		MaxSize: maxSize,
+	}
+}
+
+// Fetch fetches the document at the URL provided and parses it as a feed.
+// Relative links in the document are resolved against the URL.
+// All errors returned are of type *InvalidFeedError.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Document, error) {
+	doc, _, err := f.fetch(ctx, rawURL)
+	return doc, err
+}
+
+// Check fetches the document at the URL provided and checks that it holds a feed.
+// It returns the HTTP status code of the response, or zero if no response was received.
+// All errors returned are of type *InvalidFeedError.
+func (f *Fetcher) Check(ctx context.Context, rawURL string) (int, error) {
+	_, statusCode, err := f.fetch(ctx, rawURL)
+	return statusCode, err
+}
+
+// fetch fetches and parses the feed at the URL provided.
+// It returns the HTTP status code of the response along with the document.
+func (f *Fetcher) fetch(ctx context.Context, rawURL string) (Document, int, error) {
+	resp, err := f.get(ctx, rawURL)
+	if err != nil {
+		return Document{}, resp.statusCode, err
+	}
+
+	doc, err := Parse(resp.body)
+	if err != nil {
+		return Document{}, resp.statusCode, &InvalidFeedError{Msg: "not a valid feed", Err: err,
+			StatusCode: resp.statusCode}
+	}
+
+	doc.SiteURL = resolveLink(resp.url, doc.SiteURL)
+	doc.IconURL = resolveLink(resp.url, doc.IconURL)
+	return doc, resp.statusCode, nil
+}
+
+// resolveLink resolves a link found in a document against the URL of the document.
+// It returns an empty string if the link is not a valid HTTP link.
+func resolveLink(docURL *url.URL, link string) string {
+	if link == "" {
+		return ""
+	}
+
+	u, err := docURL.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// response holds a document fetched over HTTP.
+type response struct {
+	body []byte
+	// url is the URL the document was finally fetched from, after following redirects.
+	url        *url.URL
+	statusCode int
+}
+
+// get fetches the document at the URL provided.
+// The status code of the response is returned even on error, if a response was received.
+// All errors returned are of type *InvalidFeedError.
+func (f *Fetcher) get(ctx context.Context, rawURL string) (response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return response{}, &InvalidFeedError{Msg: "error creating request", Err: err}
+	}
+	req.Header.Set("Accept", acceptHeader)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return response{}, &InvalidFeedError{Msg: "error fetching document", Err: err}
+	}
+	defer resp.Body.Close()
+
+	result := response{url: resp.Request.URL, statusCode: resp.StatusCode}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, &InvalidFeedError{Msg: fmt.Sprintf("error fetching document: unexpected status code %d",
+			resp.StatusCode), StatusCode: resp.StatusCode}
+	}
+
+	result.body, err = io.ReadAll(io.LimitReader(resp.Body, f.MaxSize+1))
+	if err != nil {
+		return result, &InvalidFeedError{Msg: "error reading document", Err: err, StatusCode: resp.StatusCode}
+	}
+	if int64(len(result.body)) > f.MaxSize {
+		return result, &InvalidFeedError{Msg: fmt.Sprintf("error reading document: document is larger than %d bytes",
+			f.MaxSize), StatusCode: resp.StatusCode}
+	}
+
+	return result, nil
+}
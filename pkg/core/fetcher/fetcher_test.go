@@ -0,0 +1,243 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/urlpolicy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rssDoc = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title> BBC News - UK </title>
+    <link>https://www.bbc.co.uk/news/</link>
+    <atom:link href="https://feeds.bbci.co.uk/news/uk/rss.xml" rel="self" type="application/rss+xml"/>
+    <description>BBC News - UK</description>
+    <language>en-GB</language>
+    <image>
+      <url>/images/logo.gif</url>
+    </image>
+  </channel>
+</rss>`
+
+	atomDoc = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:lang="en">
+  <title>Example Blog</title>
+  <subtitle>Thoughts</subtitle>
+  <link rel="self" href="https://blog.example.com/feed.xml"/>
+  <link href="https://blog.example.com/"/>
+  <logo>https://blog.example.com/logo.png</logo>
+  <updated>2021-03-14T10:30:00Z</updated>
+</feed>`
+
+	jsonFeedDoc = `{"version": "https://jsonfeed.org/version/1.1", "title": "JSON Blog", "language": "pt_PT", ` +
+		`"home_page_url": "https://json.example.com/", "favicon": "https://json.example.com/favicon.ico", "items": []}`
+
+	htmlDoc = `<!DOCTYPE html>
+<html lang=en>
+  <head><title>BBC News</title></head>
+  <body><p>Not a feed<br></p></body>
+</html>`
+)
+
+func TestParse(t *testing.T) {
+	rssDocument := fetcher.Document{
+		Format:      fetcher.FormatRSS,
+		Title:       "BBC News - UK",
+		Description: "BBC News - UK",
+		Language:    "en-GB",
+		SiteURL:     "https://www.bbc.co.uk/news/",
+		IconURL:     "/images/logo.gif",
+	}
+
+	tests := map[string]struct {
+		body             string
+		expectedDocument fetcher.Document
+		expectedErr      bool
+	}{
+		"rss": {body: rssDoc, expectedDocument: rssDocument},
+		"atom": {
+			body: atomDoc,
+			expectedDocument: fetcher.Document{
+				Format:      fetcher.FormatAtom,
+				Title:       "Example Blog",
+				Description: "Thoughts",
+				Language:    "en",
+				SiteURL:     "https://blog.example.com/",
+				IconURL:     "https://blog.example.com/logo.png",
+			}},
+		"json feed": {
+			body: jsonFeedDoc,
+			expectedDocument: fetcher.Document{
+				Format:   fetcher.FormatJSONFeed,
+				Title:    "JSON Blog",
+				Language: "pt_PT",
+				SiteURL:  "https://json.example.com/",
+				IconURL:  "https://json.example.com/favicon.ico",
+			}},
+		"leading newlines": {body: "\n\n" + rssDoc, expectedDocument: rssDocument},
+		"empty":            {body: " \n", expectedErr: true},
+		"html":             {body: htmlDoc, expectedErr: true},
+		"plain text":       {body: "hello", expectedErr: true},
+		"broken rss":       {body: `<rss version="2.0"><channel><title>Broken</channel></rss>`, expectedErr: true},
+		"rss 1.0":          {body: `<rss version="1.0"><channel><title>Old</title></channel></rss>`, expectedErr: true},
+		"rss no channel":   {body: `<rss version="2.0"></rss>`, expectedErr: true},
+		"feed not atom":    {body: `<feed><title>Something else</title></feed>`, expectedErr: true},
+		"plain json":       {body: `{"title": "Not a feed", "items": []}`, expectedErr: true},
+		"json no items":    {body: `{"version": "https://jsonfeed.org/version/1", "title": "JSON Blog"}`, expectedErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc, err := fetcher.Parse([]byte(test.body))
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedDocument, doc)
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(rssDoc))
+	})
+	mux.HandleFunc("/index.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(htmlDoc))
+	})
+	mux.HandleFunc("/large.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Replace(rssDoc, "<link>", strings.Repeat(" ", 2048)+"<link>", 1)))
+	})
+	mux.HandleFunc("/slow.xml", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(rssDoc))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := fetcher.NewFetcher(100*time.Millisecond, 2048, nil)
+
+	// Relative links are resolved against the feed URL
+	rssDocument := fetcher.Document{
+		Format:      fetcher.FormatRSS,
+		Title:       "BBC News - UK",
+		Description: "BBC News - UK",
+		Language:    "en-GB",
+		SiteURL:     "https://www.bbc.co.uk/news/",
+		IconURL:     server.URL + "/images/logo.gif",
+	}
+
+	tests := map[string]struct {
+		path               string
+		expectedDocument   fetcher.Document
+		expectedStatusCode int
+		expectedErr        string
+	}{
+		"feed": {path: "/rss.xml", expectedDocument: rssDocument, expectedStatusCode: 200},
+		"not a feed": {path: "/index.html", expectedStatusCode: 200,
+			expectedErr: "not a valid feed: document is an HTML page"},
+		"not found": {path: "/missing.xml", expectedStatusCode: 404,
+			expectedErr: "error fetching document: unexpected status code 404"},
+		"too large": {path: "/large.xml", expectedStatusCode: 200,
+			expectedErr: "error reading document: document is larger than 2048 bytes"},
+		"too slow": {path: "/slow.xml", expectedStatusCode: 0, expectedErr: "error fetching document"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			statusCode, checkErr := f.Check(context.Background(), server.URL+test.path)
+			assert.Equal(t, test.expectedStatusCode, statusCode)
+
+			doc, err := f.Fetch(context.Background(), server.URL+test.path)
+			if test.expectedErr != "" {
+				require.IsType(t, &fetcher.InvalidFeedError{}, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				assert.Equal(t, test.expectedStatusCode, err.(*fetcher.InvalidFeedError).StatusCode)
+				assert.Error(t, checkErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NoError(t, checkErr)
+			assert.Equal(t, test.expectedDocument, doc)
+		})
+	}
+}
+
+func TestFetchURLPolicy(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(rssDoc))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/redirect.xml", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/rss.xml", http.StatusFound)
+	})
+
+	tests := map[string]struct {
+		policy      *urlpolicy.Policy
+		url         string
+		expectedErr string
+	}{
+		"private address": {policy: &urlpolicy.Policy{}, url: server.URL + "/rss.xml",
+			expectedErr: "url not allowed: address <127.0.0.1> is private or reserved"},
+		"private address allowed": {policy: &urlpolicy.Policy{AllowPrivateAddresses: true},
+			url: server.URL + "/rss.xml"},
+		"redirect to denied host": {
+			policy:      &urlpolicy.Policy{AllowPrivateAddresses: true, DeniedHosts: []string{"localhost"}},
+			url:         server.URL + "/redirect.xml",
+			expectedErr: "url not allowed: host <localhost> is denied"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := fetcher.NewFetcher(time.Second, 1<<20, test.policy)
+
+			_, err := f.Fetch(context.Background(), test.url)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var policyErr *urlpolicy.PolicyError
+			require.True(t, errors.As(err, &policyErr))
+			assert.EqualError(t, policyErr, test.expectedErr)
+		})
+	}
+}
+
+func TestDocumentMetadata(t *testing.T) {
+	doc := fetcher.Document{
+		Format:      fetcher.FormatRSS,
+		Title:       strings.Repeat("é", 300),
+		Description: "Description",
+		Language:    "en_US",
+		SiteURL:     "https://example.com/" + strings.Repeat("a", 250),
+		IconURL:     "https://example.com/icon.png",
+	}
+
+	assert.Equal(t, entities.FeedMetadata{
+		Title:       strings.Repeat("é", 250),
+		Description: "Description",
+		Language:    "en-us",
+		IconURL:     "https://example.com/icon.png",
+	}, doc.Metadata())
+}
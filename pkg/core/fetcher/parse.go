@@ -0,0 +1,247 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+)
+
+// Feed formats supported.
+const (
+	FormatRSS      = "rss"
+	FormatAtom     = "atom"
+	FormatJSONFeed = "json"
+)
+
+// atomNamespace is the XML namespace of Atom documents.
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// jsonFeedVersionPrefix prefixes the version of every JSON Feed document.
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+// Document holds the details of a feed document.
+type Document struct {
+	// Format is one of the Format* constants.
+	Format      string
+	Title       string
+	Description string
+	Language    string
+	// SiteURL is the link to the website the feed belongs to, as found in the document.
+	SiteURL string
+	// IconURL is the link to the feed icon or logo, as found in the document.
+	IconURL string
+}
+
+// Metadata returns the feed metadata in the document.
+// Language tags are lowercased, and texts longer than the feed metadata fields are truncated. Links are dropped
+// instead.
+func (d Document) Metadata() entities.FeedMetadata {
+	language := strings.ToLower(strings.ReplaceAll(d.Language, "_", "-"))
+
+	return entities.FeedMetadata{
+		Title:       truncate(d.Title, entities.MaxFeedTitleLength),
+		Description: truncate(d.Description, entities.MaxFeedDescriptionLength),
+		Language:    truncate(language, entities.MaxFeedLanguageLength),
+		SiteURL:     dropLong(d.SiteURL, entities.MaxFeedLinkLength),
+		IconURL:     dropLong(d.IconURL, entities.MaxFeedLinkLength),
+	}
+}
+
+// truncate returns the first maxLength characters of s.
+func truncate(s string, maxLength int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLength {
+		return s
+	}
+	return string(runes[:maxLength])
+}
+
+// dropLong returns s if it is at most maxLength characters long, or an empty string otherwise.
+func dropLong(s string, maxLength int) string {
+	if len([]rune(s)) > maxLength {
+		return ""
+	}
+	return s
+}
+
+// Parse parses a feed document in RSS 2.0, Atom or JSON Feed format.
+// RSS 0.91 and 0.92 documents are accepted as well, as RSS 2.0 is compatible with them.
+func Parse(body []byte) (Document, error) {
+	body = bytes.TrimLeft(body, " \t\r\n\ufeff")
+	if len(body) == 0 {
+		return Document{}, fmt.Errorf("document is empty")
+	}
+
+	if body[0] == '{' {
+		return parseJSONFeed(body)
+	}
+	return parseXMLFeed(body)
+}
+
+type rssDocument struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel *struct {
+		Title string `xml:"title"`
+		// Links holds the channel link, along with the links in other namespaces (e.g. 'atom:link').
+		Links []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:"link"`
+		Description string `xml:"description"`
+		Language    string `xml:"language"`
+		Image       struct {
+			URL string `xml:"url"`
+		} `xml:"image"`
+	} `xml:"channel"`
+}
+
+type atomDocument struct {
+	XMLName  xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang     string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Title    string   `xml:"title"`
+	Subtitle string   `xml:"subtitle"`
+	Links    []struct {
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Icon string `xml:"icon"`
+	Logo string `xml:"logo"`
+}
+
+type jsonFeedDocument struct {
+	Version     string            `json:"version"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Language    string            `json:"language"`
+	HomePageURL string            `json:"home_page_url"`
+	Icon        string            `json:"icon"`
+	Favicon     string            `json:"favicon"`
+	Items       []json.RawMessage `json:"items"`
+}
+
+func parseXMLFeed(body []byte) (Document, error) {
+	// The root element is found leniently, so that HTML pages can be told apart from broken XML
+	decoder := newXMLDecoder(body)
+	decoder.Strict = false
+
+	var root xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return Document{}, fmt.Errorf("document has no root element")
+		} else if err != nil {
+			return Document{}, fmt.Errorf("error parsing XML document: %w", err)
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+
+	switch {
+	case root.Name.Local == "rss":
+		var doc rssDocument
+		if err := newXMLDecoder(body).Decode(&doc); err != nil {
+			return Document{}, fmt.Errorf("error parsing RSS document: %w", err)
+		}
+
+		if doc.Version != "2.0" && doc.Version != "0.91" && doc.Version != "0.92" {
+			return Document{}, fmt.Errorf("unsupported RSS version <%s>", doc.Version)
+		}
+		if doc.Channel == nil {
+			return Document{}, fmt.Errorf("RSS document has no channel")
+		}
+
+		feedDoc := Document{
+			Format:      FormatRSS,
+			Title:       strings.TrimSpace(doc.Channel.Title),
+			Description: strings.TrimSpace(doc.Channel.Description),
+			Language:    strings.TrimSpace(doc.Channel.Language),
+			IconURL:     strings.TrimSpace(doc.Channel.Image.URL),
+		}
+		for _, link := range doc.Channel.Links {
+			if link.XMLName.Space == "" {
+				feedDoc.SiteURL = strings.TrimSpace(link.Value)
+				break
+			}
+		}
+		return feedDoc, nil
+
+	case root.Name.Local == "feed" && root.Name.Space == atomNamespace:
+		var doc atomDocument
+		if err := newXMLDecoder(body).Decode(&doc); err != nil {
+			return Document{}, fmt.Errorf("error parsing Atom document: %w", err)
+		}
+
+		feedDoc := Document{
+			Format:      FormatAtom,
+			Title:       strings.TrimSpace(doc.Title),
+			Description: strings.TrimSpace(doc.Subtitle),
+			Language:    strings.TrimSpace(doc.Lang),
+			IconURL:     strings.TrimSpace(doc.Icon),
+		}
+		if feedDoc.IconURL == "" {
+			feedDoc.IconURL = strings.TrimSpace(doc.Logo)
+		}
+		for _, link := range doc.Links {
+			if (link.Rel == "" || link.Rel == "alternate") && (link.Type == "" || link.Type == "text/html") {
+				feedDoc.SiteURL = strings.TrimSpace(link.Href)
+				break
+			}
+		}
+		return feedDoc, nil
+
+	case strings.EqualFold(root.Name.Local, "html"):
+		return Document{}, fmt.Errorf("document is an HTML page")
+
+	default:
+		return Document{}, fmt.Errorf("unsupported document with root element <%s>", root.Name.Local)
+	}
+}
+
+// newXMLDecoder returns a decoder reading the body provided.
+//
+// Documents in any charset are read as is. Element names are ASCII in every charset feeds are found in, and the
+// text in them is not used for validation.
+func newXMLDecoder(body []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+func parseJSONFeed(body []byte) (Document, error) {
+	var doc jsonFeedDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return Document{}, fmt.Errorf("error parsing JSON document: %w", err)
+	}
+
+	if !strings.HasPrefix(doc.Version, jsonFeedVersionPrefix) {
+		return Document{}, fmt.Errorf("JSON document is not a JSON Feed")
+	}
+	if doc.Items == nil {
+		return Document{}, fmt.Errorf("JSON Feed document has no items")
+	}
+
+	feedDoc := Document{
+		Format:      FormatJSONFeed,
+		Title:       strings.TrimSpace(doc.Title),
+		Description: strings.TrimSpace(doc.Description),
+		Language:    strings.TrimSpace(doc.Language),
+		SiteURL:     strings.TrimSpace(doc.HomePageURL),
+		IconURL:     strings.TrimSpace(doc.Icon),
+	}
+	if feedDoc.IconURL == "" {
+		feedDoc.IconURL = strings.TrimSpace(doc.Favicon)
+	}
+	return feedDoc, nil
+}
@@ -0,0 +1,151 @@
+package formats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+)
+
+// CSVContentType is the MIME type of CSV documents.
+const CSVContentType = "text/csv"
+
+// csvHeader holds the columns written by CSVWriter.
+var csvHeader = []string{"id", "url", "provider", "category", "enabled", "title", "description", "language",
+	"site_url", "icon_url", "created_at", "updated_at"}
+
+// CSVWriter writes feeds as CSV rows, preceded by a header row.
+type CSVWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter returns a CSVWriter writing to w.
+func NewCSVWriter(w io.Writer) FeedWriter {
+	return &CSVWriter{writer: csv.NewWriter(w)}
+}
+
+// Write writes the feed as a CSV row. The header row is written before the first feed.
+func (cw *CSVWriter) Write(feed entities.Feed) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	return cw.writer.Write([]string{
+		feed.ID,
+		feed.URL,
+		feed.Provider,
+		feed.Category,
+		strconv.FormatBool(feed.Enabled),
+		feed.Title,
+		feed.Description,
+		feed.Language,
+		feed.SiteURL,
+		feed.IconURL,
+		feed.CreatedAt.UTC().Format(time.RFC3339),
+		feed.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// Flush writes any buffered rows to the underlying writer.
+// The header row is written even if no feeds were.
+func (cw *CSVWriter) Flush() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+func (cw *CSVWriter) writeHeader() error {
+	if cw.headerWritten {
+		return nil
+	}
+
+	cw.headerWritten = true
+	return cw.writer.Write(csvHeader)
+}
+
+// ParseCSV parses the feeds in a CSV document.
+//
+// The first row must be a header naming the columns. The 'url' column is required, while the 'provider',
+// 'category' and 'enabled' columns are optional. Any other columns are ignored, so that exported documents can be
+// imported back. Empty providers and categories are set to the defaults provided, and feeds are enabled unless
+// stated otherwise.
+// Feeds are returned in the order they appear in the document.
+func ParseCSV(r io.Reader, defaultProvider string, defaultCategory string) (entities.Feeds, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("error parsing CSV document: missing header")
+	} else if err != nil {
+		return nil, fmt.Errorf("error parsing CSV document: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns["url"]; !ok {
+		return nil, fmt.Errorf("error parsing CSV document: missing 'url' column")
+	}
+
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	feeds := entities.Feeds{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing CSV document: %w", err)
+		}
+
+		enabled, err := parseEnabled(column(record, "enabled"))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing CSV document: row %d: %w", row, err)
+		}
+
+		feed := entities.Feed{
+			URL:      column(record, "url"),
+			Provider: column(record, "provider"),
+			Category: column(record, "category"),
+			Enabled:  enabled,
+		}
+		if feed.Provider == "" {
+			feed.Provider = defaultProvider
+		}
+		if feed.Category == "" {
+			feed.Category = defaultCategory
+		}
+
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, nil
+}
+
+// parseEnabled parses the state of an imported feed. Feeds are enabled when the state is empty.
+func parseEnabled(value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid enabled value <%s>", value)
+	}
+	return enabled, nil
+}
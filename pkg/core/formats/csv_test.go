@@ -0,0 +1,94 @@
+package formats_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/formats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVWriter(t *testing.T) {
+	createdAt := time.Date(2021, 3, 14, 10, 30, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	writer := formats.NewCSVWriter(&buf)
+	err := writer.Write(entities.Feed{
+		ID:       "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f01",
+		URL:      "http://feeds.bbci.co.uk/news/uk/rss.xml",
+		Provider: "BBC News",
+		Category: "UK, Local",
+		Enabled:  true,
+		FeedMetadata: entities.FeedMetadata{
+			Title:    "BBC News - UK",
+			Language: "en-gb",
+			SiteURL:  "https://www.bbc.co.uk/news/",
+		},
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, writer.Flush())
+
+	assert.Equal(t, "id,url,provider,category,enabled,title,description,language,site_url,icon_url,"+
+		"created_at,updated_at\n"+
+		"0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f01,http://feeds.bbci.co.uk/news/uk/rss.xml,BBC News,\"UK, Local\",true,"+
+		"BBC News - UK,,en-gb,https://www.bbc.co.uk/news/,,2021-03-14T10:30:00Z,2021-03-14T11:30:00Z\n", buf.String())
+
+	// Whatever is exported can be imported back
+	feeds, err := formats.ParseCSV(&buf, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, entities.Feeds{
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK, Local", Enabled: true},
+	}, feeds)
+
+	// The header is written even without feeds
+	buf.Reset()
+	writer = formats.NewCSVWriter(&buf)
+	require.NoError(t, writer.Flush())
+	assert.Equal(t, "id,url,provider,category,enabled,title,description,language,site_url,icon_url,"+
+		"created_at,updated_at\n", buf.String())
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := map[string]struct {
+		doc           string
+		expectedFeeds entities.Feeds
+		expectedErr   bool
+	}{
+		"columns in any order": {
+			doc: "Category,URL,Enabled\n" +
+				"Tech,https://example.com/tech.xml,false\n" +
+				",https://example.com/rss.xml,\n",
+			expectedFeeds: entities.Feeds{
+				{URL: "https://example.com/tech.xml", Provider: "Imported", Category: "Tech", Enabled: false},
+				{URL: "https://example.com/rss.xml", Provider: "Imported", Category: "General", Enabled: true},
+			},
+		},
+		"only header": {
+			doc:           "url,provider,category\n",
+			expectedFeeds: entities.Feeds{},
+		},
+		"empty document":    {doc: "", expectedErr: true},
+		"missing url":       {doc: "provider,category\nBBC News,UK\n", expectedErr: true},
+		"invalid enabled":   {doc: "url,enabled\nhttps://example.com/rss.xml,maybe\n", expectedErr: true},
+		"wrong field count": {doc: "url,provider\nhttps://example.com/rss.xml\n", expectedErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			feeds, err := formats.ParseCSV(strings.NewReader(test.doc), "Imported", "General")
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedFeeds, feeds)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+// Package formats provides encoders and decoders for the formats feeds can be exported to and imported from.
+package formats
+
+import "github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+
+// FeedWriter writes feeds one at a time, so that they can be exported without holding them all in memory.
+type FeedWriter interface {
+	Write(feed entities.Feed) error
+	// Flush writes any buffered data to the underlying writer. It must be called after the last feed is written.
+	Flush() error
+}
@@ -0,0 +1,84 @@
+package formats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+)
+
+// NDJSONContentType is the MIME type of newline delimited JSON documents.
+const NDJSONContentType = "application/x-ndjson"
+
+// NDJSONWriter writes feeds as JSON objects, one per line.
+type NDJSONWriter struct {
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewNDJSONWriter returns an NDJSONWriter writing to w.
+func NewNDJSONWriter(w io.Writer) FeedWriter {
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	return &NDJSONWriter{buf: buf, encoder: encoder}
+}
+
+// Write writes the feed as a JSON object, followed by a newline.
+func (nw *NDJSONWriter) Write(feed entities.Feed) error {
+	return nw.encoder.Encode(feed)
+}
+
+// Flush writes any buffered lines to the underlying writer.
+func (nw *NDJSONWriter) Flush() error {
+	return nw.buf.Flush()
+}
+
+// ParseNDJSON parses the feeds in a newline delimited JSON document, with one feed object per line.
+//
+// Objects hold the 'url', 'provider', 'category', 'enabled' and 'poll_interval' fields, as in exported documents.
+// Any other fields are ignored. Empty providers and categories are set to the defaults provided, and feeds are enabled unless
+// stated otherwise.
+// Feeds are returned in the order they appear in the document.
+func ParseNDJSON(r io.Reader, defaultProvider string, defaultCategory string) (entities.Feeds, error) {
+	decoder := json.NewDecoder(r)
+
+	feeds := entities.Feeds{}
+	for object := 1; ; object++ {
+		record := struct {
+			URL      string `json:"url"`
+			Provider string `json:"provider"`
+			Category string `json:"category"`
+			Enabled  *bool  `json:"enabled"`
+			// PollInterval is in seconds
+			PollInterval int64 `json:"poll_interval"`
+		}{}
+
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error parsing NDJSON document: object %d: %w", object, err)
+		}
+
+		feed := entities.Feed{
+			URL:          record.URL,
+			Provider:     record.Provider,
+			Category:     record.Category,
+			Enabled:      record.Enabled == nil || *record.Enabled,
+			PollInterval: record.PollInterval,
+		}
+		if feed.Provider == "" {
+			feed.Provider = defaultProvider
+		}
+		if feed.Category == "" {
+			feed.Category = defaultCategory
+		}
+
+		feeds = append(feeds, feed)
+	}
+
+	return feeds, nil
+}
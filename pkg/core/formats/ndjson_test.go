@@ -0,0 +1,71 @@
+package formats_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/formats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	createdAt := time.Date(2021, 3, 14, 10, 30, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	writer := formats.NewNDJSONWriter(&buf)
+	for _, url := range []string{"http://feeds.bbci.co.uk/news/uk/rss.xml", "https://example.com/rss?a=1&b=2"} {
+		err := writer.Write(entities.Feed{
+			ID:       "0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f01",
+			URL:      url,
+			Provider: "BBC News",
+			Category: "UK",
+			FeedMetadata: entities.FeedMetadata{
+				Title:    "BBC News - UK",
+				Language: "en-gb",
+			},
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"id":"0b9cd7e6-4a44-4d62-9c64-8e2f4a1c0f01","url":"https://example.com/rss?a=1&b=2","canonical_url":"",`+
+		`"provider":"BBC News","category":"UK","enabled":false,"title":"BBC News - UK","description":"",`+
+		`"language":"en-gb","site_url":"","icon_url":"",`+
+		`"fetch_state":{"etag":"","last_modified":"","content_hash":"","update_frequency":0},`+
+		`"poll_interval":0,"next_fetch_at":"0001-01-01T00:00:00Z",`+
+		`"created_at":"2021-03-14T10:30:00Z","updated_at":"2021-03-14T10:30:00Z"}`, lines[1])
+
+	// Whatever is exported can be imported back
+	feeds, err := formats.ParseNDJSON(&buf, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, entities.Feeds{
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK", Enabled: false},
+		{URL: "https://example.com/rss?a=1&b=2", Provider: "BBC News", Category: "UK", Enabled: false},
+	}, feeds)
+}
+
+func TestParseNDJSON(t *testing.T) {
+	doc := `{"url": "https://example.com/tech.xml", "category": "Tech", "enabled": false}
+
+{"url": "https://example.com/rss.xml", "provider": "Example", "poll_interval": 3600}
+`
+
+	feeds, err := formats.ParseNDJSON(strings.NewReader(doc), "Imported", "General")
+	require.NoError(t, err)
+	assert.Equal(t, entities.Feeds{
+		{URL: "https://example.com/tech.xml", Provider: "Imported", Category: "Tech", Enabled: false},
+		{URL: "https://example.com/rss.xml", Provider: "Example", Category: "General", Enabled: true,
+			PollInterval: 3600},
+	}, feeds)
+
+	_, err = formats.ParseNDJSON(strings.NewReader(`{"url": "https://example.com/rss.xml"}`+"\nnot json\n"), "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package formats
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+)
+
+// OPMLContentType is the MIME type of OPML documents.
+const OPMLContentType = "text/x-opml"
+
+// opmlDocument represents an OPML 2.0 document.
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// opmlOutline represents either a feed, when it has an 'xmlUrl' attribute, or a group of outlines.
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	Provider string        `xml:"provider,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// OPMLWriter writes feeds as an OPML document, with one outline per category holding the feeds in it.
+// Feeds must be written sorted by category.
+type OPMLWriter struct {
+	w       io.Writer
+	encoder *xml.Encoder
+	title   string
+	started bool
+	// category is the name of the category outline open, if any
+	category     string
+	categoryOpen bool
+}
+
+// NewOPMLWriter returns an OPMLWriter writing a document with the title provided to w.
+func NewOPMLWriter(w io.Writer, title string) FeedWriter {
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &OPMLWriter{w: w, encoder: encoder, title: title}
+}
+
+// Write writes the feed as an outline, within the outline of its category. The document head is written before
+// the first feed.
+func (ow *OPMLWriter) Write(feed entities.Feed) error {
+	if err := ow.writeHead(); err != nil {
+		return err
+	}
+
+	if !ow.categoryOpen || ow.category != feed.Category {
+		if err := ow.closeCategory(); err != nil {
+			return err
+		}
+
+		start := xml.StartElement{Name: xml.Name{Local: "outline"}, Attr: []xml.Attr{
+			{Name: xml.Name{Local: "text"}, Value: feed.Category},
+			{Name: xml.Name{Local: "title"}, Value: feed.Category},
+		}}
+		if err := ow.encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		ow.category, ow.categoryOpen = feed.Category, true
+	}
+
+	outline := opmlOutline{Text: feed.URL, Type: "rss", XMLURL: feed.URL, Provider: feed.Provider}
+	return ow.encoder.EncodeElement(outline, xml.StartElement{Name: xml.Name{Local: "outline"}})
+}
+
+// Flush closes the document and writes it to the underlying writer.
+func (ow *OPMLWriter) Flush() error {
+	if err := ow.writeHead(); err != nil {
+		return err
+	}
+	if err := ow.closeCategory(); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"body", "opml"} {
+		if err := ow.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	if err := ow.encoder.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(ow.w, "\n")
+	return err
+}
+
+// writeHead writes the XML declaration, the document head and opens the body, unless already written.
+func (ow *OPMLWriter) writeHead() error {
+	if ow.started {
+		return nil
+	}
+	ow.started = true
+
+	if _, err := io.WriteString(ow.w, xml.Header); err != nil {
+		return err
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: "opml"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "version"}, Value: "2.0"}}}
+	if err := ow.encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	head := opmlHead{Title: ow.title, DateCreated: time.Now().UTC().Format(time.RFC1123Z)}
+	if err := ow.encoder.EncodeElement(head, xml.StartElement{Name: xml.Name{Local: "head"}}); err != nil {
+		return err
+	}
+
+	return ow.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: "body"}})
+}
+
+// closeCategory closes the category outline open, if any.
+func (ow *OPMLWriter) closeCategory() error {
+	if !ow.categoryOpen {
+		return nil
+	}
+	ow.categoryOpen = false
+	return ow.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "outline"}})
+}
+
+// ParseOPML parses the feeds in an OPML document.
+//
+// The category of a feed is the text of the outline it is nested in, and its provider is taken from the
+// 'provider' attribute. When missing, they are set to the defaults provided. Feeds are always enabled.
+// Feeds are returned in the order they appear in the document.
+func ParseOPML(r io.Reader, defaultProvider string, defaultCategory string) (entities.Feeds, error) {
+	var doc opmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error parsing OPML document: %w", err)
+	}
+
+	feeds := entities.Feeds{}
+	collectOPMLFeeds(doc.Body.Outlines, defaultProvider, defaultCategory, &feeds)
+	return feeds, nil
+}
+
+// collectOPMLFeeds appends the feeds found in the outlines, and in the outlines nested in them, to feeds.
+func collectOPMLFeeds(outlines []opmlOutline, provider string, category string, feeds *entities.Feeds) {
+	for _, outline := range outlines {
+		if outline.XMLURL == "" {
+			groupCategory := outline.Text
+			if groupCategory == "" {
+				groupCategory = outline.Title
+			}
+			if groupCategory == "" {
+				groupCategory = category
+			}
+
+			collectOPMLFeeds(outline.Outlines, provider, groupCategory, feeds)
+			continue
+		}
+
+		feed := entities.Feed{URL: outline.XMLURL, Provider: outline.Provider, Category: category, Enabled: true}
+		if feed.Provider == "" {
+			feed.Provider = provider
+		}
+
+		*feeds = append(*feeds, feed)
+	}
+}
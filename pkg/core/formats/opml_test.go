@@ -0,0 +1,75 @@
+package formats_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/formats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOPMLWriter(t *testing.T) {
+	feeds := entities.Feeds{
+		{URL: "http://feeds.bbci.co.uk/news/technology/rss.xml", Provider: "BBC News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.skynews.com/feeds/rss/technology.xml", Provider: "Sky News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK", Enabled: true},
+	}
+
+	var buf bytes.Buffer
+	writer := formats.NewOPMLWriter(&buf, "News feeds")
+	for _, feed := range feeds {
+		require.NoError(t, writer.Write(feed))
+	}
+	require.NoError(t, writer.Flush())
+
+	output := buf.String()
+	assert.True(t, strings.HasPrefix(output, `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, output, `<title>News feeds</title>`)
+	assert.Equal(t, 1, strings.Count(output, `<outline text="Technology" title="Technology">`))
+	assert.Equal(t, 1, strings.Count(output, `<outline text="UK" title="UK">`))
+	assert.Contains(t, output, `<outline text="http://feeds.bbci.co.uk/news/uk/rss.xml" type="rss" `+
+		`xmlUrl="http://feeds.bbci.co.uk/news/uk/rss.xml" provider="BBC News"></outline>`)
+
+	// Whatever is exported can be imported back
+	parsedFeeds, err := formats.ParseOPML(&buf, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, feeds, parsedFeeds)
+
+	// The document is complete even without feeds
+	buf.Reset()
+	writer = formats.NewOPMLWriter(&buf, "News feeds")
+	require.NoError(t, writer.Flush())
+	parsedFeeds, err = formats.ParseOPML(&buf, "", "")
+	require.NoError(t, err)
+	assert.Empty(t, parsedFeeds)
+}
+
+func TestParseOPML(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="Tech" title="Tech">
+      <outline type="rss" text="BBC Technology" xmlUrl="http://feeds.bbci.co.uk/news/technology/rss.xml" provider="BBC News"/>
+      <outline text="Blogs">
+        <outline type="rss" text="Some blog" xmlUrl="https://blog.example.com/feed"/>
+      </outline>
+    </outline>
+    <outline type="rss" text="Uncategorised" xmlUrl="https://example.com/rss.xml"/>
+  </body>
+</opml>`
+
+	feeds, err := formats.ParseOPML(strings.NewReader(doc), "Imported", "General")
+	require.NoError(t, err)
+	assert.Equal(t, entities.Feeds{
+		{URL: "http://feeds.bbci.co.uk/news/technology/rss.xml", Provider: "BBC News", Category: "Tech", Enabled: true},
+		{URL: "https://blog.example.com/feed", Provider: "Imported", Category: "Blogs", Enabled: true},
+		{URL: "https://example.com/rss.xml", Provider: "Imported", Category: "General", Enabled: true},
+	}, feeds)
+
+	_, err = formats.ParseOPML(strings.NewReader("not opml"), "", "")
+	assert.Error(t, err)
+}
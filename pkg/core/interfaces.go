@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"time"
 
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
 )
@@ -9,10 +10,39 @@ import (
 // Repository represents a database holding the data
 type Repository interface {
 	HealthCheck() error
-	GetFeeds(provider string, category string, enabled bool) (feeds entities.Feeds, err error)
-	AddFeed(feed entities.Feed) (err error)
-	SetFeedState(url string, enabled bool) (err error)
-	DeleteFeed(url string) (err error)
+	GetFeeds(query entities.FeedQuery) (page entities.FeedsPage, err error)
+	IterateFeeds(query entities.FeedQuery, fn func(feed entities.Feed) error) (err error)
+	GetFeed(id string) (feed entities.Feed, err error)
+	GetFeedByURL(canonicalURL string) (feed entities.Feed, err error)
+	AddFeed(feed entities.Feed, actor entities.Actor) (created entities.Feed, err error)
+	AddFeeds(feeds entities.Feeds, opts entities.FeedBatchOptions, actor entities.Actor) (results entities.FeedBatchResults,
+		err error)
+	SetFeedState(id string, enabled bool, actor entities.Actor) (err error)
+	SetFeedFetchState(id string, state entities.FeedFetchState, nextFetchAt time.Time) (err error)
+	LeaseDueFeeds(limit int, leaseTimeout time.Duration) (feeds entities.Feeds, err error)
+	UpdateFeed(id string, update entities.FeedUpdate, actor entities.Actor) (feed entities.Feed, err error)
+	DeleteFeed(id string, actor entities.Actor) (err error)
+	RestoreFeed(id string, actor entities.Actor) (feed entities.Feed, err error)
+	PurgeDeletedFeeds(deletedBefore time.Time, limit int, actor entities.Actor) (purged int, err error)
+	GetFeedHealth(id string) (health entities.FeedHealth, err error)
+	RecordFeedCheck(id string, check entities.FeedCheck) (health entities.FeedHealth, err error)
+
+	GetProviders() (providers entities.Providers, err error)
+	AddProvider(name string) (provider entities.Provider, err error)
+	RenameProvider(id uint64, name string) (err error)
+	DeleteProvider(id uint64, cascade bool, actor entities.Actor) (err error)
+
+	GetCategories() (categories entities.Categories, err error)
+	AddCategory(name string) (category entities.Category, err error)
+	RenameCategory(id uint64, name string) (err error)
+	DeleteCategory(id uint64, cascade bool, actor entities.Actor) (err error)
+
+	GetAPIKeys() (keys entities.APIKeys, err error)
+	GetAPIKeyByHash(keyHash string) (key entities.APIKey, err error)
+	AddAPIKey(key entities.APIKey, keyHash string) (created entities.APIKey, err error)
+	DeleteAPIKey(id string) (err error)
+
+	GetAuditEntries(query entities.AuditQuery) (page entities.AuditPage, err error)
 }
 
 // ShutDowner represents anything that can be shutdown like an HTTP server.
@@ -0,0 +1,296 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Defaults used when fetching key sets.
+const (
+	DefaultFetchTimeout = 10 * time.Second
+	// DefaultMaxAge is the time keys fetched are used for before being fetched again.
+	DefaultMaxAge = time.Hour
+	// DefaultMinRefreshInterval is the minimum time between fetches caused by tokens signed with unknown keys, so that
+	// they can't be used to flood the key set URL.
+	DefaultMinRefreshInterval = time.Minute
+)
+
+// maxKeySetSize is the maximum size in bytes of the key sets fetched.
+const maxKeySetSize = 1 << 20
+
+// KeySet holds the keys of a JWKS document. Keys that are not meant for signatures, or of types not supported, are
+// left out.
+type KeySet struct {
+	Keys []Key
+}
+
+// Key returns the key with the ID provided. If the ID is empty, the key is only returned when there is a single key.
+func (ks *KeySet) Key(ctx context.Context, kid string) (Key, error) {
+	if kid == "" {
+		if len(ks.Keys) == 1 {
+			return ks.Keys[0], nil
+		}
+		return Key{}, &ValidationError{Msg: "token has no key ID"}
+	}
+
+	for _, key := range ks.Keys {
+		if key.ID == kid {
+			return key, nil
+		}
+	}
+	return Key{}, &ValidationError{Msg: fmt.Sprintf("key <%s> not found", kid)}
+}
+
+// jsonWebKey holds the fields of the JSON Web Keys supported.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseKeySet parses a JWKS document. It fails if there are no keys that can verify signatures.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	document := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("error parsing key set: %w", err)
+	}
+
+	ks := &KeySet{}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, ok, err := parseKey(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key <%s>: %w", jwk.Kid, err)
+		} else if ok {
+			ks.Keys = append(ks.Keys, key)
+		}
+	}
+
+	if len(ks.Keys) == 0 {
+		return nil, errors.New("error parsing key set: no signing keys found")
+	}
+	return ks, nil
+}
+
+// LoadKeySetFile reads and parses a JWKS file.
+func LoadKeySetFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeySet(data)
+}
+
+// parseKey converts a JSON Web Key into a Key. Keys of types not supported are skipped.
+func parseKey(jwk jsonWebKey) (key Key, ok bool, err error) {
+	key = Key{ID: jwk.Kid, Algorithm: jwk.Alg}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return key, false, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return key, false, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return key, false, errors.New("exponent too large")
+		}
+		key.PublicKey = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return key, false, nil
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return key, false, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return key, false, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return key, false, errors.New("point not on curve")
+		}
+		key.PublicKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	default:
+		return key, false, nil
+	}
+
+	return key, true, nil
+}
+
+// decodeBigInt decodes a base64url encoded big-endian integer.
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// RemoteKeySet fetches the key set at a URL, such as the 'jwks_uri' of an OIDC provider, and caches it.
+// Keys are fetched again once they're older than MaxAge, or when tokens are signed with unknown keys, so that keys
+// rotated by the provider are picked up.
+// Fetches are made in the background, and callers needing the keys at the same time wait for the same fetch.
+// It is safe for concurrent use.
+type RemoteKeySet struct {
+	URL    string
+	Client *http.Client
+	MaxAge time.Duration
+	// MinRefreshInterval is the minimum time between fetches, whether they succeeded or not, other than the first one.
+	// It keeps tokens signed with unknown keys from flooding the key set URL, and backs off when fetches fail.
+	MinRefreshInterval time.Duration
+
+	mu   sync.Mutex
+	keys *KeySet
+	// fetchedAt is when keys were fetched, and attemptedAt when the latest fetch started, successful or not
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// fetchErr is the error of the latest fetch, if it failed
+	fetchErr error
+	// fetching is closed once the fetch in progress, if any, is done
+	fetching chan struct{}
+}
+
+// NewRemoteKeySet returns a new RemoteKeySet for the URL provided, with the default settings.
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		URL:                url,
+		Client:             &http.Client{Timeout: DefaultFetchTimeout},
+		MaxAge:             DefaultMaxAge,
+		MinRefreshInterval: DefaultMinRefreshInterval,
+	}
+}
+
+// Key returns the key with the ID provided, fetching the key set if needed.
+func (rks *RemoteKeySet) Key(ctx context.Context, kid string) (Key, error) {
+	keys, err := rks.keySet(ctx, false)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key, err := keys.Key(ctx, kid)
+	if _, ok := err.(*ValidationError); ok {
+		// The key may have been rotated since
+		if refreshed, fetchErr := rks.keySet(ctx, true); fetchErr == nil && refreshed != keys {
+			return refreshed.Key(ctx, kid)
+		}
+	}
+
+	return key, err
+}
+
+// keySet returns the key set, fetching it first if there is none yet, if it's older than MaxAge, or if refresh is
+// true. Keys that can't be fetched again are used until they can.
+func (rks *RemoteKeySet) keySet(ctx context.Context, refresh bool) (*KeySet, error) {
+	rks.mu.Lock()
+	due := refresh || rks.keys == nil || time.Since(rks.fetchedAt) >= rks.MaxAge
+	if due && rks.fetching == nil && !rks.attemptedAt.IsZero() &&
+		time.Since(rks.attemptedAt) < rks.MinRefreshInterval {
+		due = false
+	}
+
+	if !due {
+		keys, err := rks.keys, rks.fetchErr
+		rks.mu.Unlock()
+		if keys == nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+
+	fetching := rks.fetching
+	if fetching == nil {
+		fetching = make(chan struct{})
+		rks.fetching = fetching
+		rks.attemptedAt = time.Now()
+		go rks.fetch(fetching)
+	}
+	rks.mu.Unlock()
+
+	select {
+	case <-fetching:
+	case <-ctx.Done():
+	}
+
+	rks.mu.Lock()
+	defer rks.mu.Unlock()
+	if rks.keys != nil {
+		return rks.keys, nil
+	} else if rks.fetchErr != nil {
+		return nil, rks.fetchErr
+	}
+	return nil, fmt.Errorf("error fetching key set: %w", ctx.Err())
+}
+
+// fetch fetches the key set and stores the outcome, closing done afterwards.
+// It isn't bound to the context of any caller, as all the callers waiting for it need the outcome.
+func (rks *RemoteKeySet) fetch(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultFetchTimeout)
+	defer cancel()
+	keys, err := rks.download(ctx)
+
+	rks.mu.Lock()
+	if err == nil {
+		rks.keys = keys
+		rks.fetchedAt = time.Now()
+	}
+	rks.fetchErr = err
+	rks.fetching = nil
+	rks.mu.Unlock()
+
+	close(done)
+}
+
+// download fetches and parses the key set.
+func (rks *RemoteKeySet) download(ctx context.Context) (*KeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rks.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rks.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching key set: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("error fetching key set: unexpected status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching key set: %w", err)
+	}
+
+	return ParseKeySet(data)
+}
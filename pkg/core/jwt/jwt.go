@@ -0,0 +1,247 @@
+// Package jwt validates JSON Web Tokens signed with RS256 or ES256, such as the ones issued by OIDC providers,
+// against the keys of a JSON Web Key Set (JWKS).
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Signing algorithms supported.
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+)
+
+// DefaultLeeway is the clock skew allowed by default when checking the time claims of tokens.
+const DefaultLeeway = time.Minute
+
+// ValidationError is returned when a token is not valid.
+type ValidationError struct {
+	Msg string
+}
+
+func (e *ValidationError) Error() string { return "token not valid: " + e.Msg }
+
+// KeyProvider provides the public keys tokens are verified with.
+type KeyProvider interface {
+	// Key returns the key with the ID provided. If the ID is empty, the key is only returned when there is a single
+	// key. It returns a *ValidationError if there is no such key.
+	Key(ctx context.Context, kid string) (Key, error)
+}
+
+// Claims holds the claims of a token, as decoded from JSON.
+type Claims map[string]interface{}
+
+// Subject returns the 'sub' claim.
+func (c Claims) Subject() string {
+	sub, _ := c["sub"].(string)
+	return sub
+}
+
+// Strings returns the values of the claim at the path provided, which may be dot separated to reach nested claims
+// (e.g. 'realm_access.roles'). Claims may hold a string or an array of strings. Values of other types are ignored.
+func (c Claims) Strings(path string) (values []string) {
+	var value interface{} = map[string]interface{}(c)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// time returns the claim holding a NumericDate, as a time.
+func (c Claims) time(name string) (t time.Time, ok bool, err error) {
+	value, ok := c[name]
+	if !ok {
+		return t, false, nil
+	}
+
+	seconds, ok := value.(float64)
+	if !ok {
+		return t, false, &ValidationError{Msg: fmt.Sprintf("claim '%s' is not a number", name)}
+	}
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// Verifier validates tokens.
+type Verifier struct {
+	Keys KeyProvider
+	// Issuer, if not empty, must match the 'iss' claim.
+	Issuer string
+	// Audience, if not empty, must be in the 'aud' claim.
+	Audience string
+	// Leeway is the clock skew allowed when checking the 'exp' and 'nbf' claims.
+	Leeway time.Duration
+}
+
+// NewVerifier returns a new Verifier with the default leeway.
+func NewVerifier(keys KeyProvider, issuer string, audience string) *Verifier {
+	return &Verifier{Keys: keys, Issuer: issuer, Audience: audience, Leeway: DefaultLeeway}
+}
+
+// Verify checks the signature of the token provided, in compact serialization, along with its 'exp', 'nbf', 'iss'
+// and 'aud' claims, and returns its claims. Tokens must expire.
+// Errors caused by the token are of type *ValidationError. Other errors come from the key provider.
+func (v *Verifier) Verify(ctx context.Context, token string) (claims Claims, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &ValidationError{Msg: "malformed token"}
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, &ValidationError{Msg: "malformed header"}
+	}
+
+	// The algorithm is only trusted once it's checked against the key, so tokens can't pick a weaker one
+	if header.Alg != AlgorithmRS256 && header.Alg != AlgorithmES256 {
+		return nil, &ValidationError{Msg: fmt.Sprintf("algorithm <%s> not supported", header.Alg)}
+	}
+
+	key, err := v.Keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &ValidationError{Msg: "malformed signature"}
+	}
+
+	if err := key.verify(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, &ValidationError{Msg: "malformed claims"}
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// validate checks the registered claims of a token whose signature was verified.
+func (v *Verifier) validate(claims Claims) error {
+	now := time.Now()
+
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return err
+	} else if !ok {
+		return &ValidationError{Msg: "token doesn't expire"}
+	} else if !now.Before(exp.Add(v.Leeway)) {
+		return &ValidationError{Msg: "token expired"}
+	}
+
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return err
+	} else if ok && now.Add(v.Leeway).Before(nbf) {
+		return &ValidationError{Msg: "token not valid yet"}
+	}
+
+	if v.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.Issuer {
+			return &ValidationError{Msg: fmt.Sprintf("issuer <%s> not allowed", iss)}
+		}
+	}
+
+	if v.Audience != "" {
+		found := false
+		for _, aud := range claims.Strings("aud") {
+			if aud == v.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &ValidationError{Msg: "audience not allowed"}
+		}
+	}
+
+	return nil
+}
+
+// Key holds a public key along with the algorithm it's meant for, if set.
+type Key struct {
+	ID        string
+	Algorithm string
+	PublicKey crypto.PublicKey
+}
+
+// verify checks the signature of the signing input provided.
+func (k Key) verify(alg string, signingInput string, signature []byte) error {
+	if k.Algorithm != "" && k.Algorithm != alg {
+		return &ValidationError{Msg: fmt.Sprintf("key <%s> is not meant for algorithm <%s>", k.ID, alg)}
+	}
+
+	hash := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case AlgorithmRS256:
+		publicKey, ok := k.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return &ValidationError{Msg: fmt.Sprintf("key <%s> is not an RSA key", k.ID)}
+		}
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hash[:], signature) != nil {
+			return &ValidationError{Msg: "invalid signature"}
+		}
+	case AlgorithmES256:
+		publicKey, ok := k.PublicKey.(*ecdsa.PublicKey)
+		if !ok || publicKey.Curve.Params().Name != "P-256" {
+			return &ValidationError{Msg: fmt.Sprintf("key <%s> is not a P-256 key", k.ID)}
+		}
+		// ES256 signatures are the R and S values, 32 bytes each
+		if len(signature) != 64 {
+			return &ValidationError{Msg: "invalid signature"}
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, hash[:], r, s) {
+			return &ValidationError{Msg: "invalid signature"}
+		}
+	default:
+		return &ValidationError{Msg: fmt.Sprintf("algorithm <%s> not supported", alg)}
+	}
+
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
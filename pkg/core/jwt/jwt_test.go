@@ -0,0 +1,251 @@
+package jwt_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/jwt"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/jwt/jwttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	rsaKey := jwttest.NewRSAKey(t, "rsa-1")
+	ecKey := jwttest.NewECKey(t, "ec-1")
+	otherKey := jwttest.NewRSAKey(t, "rsa-1")
+
+	keys, err := jwt.ParseKeySet(jwttest.KeySet(t, rsaKey, ecKey))
+	require.NoError(t, err)
+	verifier := jwt.NewVerifier(keys, "https://auth.example.com", "feeds-mgmt")
+
+	now := time.Now().Unix()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": "https://auth.example.com",
+			"aud": []string{"feeds-mgmt", "other"},
+			"sub": "jane",
+			"exp": now + 300,
+			"nbf": now - 300,
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+
+	// Tokens with a key ID of a key of another type
+	wrongType := jwttest.SigningKey{ID: "ec-1", Algorithm: jwt.AlgorithmRS256, PrivateKey: rsaKey.PrivateKey}
+
+	valid := rsaKey.Sign(t, claims(nil))
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) +
+		"." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." + parts[1] + "."
+
+	tests := map[string]struct {
+		token         string
+		expectedError string
+	}{
+		"rs256":                 {token: valid},
+		"es256":                 {token: ecKey.Sign(t, claims(nil))},
+		"single audience":       {token: ecKey.Sign(t, claims(map[string]interface{}{"aud": "feeds-mgmt"}))},
+		"expired within leeway": {token: ecKey.Sign(t, claims(map[string]interface{}{"exp": now - 10}))},
+		"expired": {token: ecKey.Sign(t, claims(map[string]interface{}{"exp": now - 3600})),
+			expectedError: "token not valid: token expired"},
+		"no expiry": {token: ecKey.Sign(t, claims(map[string]interface{}{"exp": nil})),
+			expectedError: "token not valid: token doesn't expire"},
+		"not valid yet": {token: ecKey.Sign(t, claims(map[string]interface{}{"nbf": now + 3600})),
+			expectedError: "token not valid: token not valid yet"},
+		"wrong issuer": {token: ecKey.Sign(t, claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+			expectedError: "token not valid: issuer <https://evil.example.com> not allowed"},
+		"wrong audience": {token: ecKey.Sign(t, claims(map[string]interface{}{"aud": "other"})),
+			expectedError: "token not valid: audience not allowed"},
+		"unknown key": {token: jwttest.NewECKey(t, "ec-2").Sign(t, claims(nil)),
+			expectedError: "token not valid: key <ec-2> not found"},
+		"other key with same id": {token: otherKey.Sign(t, claims(nil)),
+			expectedError: "token not valid: invalid signature"},
+		"key of another type": {token: wrongType.Sign(t, claims(nil)),
+			expectedError: "token not valid: key <ec-1> is not meant for algorithm <RS256>"},
+		"tampered claims": {token: tampered, expectedError: "token not valid: invalid signature"},
+		"unsigned":        {token: unsigned, expectedError: "token not valid: algorithm <none> not supported"},
+		"malformed":       {token: "not-a-token", expectedError: "token not valid: malformed token"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), test.token)
+			if test.expectedError != "" {
+				assert.IsType(t, &jwt.ValidationError{}, err)
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "jane", claims.Subject())
+		})
+	}
+}
+
+func TestClaimsStrings(t *testing.T) {
+	claims := jwt.Claims{
+		"roles":        []interface{}{"editor", 42, "viewer"},
+		"group":        "admins",
+		"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}},
+	}
+
+	assert.Equal(t, []string{"editor", "viewer"}, claims.Strings("roles"))
+	assert.Equal(t, []string{"admins"}, claims.Strings("group"))
+	assert.Equal(t, []string{"admin"}, claims.Strings("realm_access.roles"))
+	assert.Empty(t, claims.Strings("realm_access.missing"))
+	assert.Empty(t, claims.Strings("group.roles"))
+}
+
+func TestParseKeySet(t *testing.T) {
+	_, err := jwt.ParseKeySet([]byte(`{"keys": []}`))
+	assert.Error(t, err)
+
+	// Encryption keys and unsupported key types are left out
+	_, err = jwt.ParseKeySet([]byte(`{"keys": [{"kty": "oct", "k": "c2VjcmV0"}, {"kty": "RSA", "use": "enc"}]}`))
+	assert.Error(t, err)
+
+	_, err = jwt.ParseKeySet([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`))
+	assert.EqualError(t, err, "error parsing key <>: point not on curve")
+
+	keys, err := jwt.ParseKeySet(jwttest.KeySet(t, jwttest.NewECKey(t, "")))
+	require.NoError(t, err)
+
+	// Tokens without a key ID are verified with the only key there is
+	_, err = keys.Key(context.Background(), "")
+	assert.NoError(t, err)
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	oldKey := jwttest.NewECKey(t, "old")
+	newKey := jwttest.NewECKey(t, "new")
+
+	var fetches int32
+	var rotated int32
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if atomic.LoadInt32(&rotated) == 1 {
+			w.Write(jwttest.KeySet(t, newKey))
+			return
+		}
+		w.Write(jwttest.KeySet(t, oldKey))
+	}))
+	defer jwksServer.Close()
+
+	keys := jwt.NewRemoteKeySet(jwksServer.URL)
+	keys.MinRefreshInterval = 0
+	verifier := jwt.NewVerifier(keys, "", "")
+	claims := map[string]interface{}{"sub": "jane", "exp": time.Now().Unix() + 300}
+
+	_, err := verifier.Verify(context.Background(), oldKey.Sign(t, claims))
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), oldKey.Sign(t, claims))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&fetches))
+
+	// Tokens signed with an unknown key fetch the key set again
+	atomic.StoreInt32(&rotated, 1)
+	_, err = verifier.Verify(context.Background(), newKey.Sign(t, claims))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&fetches))
+
+	_, err = verifier.Verify(context.Background(), oldKey.Sign(t, claims))
+	assert.IsType(t, &jwt.ValidationError{}, err)
+
+	// Not more than once every MinRefreshInterval
+	keys.MinRefreshInterval = time.Hour
+	fetched := atomic.LoadInt32(&fetches)
+	_, err = verifier.Verify(context.Background(), jwttest.NewECKey(t, "unknown").Sign(t, claims))
+	assert.IsType(t, &jwt.ValidationError{}, err)
+	assert.Equal(t, fetched, atomic.LoadInt32(&fetches))
+}
+
+func TestRemoteKeySetFailures(t *testing.T) {
+	key := jwttest.NewECKey(t, "key")
+
+	var fetches int32
+	var healthy int32
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write(jwttest.KeySet(t, key))
+	}))
+	defer jwksServer.Close()
+
+	keys := jwt.NewRemoteKeySet(jwksServer.URL)
+
+	_, err := keys.Key(context.Background(), "key")
+	assert.Error(t, err)
+
+	// Failed fetches are not retried until MinRefreshInterval has passed
+	atomic.StoreInt32(&healthy, 1)
+	_, err = keys.Key(context.Background(), "key")
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&fetches))
+
+	keys.MinRefreshInterval = 0
+	_, err = keys.Key(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&fetches))
+
+	// Keys that can't be fetched again are still used
+	atomic.StoreInt32(&healthy, 0)
+	keys.MaxAge = 0
+	_, err = keys.Key(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&fetches))
+}
+
+func TestRemoteKeySetConcurrentFetches(t *testing.T) {
+	key := jwttest.NewECKey(t, "key")
+
+	var fetches int32
+	release := make(chan struct{})
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		w.Write(jwttest.KeySet(t, key))
+	}))
+	defer jwksServer.Close()
+
+	keys := jwt.NewRemoteKeySet(jwksServer.URL)
+
+	// Callers needing the keys at the same time wait for the same fetch
+	errs := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := keys.Key(context.Background(), "key")
+			errs <- err
+		}()
+	}
+
+	// Callers giving up don't cancel the fetch for the others
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := keys.Key(ctx, "key")
+	assert.Error(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&fetches))
+}
@@ -0,0 +1,113 @@
+// Package jwttest provides signing keys and token signing for tests of code validating tokens.
+package jwttest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/jwt"
+)
+
+// SigningKey is a private key along with the key ID and algorithm it signs tokens with.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+}
+
+// NewRSAKey generates a 2048 bits RSA key, signing with RS256.
+func NewRSAKey(t *testing.T, kid string) SigningKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SigningKey{ID: kid, Algorithm: jwt.AlgorithmRS256, PrivateKey: privateKey}
+}
+
+// NewECKey generates a P-256 key, signing with ES256.
+func NewECKey(t *testing.T, kid string) SigningKey {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SigningKey{ID: kid, Algorithm: jwt.AlgorithmES256, PrivateKey: privateKey}
+}
+
+// Sign returns a token with the claims provided, in compact serialization.
+func (k SigningKey) Sign(t *testing.T, claims map[string]interface{}) string {
+	header := map[string]interface{}{"alg": k.Algorithm, "typ": "JWT"}
+	if k.ID != "" {
+		header["kid"] = k.ID
+	}
+
+	signingInput := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch privateKey := k.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// KeySet returns the JWKS document holding the public keys of the keys provided.
+func KeySet(t *testing.T, keys ...SigningKey) []byte {
+	jwks := []map[string]string{}
+	for _, k := range keys {
+		jwk := map[string]string{"kid": k.ID, "alg": k.Algorithm, "use": "sig"}
+
+		switch publicKey := k.PrivateKey.Public().(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = encodeBigInt(publicKey.N)
+			jwk["e"] = encodeBigInt(big.NewInt(int64(publicKey.E)))
+		case *ecdsa.PublicKey:
+			jwk["kty"] = "EC"
+			jwk["crv"] = "P-256"
+			jwk["x"] = encodeBigInt(publicKey.X)
+			jwk["y"] = encodeBigInt(publicKey.Y)
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"keys": jwks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+)
+
+// cursorTimeFormat is a fixed width version of time.RFC3339Nano, so that times in UTC can be compared as strings.
+const cursorTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Cursor represents the position of the last feed returned in a page.
+type Cursor struct {
+	// Sort is the sort expression the cursor was generated for.
+	Sort string `json:"s"`
+	// Value is the sort field value of the last feed.
+	Value string `json:"v"`
+	// URL is the URL of the last feed, used to break ties.
+	URL string `json:"u"`
+}
+
+// NewCursor returns the cursor pointing at the feed provided.
+func NewCursor(sort string, feed entities.Feed) Cursor {
+	field, _, _ := ParseFeedSort(sort)
+	return Cursor{Sort: sort, Value: FeedSortValue(feed, field), URL: feed.URL}
+}
+
+// Encode returns the opaque representation of the cursor.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses an opaque cursor generated for the sort expression provided.
+func DecodeCursor(rawCursor string, sort string) (c Cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(rawCursor)
+	if err != nil {
+		return c, &DBInvalidCursorError{}
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return c, &DBInvalidCursorError{}
+	}
+
+	field, _, _ := ParseFeedSort(sort)
+	if field == entities.FeedSortCreatedAt {
+		if _, err := time.Parse(cursorTimeFormat, c.Value); err != nil {
+			return c, &DBInvalidCursorError{}
+		}
+	}
+
+	return c, nil
+}
+
+// ParseFeedSort parses a sort expression (e.g. '-created_at') into its field and direction.
+// An empty expression sorts by URL.
+func ParseFeedSort(sort string) (field string, desc bool, ok bool) {
+	if sort == "" {
+		return entities.FeedSortURL, false, true
+	}
+
+	desc = strings.HasPrefix(sort, "-")
+	field = strings.TrimPrefix(sort, "-")
+
+	switch field {
+	case entities.FeedSortURL, entities.FeedSortProvider, entities.FeedSortCategory, entities.FeedSortCreatedAt:
+		return field, desc, true
+	default:
+		return "", false, false
+	}
+}
+
+// FeedSortValue returns the value of the feed field used for sorting.
+func FeedSortValue(feed entities.Feed, field string) string {
+	switch field {
+	case entities.FeedSortProvider:
+		return feed.Provider
+	case entities.FeedSortCategory:
+		return feed.Category
+	case entities.FeedSortCreatedAt:
+		return feed.CreatedAt.UTC().Format(cursorTimeFormat)
+	default:
+		return feed.URL
+	}
+}
+
+// auditCursor represents the position of the last audit entry returned in a page.
+type auditCursor struct {
+	// ID is the ID of the last entry. Entries are listed by descending ID.
+	ID uint64 `json:"i"`
+}
+
+// EncodeAuditCursor returns the opaque cursor pointing at the audit entry with the ID provided.
+func EncodeAuditCursor(id uint64) string {
+	data, _ := json.Marshal(auditCursor{ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeAuditCursor parses an opaque audit cursor and returns the ID of the entry it points at.
+func DecodeAuditCursor(rawCursor string) (id uint64, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(rawCursor)
+	if err != nil {
+		return 0, &DBInvalidCursorError{}
+	}
+
+	var c auditCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return 0, &DBInvalidCursorError{}
+	}
+
+	return c.ID, nil
+}
@@ -1,36 +1,57 @@
 package repository
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
-	"gorm.io/driver/mysql"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
+// errRecordInUse is returned when deleting a provider or category still referenced by feeds.
+var errRecordInUse = errors.New("record still referenced by feeds")
+
 // Database represents the database manager connecting to the database.
 type Database struct {
-	conn *gorm.DB
+	conn    *gorm.DB
+	dialect dialect
+
+	// IgnoreURLScheme gives 'http' and 'https' URLs the same canonical form, when migrations canonicalize the URLs of
+	// existing feeds.
+	IgnoreURLScheme bool
 }
 
-// NewDatabase returns a new Database.
-func NewDatabase(host string, port int, username string, password string, dbname string) (*Database, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		username, password, host, port, dbname)
+// NewDatabase returns a new Database for the driver ('mysql', 'postgres' or 'sqlite') and DSN provided.
+func NewDatabase(driver string, dsn string) (*Database, error) {
+	d, err := lookupDialect(driver)
+	if err != nil {
+		return nil, err
+	}
 
 	// TODO: Setup logger for gorm here
 	// I should implement GORM's logger interface on core.AppLogger and pass it to gorm config.
 	// I should also pass log level to GORM.
 
-	// dbconn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// dbconn, err := gorm.Open(d.open(dsn), &gorm.Config{})
 	// dbconn = dbconn.Debug()
-	dbconn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	// Timestamps are always stored in UTC so that they sort correctly regardless of the database driver.
+	dbconn, err := gorm.Open(d.open(dsn), &gorm.Config{
+		Logger:  logger.Default.LogMode(logger.Silent),
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	dbconn = dbconn.Session(&gorm.Session{})
-	db := Database{conn: dbconn}
+	db := Database{conn: dbconn, dialect: d, IgnoreURLScheme: core.DefaultIgnoreURLScheme}
 	return &db, nil
 }
 
@@ -44,6 +65,11 @@ func (db *Database) Close() error {
 	return sqlDB.Close()
 }
 
+// IsDuplicateError checks whether the error returned by the database is a unique constraint violation.
+func (db *Database) IsDuplicateError(err error) bool {
+	return db.dialect.isDuplicateError(err)
+}
+
 // HealthCheck checks whether the database is still around.
 func (db *Database) HealthCheck() error {
 	sqlDB, err := db.conn.DB()
@@ -59,73 +85,945 @@ func (db *Database) HealthCheck() error {
 	return nil
 }
 
-// FindAllFeedRecords finds all the feed records with possible filters for 'provider', 'category' and 'enabled'.
-func (db *Database) FindAllFeedRecords(provider string, category string, enabled bool) ([]Feed, error) {
+// FeedRecordFilter holds the filters used to find feed records.
+type FeedRecordFilter struct {
+	Provider string
+	Category string
+	// Language matches the language provided and its regional variants.
+	Language string
+	// Enabled is ignored when nil.
+	Enabled *bool
+	// IncludeDeleted finds soft-deleted records too.
+	IncludeDeleted bool
+}
+
+// FeedRecordPage holds the sorting and pagination parameters used to find feed records.
+type FeedRecordPage struct {
+	// SortField is one of the entities.FeedSort* fields.
+	SortField string
+	SortDesc  bool
+	// After, if not nil, only returns records positioned after the cursor.
+	After *Cursor
+	// Limit is the maximum number of records to return. Zero means no limit.
+	Limit int
+}
+
+// feedSortColumns maps the sort fields to the columns they sort by.
+var feedSortColumns = map[string]clause.Column{
+	entities.FeedSortURL:       {Table: "feeds", Name: "url"},
+	entities.FeedSortProvider:  {Table: "Provider", Name: "name"},
+	entities.FeedSortCategory:  {Table: "Category", Name: "name"},
+	entities.FeedSortCreatedAt: {Table: "feeds", Name: "created_at"},
+}
+
+// FindAllFeedRecords finds all the feed records with optional filters for 'provider', 'category', 'language' and
+// 'enabled', sorted and paginated.
+func (db *Database) FindAllFeedRecords(filter FeedRecordFilter, page FeedRecordPage) ([]Feed, error) {
 	var feedResults []Feed
-	chain := db.conn.Joins("Provider").Joins("Category")
+	chain := db.filterFeedRecords(filter)
 
-	if provider != "" {
-		chain = chain.Where("`Provider`.`name` = ?", provider)
+	sortColumn, ok := feedSortColumns[page.SortField]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort field: %s", page.SortField)
 	}
+	urlColumn := feedSortColumns[entities.FeedSortURL]
 
-	if category != "" {
-		chain = chain.Where("`Category`.`name` = ?", category)
+	if page.After != nil {
+		var sortValue interface{} = page.After.Value
+		if page.SortField == entities.FeedSortCreatedAt {
+			t, err := time.Parse(cursorTimeFormat, page.After.Value)
+			if err != nil {
+				return nil, err
+			}
+			sortValue = t
+		}
+
+		// Keyset pagination: (sortColumn, url) > (value, url)
+		if page.SortField == entities.FeedSortURL {
+			chain = chain.Where(compare(urlColumn, page.After.URL, page.SortDesc))
+		} else {
+			chain = chain.Where(clause.Or(
+				compare(sortColumn, sortValue, page.SortDesc),
+				clause.And(
+					clause.Eq{Column: sortColumn, Value: sortValue},
+					compare(urlColumn, page.After.URL, page.SortDesc),
+				),
+			))
+		}
+	}
+
+	chain = chain.Order(clause.OrderByColumn{Column: sortColumn, Desc: page.SortDesc})
+	if page.SortField != entities.FeedSortURL {
+		chain = chain.Order(clause.OrderByColumn{Column: urlColumn, Desc: page.SortDesc})
+	}
+
+	if page.Limit > 0 {
+		chain = chain.Limit(page.Limit)
 	}
 
-	chain = chain.Where(&Feed{Enabled: &enabled})
 	result := chain.Find(&feedResults)
 	return feedResults, result.Error
 }
 
-// InsertFeedRecord inserts a new feed record in the database.
-func (db *Database) InsertFeedRecord(url string, provider string, category string, enabled bool) error {
+// CountFeedRecords counts all the feed records with optional filters for 'provider', 'category', 'language' and
+// 'enabled'.
+func (db *Database) CountFeedRecords(filter FeedRecordFilter) (int64, error) {
+	var count int64
+	result := db.filterFeedRecords(filter).Model(&Feed{}).Count(&count)
+	return count, result.Error
+}
+
+// filterFeedRecords returns a query for the feed records matching the filter.
+func (db *Database) filterFeedRecords(filter FeedRecordFilter) *gorm.DB {
+	chain := db.conn.Joins("Provider").Joins("Category")
+
+	if filter.Provider != "" {
+		chain = chain.Where(clause.Eq{Column: clause.Column{Table: "Provider", Name: "name"}, Value: filter.Provider})
+	}
+
+	if filter.Category != "" {
+		chain = chain.Where(clause.Eq{Column: clause.Column{Table: "Category", Name: "name"}, Value: filter.Category})
+	}
+
+	if filter.Language != "" {
+		language := strings.ToLower(filter.Language)
+		chain = chain.Where(clause.Or(
+			clause.Eq{Column: clause.Column{Table: "feeds", Name: "language"}, Value: language},
+			clause.Like{Column: clause.Column{Table: "feeds", Name: "language"}, Value: language + "-%"},
+		))
+	}
+
+	if filter.Enabled != nil {
+		chain = chain.Where(&Feed{Enabled: filter.Enabled})
+	}
+
+	if filter.IncludeDeleted {
+		chain = chain.Unscoped()
+	}
+
+	return chain
+}
+
+// compare returns the expression 'column > value', or 'column < value' when sorting in descending order.
+func compare(column clause.Column, value interface{}, desc bool) clause.Expression {
+	if desc {
+		return clause.Lt{Column: column, Value: value}
+	}
+	return clause.Gt{Column: column, Value: value}
+}
+
+// FindFeedRecord finds the feed record with the ID provided.
+func (db *Database) FindFeedRecord(id string) (Feed, error) {
+	return findFeedRecord(db.conn, id)
+}
+
+// findFeedRecord finds the feed record with the ID provided, along with its provider and category.
+func findFeedRecord(tx *gorm.DB, id string) (Feed, error) {
+	var feedRecord Feed
+	result := tx.Joins("Provider").Joins("Category").
+		Where(clause.Eq{Column: clause.Column{Table: "feeds", Name: "id"}, Value: id}).Take(&feedRecord)
+	return feedRecord, result.Error
+}
+
+// FindFeedRecordByCanonicalURL finds the feed record with the canonical URL provided.
+func (db *Database) FindFeedRecordByCanonicalURL(canonicalURL string) (Feed, error) {
+	var feedRecord Feed
+	result := db.conn.Joins("Provider").Joins("Category").
+		Where(clause.Eq{Column: clause.Column{Table: "feeds", Name: "canonical_url"}, Value: canonicalURL}).
+		Take(&feedRecord)
+	return feedRecord, result.Error
+}
+
+// FindExistingCanonicalURLs returns which of the canonical URLs provided belong to feed records already.
+// Soft-deleted records are included, as they hold on to their URLs until purged.
+func (db *Database) FindExistingCanonicalURLs(canonicalURLs []string) ([]string, error) {
+	var existing []string
+	result := db.conn.Unscoped().Model(&Feed{}).
+		Where(clause.IN{Column: clause.Column{Name: "canonical_url"}, Values: toInterfaces(canonicalURLs)}).
+		Pluck("canonical_url", &existing)
+	return existing, result.Error
+}
+
+// InsertFeedRecord inserts a new feed record in the database, recording it in the audit log.
+func (db *Database) InsertFeedRecord(record NewFeedRecord, actor entities.Actor) (feedRecord Feed, err error) {
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		feedRecord, err = insertFeedRecord(tx, record)
+		if err != nil {
+			return err
+		}
+		return insertAuditRecord(tx, actor, entities.AuditActionCreate, nil, &feedRecord)
+	})
+	return feedRecord, err
+}
+
+// NewFeedRecord holds the fields of a feed record to be inserted.
+type NewFeedRecord struct {
+	ID           string
+	URL          string
+	CanonicalURL string
+	Provider     string
+	Category     string
+	Enabled      bool
+	Metadata     entities.FeedMetadata
+	// PollInterval is in seconds.
+	PollInterval int64
+}
+
+// InsertFeedRecords inserts new feed records in a single transaction, so either all of them are inserted or none are.
+// Each of them is recorded in the audit log.
+func (db *Database) InsertFeedRecords(records []NewFeedRecord, actor entities.Actor) (feedRecords []Feed, err error) {
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		for _, record := range records {
+			feedRecord, err := insertFeedRecord(tx, record)
+			if err != nil {
+				return err
+			}
+			if err := insertAuditRecord(tx, actor, entities.AuditActionCreate, nil, &feedRecord); err != nil {
+				return err
+			}
+			feedRecords = append(feedRecords, feedRecord)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return feedRecords, nil
+}
+
+// insertFeedRecord inserts a new feed record, along with its provider and category if they don't exist.
+// Deleted providers and categories are restored.
+func insertFeedRecord(tx *gorm.DB, record NewFeedRecord) (Feed, error) {
 	// Add Provider if it doesn't exist
 	var providerRecord Provider
-	result := db.conn.Where(Provider{Name: provider}).FirstOrCreate(&providerRecord)
+	result := tx.Where(Provider{Name: record.Provider}).FirstOrCreate(&providerRecord)
 	if result.Error != nil {
-		return result.Error
+		return Feed{}, result.Error
+	} else if err := restoreNamedRecord(tx, &providerRecord, providerRecord.DeletedAt); err != nil {
+		return Feed{}, err
 	}
 
 	// Add Category if it doesn't exist
 	var categoryRecord Category
-	result = db.conn.Where(Category{Name: category}).FirstOrCreate(&categoryRecord)
+	result = tx.Where(Category{Name: record.Category}).FirstOrCreate(&categoryRecord)
 	if result.Error != nil {
-		return result.Error
+		return Feed{}, result.Error
+	} else if err := restoreNamedRecord(tx, &categoryRecord, categoryRecord.DeletedAt); err != nil {
+		return Feed{}, err
 	}
 
 	feedRecord := Feed{
-		URL:        url,
-		ProviderID: providerRecord.ID,
-		CategoryID: categoryRecord.ID,
-		Enabled:    &enabled,
+		ID:           record.ID,
+		URL:          record.URL,
+		CanonicalURL: record.CanonicalURL,
+		Provider:     providerRecord,
+		ProviderID:   providerRecord.ID,
+		Category:     categoryRecord,
+		CategoryID:   categoryRecord.ID,
+		Enabled:      &record.Enabled,
+		Title:        record.Metadata.Title,
+		Description:  record.Metadata.Description,
+		Language:     record.Metadata.Language,
+		SiteURL:      record.Metadata.SiteURL,
+		IconURL:      record.Metadata.IconURL,
+		// New feeds are due straight away
+		PollInterval: record.PollInterval,
+		NextFetchAt:  tx.NowFunc(),
 	}
 
-	result = db.conn.Create(&feedRecord)
-	return result.Error
+	result = tx.Omit(clause.Associations).Create(&feedRecord)
+	return feedRecord, result.Error
 }
 
-// UpdateFeedState updates a feed enabled field.
-func (db *Database) UpdateFeedState(url string, enabled bool) error {
+// UpdateFeedState updates a feed enabled field, recording the change in the audit log.
+// Nothing is recorded if the feed is in that state already.
+func (db *Database) UpdateFeedState(id string, enabled bool, actor entities.Actor) error {
+	return db.conn.Transaction(func(tx *gorm.DB) error {
+		// First check record exists
+		before, err := findFeedRecord(tx, id)
+		if err != nil {
+			return err
+		} else if *before.Enabled == enabled {
+			return nil
+		}
+
+		result := tx.Model(&Feed{}).Where(clause.Eq{Column: "id", Value: id}).Update("enabled", enabled)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		after, err := findFeedRecord(tx, id)
+		if err != nil {
+			return err
+		}
+
+		action := entities.AuditActionDisable
+		if enabled {
+			action = entities.AuditActionEnable
+		}
+		return insertAuditRecord(tx, actor, action, &before, &after)
+	})
+}
+
+// UpdateFeedFetchState replaces the fetch state fields of a feed record, and schedules its next fetch.
+// Unlike other changes, the fetch state doesn't bump the record 'updated_at' field, as it changes on every fetch.
+func (db *Database) UpdateFeedFetchState(id string, state entities.FeedFetchState, nextFetchAt time.Time) error {
 	// First check record exists
 	var feedRecord Feed
-	result := db.conn.Where(&Feed{URL: url}).Take(&feedRecord)
+	result := db.conn.Where(clause.Eq{Column: "id", Value: id}).Take(&feedRecord)
 	if result.Error != nil {
 		return result.Error
 	}
 
-	result = db.conn.Model(&Feed{URL: url}).Update("enabled", enabled)
+	result = db.conn.Model(&Feed{}).Where(clause.Eq{Column: "id", Value: id}).UpdateColumns(map[string]interface{}{
+		"etag":             state.ETag,
+		"last_modified":    state.LastModified,
+		"content_hash":     state.ContentHash,
+		"update_frequency": state.UpdateFrequency,
+		"next_fetch_at":    nextFetchAt,
+	})
 	return result.Error
 }
 
-// DeleteFeedState deletes a feed record from the database.
-func (db *Database) DeleteFeedRecord(url string) error {
-	// First check record exists
+// LeaseDueFeedRecords leases up to limit enabled feed records due to be fetched at the time provided, and returns
+// them. The records due the longest come first.
+// Leasing a record pushes its next fetch to leasedUntil, so that it's not leased again until then. Records leased
+// concurrently by someone else are skipped.
+func (db *Database) LeaseDueFeedRecords(limit int, now time.Time, leasedUntil time.Time) (feedRecords []Feed,
+	err error) {
+	nextFetchAtColumn := clause.Column{Table: "feeds", Name: "next_fetch_at"}
+	enabled := true
+
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		var candidates []Feed
+		result := tx.Where(&Feed{Enabled: &enabled}).Where(clause.Lte{Column: nextFetchAtColumn, Value: now}).
+			Order(clause.OrderByColumn{Column: nextFetchAtColumn}).Order("url").Limit(limit).Find(&candidates)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var leased []interface{}
+		positions := make(map[string]int, len(candidates))
+		for i, candidate := range candidates {
+			positions[candidate.ID] = i
+
+			// The record is only leased if it's still due, otherwise someone else leased it in the meantime
+			result = tx.Model(&Feed{}).
+				Where(clause.Eq{Column: "id", Value: candidate.ID}).
+				Where(clause.Lte{Column: "next_fetch_at", Value: now}).
+				UpdateColumn("next_fetch_at", leasedUntil)
+			if result.Error != nil {
+				return result.Error
+			} else if result.RowsAffected == 1 {
+				leased = append(leased, candidate.ID)
+			}
+		}
+
+		if len(leased) == 0 {
+			return nil
+		}
+
+		result = tx.Joins("Provider").Joins("Category").
+			Where(clause.IN{Column: clause.Column{Table: "feeds", Name: "id"}, Values: leased}).Find(&feedRecords)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		sort.Slice(feedRecords, func(i, j int) bool {
+			return positions[feedRecords[i].ID] < positions[feedRecords[j].ID]
+		})
+		return nil
+	})
+
+	return feedRecords, err
+}
+
+// FeedRecordUpdate holds the changes to apply to a feed record. Nil fields are left unchanged.
+type FeedRecordUpdate struct {
+	// URL and CanonicalURL must be changed together.
+	URL          *string
+	CanonicalURL *string
+	Provider     *string
+	Category     *string
+	Enabled      *bool
+	// Metadata replaces all the metadata fields.
+	Metadata     *entities.FeedMetadata
+	PollInterval *int64
+}
+
+// UpdateFeedRecord applies the changes provided to a feed record, in a single transaction,
+// and returns the updated record. The change is recorded in the audit log. Updates leaving the record as it is
+// are neither written nor recorded.
+// Providers and categories are created if they don't exist.
+func (db *Database) UpdateFeedRecord(id string, update FeedRecordUpdate, actor entities.Actor) (feedRecord Feed,
+	err error) {
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		// First check record exists
+		before, err := findFeedRecord(tx, id)
+		if err != nil {
+			return err
+		}
+
+		var result *gorm.DB
+		changes := map[string]interface{}{}
+		// proposed is the record as it would be after the update, used to tell whether anything changes
+		proposed := before
+
+		if update.URL != nil {
+			changes["url"] = *update.URL
+			changes["canonical_url"] = *update.CanonicalURL
+			proposed.URL = *update.URL
+			proposed.CanonicalURL = *update.CanonicalURL
+		}
+
+		if update.Provider != nil {
+			var providerRecord Provider
+			result = tx.Where(Provider{Name: *update.Provider}).FirstOrCreate(&providerRecord)
+			if result.Error != nil {
+				return result.Error
+			} else if err := restoreNamedRecord(tx, &providerRecord, providerRecord.DeletedAt); err != nil {
+				return err
+			}
+			changes["provider_id"] = providerRecord.ID
+			proposed.Provider = providerRecord
+			proposed.ProviderID = providerRecord.ID
+		}
+
+		if update.Category != nil {
+			var categoryRecord Category
+			result = tx.Where(Category{Name: *update.Category}).FirstOrCreate(&categoryRecord)
+			if result.Error != nil {
+				return result.Error
+			} else if err := restoreNamedRecord(tx, &categoryRecord, categoryRecord.DeletedAt); err != nil {
+				return err
+			}
+			changes["category_id"] = categoryRecord.ID
+			proposed.Category = categoryRecord
+			proposed.CategoryID = categoryRecord.ID
+		}
+
+		if update.Enabled != nil {
+			changes["enabled"] = *update.Enabled
+			proposed.Enabled = update.Enabled
+		}
+
+		if update.Metadata != nil {
+			changes["title"] = update.Metadata.Title
+			changes["description"] = update.Metadata.Description
+			changes["language"] = update.Metadata.Language
+			changes["site_url"] = update.Metadata.SiteURL
+			changes["icon_url"] = update.Metadata.IconURL
+			proposed.Title = update.Metadata.Title
+			proposed.Description = update.Metadata.Description
+			proposed.Language = update.Metadata.Language
+			proposed.SiteURL = update.Metadata.SiteURL
+			proposed.IconURL = update.Metadata.IconURL
+		}
+
+		if update.PollInterval != nil {
+			changes["poll_interval"] = *update.PollInterval
+			proposed.PollInterval = *update.PollInterval
+		}
+
+		if len(changes) == 0 {
+			feedRecord = before
+			return nil
+		}
+
+		// Updates leaving the record as it is are neither written nor recorded in the audit log
+		beforeSnapshot, err := feedSnapshot(before)
+		if err != nil {
+			return err
+		}
+		proposedSnapshot, err := feedSnapshot(proposed)
+		if err != nil {
+			return err
+		}
+		if beforeSnapshot == proposedSnapshot {
+			feedRecord = before
+			return nil
+		}
+
+		result = tx.Model(&Feed{}).Where(clause.Eq{Column: "id", Value: id}).Updates(changes)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		feedRecord, err = findFeedRecord(tx, id)
+		if err != nil {
+			return err
+		}
+		return insertAuditRecord(tx, actor, entities.AuditActionUpdate, &before, &feedRecord)
+	})
+
+	return feedRecord, err
+}
+
+// DeleteFeedRecord soft-deletes a feed record, recording it in the audit log.
+// The record, along with its health record, is kept until purged, so that it can be restored.
+func (db *Database) DeleteFeedRecord(id string, actor entities.Actor) error {
+	return db.conn.Transaction(func(tx *gorm.DB) error {
+		// First check record exists
+		before, err := findFeedRecord(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if result := tx.Where(clause.Eq{Column: "id", Value: id}).Delete(&Feed{}); result.Error != nil {
+			return result.Error
+		}
+
+		after, err := findFeedRecord(tx.Unscoped(), id)
+		if err != nil {
+			return err
+		}
+		return insertAuditRecord(tx, actor, entities.AuditActionDelete, &before, &after)
+	})
+}
+
+// RestoreFeedRecord restores a soft-deleted feed record, along with its provider and category if they were deleted
+// too, recording it in the audit log, and returns the restored record. Nothing is recorded if the record is not
+// deleted.
+func (db *Database) RestoreFeedRecord(id string, actor entities.Actor) (feedRecord Feed, err error) {
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		// First check record exists
+		before, err := findFeedRecord(tx.Unscoped(), id)
+		if err != nil {
+			return err
+		} else if !before.DeletedAt.Valid {
+			feedRecord = before
+			return nil
+		}
+
+		if err := restoreNamedRecord(tx, &Provider{ID: before.ProviderID}, before.Provider.DeletedAt); err != nil {
+			return err
+		}
+		if err := restoreNamedRecord(tx, &Category{ID: before.CategoryID}, before.Category.DeletedAt); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Model(&Feed{}).Where(clause.Eq{Column: "id", Value: id}).Update("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		feedRecord, err = findFeedRecord(tx, id)
+		if err != nil {
+			return err
+		}
+		return insertAuditRecord(tx, actor, entities.AuditActionRestore, &before, &feedRecord)
+	})
+
+	return feedRecord, err
+}
+
+// PurgeFeedRecords permanently deletes up to limit feed records soft-deleted before the time provided, along with
+// their health records, recording it in the audit log. The records deleted the longest come first, and zero limit
+// means no limit. It returns the number of records purged.
+// Deleted providers and categories no longer referenced by any feed are deleted too.
+func (db *Database) PurgeFeedRecords(deletedBefore time.Time, limit int, actor entities.Actor) (purged int,
+	err error) {
+	deletedAtColumn := clause.Column{Table: "feeds", Name: "deleted_at"}
+
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		chain := tx.Unscoped().Joins("Provider").Joins("Category").
+			Where(clause.Lt{Column: deletedAtColumn, Value: deletedBefore}).
+			Order(clause.OrderByColumn{Column: deletedAtColumn}).
+			Order(clause.OrderByColumn{Column: clause.Column{Table: "feeds", Name: "url"}})
+		if limit > 0 {
+			chain = chain.Limit(limit)
+		}
+
+		var feedRecords []Feed
+		if result := chain.Find(&feedRecords); result.Error != nil {
+			return result.Error
+		}
+
+		for i := range feedRecords {
+			err := insertAuditRecord(tx, actor, entities.AuditActionPurge, &feedRecords[i], nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		purged = len(feedRecords)
+		if err := purgeFeedRecords(tx, feedRecords); err != nil {
+			return err
+		}
+
+		if err := purgeNamedRecords(tx, "providers", "provider_id"); err != nil {
+			return err
+		}
+		return purgeNamedRecords(tx, "categories", "category_id")
+	})
+
+	return purged, err
+}
+
+// purgeFeedRecords permanently deletes the feed records provided, along with their health records.
+func purgeFeedRecords(tx *gorm.DB, feedRecords []Feed) error {
+	if len(feedRecords) == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, 0, len(feedRecords))
+	for _, feedRecord := range feedRecords {
+		ids = append(ids, feedRecord.ID)
+	}
+
+	result := tx.Where(clause.IN{Column: clause.Column{Name: "feed_id"}, Values: ids}).Delete(&FeedHealth{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	result = tx.Unscoped().Where(clause.IN{Column: clause.Column{Name: "id"}, Values: ids}).Delete(&Feed{})
+	return result.Error
+}
+
+// FindFeedHealthRecord finds the health record of the feed with the ID provided.
+// If the feed was never checked, a record with only the feed ID set is returned.
+func (db *Database) FindFeedHealthRecord(id string) (FeedHealth, error) {
+	// First check feed record exists
 	var feedRecord Feed
-	result := db.conn.Where(&Feed{URL: url}).Take(&feedRecord)
+	result := db.conn.Where(clause.Eq{Column: "id", Value: id}).Take(&feedRecord)
+	if result.Error != nil {
+		return FeedHealth{}, result.Error
+	}
+
+	healthRecord := FeedHealth{FeedID: id}
+	result = db.conn.Where(clause.Eq{Column: "feed_id", Value: id}).Limit(1).Find(&healthRecord)
+	return healthRecord, result.Error
+}
+
+// UpsertFeedHealthRecord records the outcome of a health check in the health record of a feed, in a single
+// transaction, and returns the updated record.
+// The record is created on the first check of the feed.
+func (db *Database) UpsertFeedHealthRecord(id string, check entities.FeedCheck) (healthRecord FeedHealth, err error) {
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		// First check feed record exists
+		var feedRecord Feed
+		result := tx.Where(clause.Eq{Column: "id", Value: id}).Take(&feedRecord)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		healthRecord = FeedHealth{FeedID: id}
+		result = tx.Where(clause.Eq{Column: "feed_id", Value: id}).Limit(1).Find(&healthRecord)
+		if result.Error != nil {
+			return result.Error
+		}
+		exists := result.RowsAffected != 0
+
+		checkedAt := check.CheckedAt.UTC()
+		healthRecord.LastCheckedAt = &checkedAt
+		healthRecord.LastStatusCode = check.StatusCode
+		healthRecord.LastError = check.Error
+		if check.Error == "" {
+			healthRecord.LastSuccessAt = &checkedAt
+			healthRecord.ConsecutiveFailures = 0
+		} else {
+			healthRecord.LastFailureAt = &checkedAt
+			healthRecord.ConsecutiveFailures++
+		}
+
+		if exists {
+			return tx.Save(&healthRecord).Error
+		}
+		return tx.Create(&healthRecord).Error
+	})
+
+	return healthRecord, err
+}
+
+// FindAllProviderRecords finds all the provider records along with their feed count, sorted by name.
+func (db *Database) FindAllProviderRecords() ([]NamedRecordCount, error) {
+	return db.findAllNamedRecords("providers", "provider_id")
+}
+
+// InsertProviderRecord inserts a new provider record in the database, or restores the deleted one with the same
+// name.
+func (db *Database) InsertProviderRecord(name string) (Provider, error) {
+	providerRecord := Provider{Name: name}
+	err := db.insertNamedRecord(&providerRecord, name)
+	return providerRecord, err
+}
+
+// UpdateProviderName updates a provider name.
+func (db *Database) UpdateProviderName(id uint64, name string) error {
+	return db.updateNamedRecord(&Provider{ID: id}, id, name)
+}
+
+// DeleteProviderRecord deletes a provider record from the database.
+// If cascade is true, all the feeds from the provider are soft-deleted too, and recorded in the audit log. Otherwise
+// errRecordInUse is returned in case there are feeds referencing the provider.
+func (db *Database) DeleteProviderRecord(id uint64, cascade bool, actor entities.Actor) error {
+	return db.deleteNamedRecord(&Provider{ID: id}, id, "provider_id", cascade, actor)
+}
+
+// FindAllCategoryRecords finds all the category records along with their feed count, sorted by name.
+func (db *Database) FindAllCategoryRecords() ([]NamedRecordCount, error) {
+	return db.findAllNamedRecords("categories", "category_id")
+}
+
+// InsertCategoryRecord inserts a new category record in the database, or restores the deleted one with the same
+// name.
+func (db *Database) InsertCategoryRecord(name string) (Category, error) {
+	categoryRecord := Category{Name: name}
+	err := db.insertNamedRecord(&categoryRecord, name)
+	return categoryRecord, err
+}
+
+// UpdateCategoryName updates a category name.
+func (db *Database) UpdateCategoryName(id uint64, name string) error {
+	return db.updateNamedRecord(&Category{ID: id}, id, name)
+}
+
+// DeleteCategoryRecord deletes a category record from the database.
+// If cascade is true, all the feeds in the category are soft-deleted too, and recorded in the audit log. Otherwise
+// errRecordInUse is returned in case there are feeds referencing the category.
+func (db *Database) DeleteCategoryRecord(id uint64, cascade bool, actor entities.Actor) error {
+	return db.deleteNamedRecord(&Category{ID: id}, id, "category_id", cascade, actor)
+}
+
+// FindAllAPIKeyRecords finds all the API key records, sorted by name.
+func (db *Database) FindAllAPIKeyRecords() ([]APIKey, error) {
+	var records []APIKey
+	result := db.conn.Order("name").Find(&records)
+	return records, result.Error
+}
+
+// FindAPIKeyRecordByHash finds the API key record with the key hash provided.
+func (db *Database) FindAPIKeyRecordByHash(keyHash string) (APIKey, error) {
+	var record APIKey
+	result := db.conn.Where(clause.Eq{Column: "key_hash", Value: keyHash}).Take(&record)
+	return record, result.Error
+}
+
+// InsertAPIKeyRecord inserts a new API key record in the database.
+func (db *Database) InsertAPIKeyRecord(record APIKey) (APIKey, error) {
+	result := db.conn.Create(&record)
+	return record, result.Error
+}
+
+// DeleteAPIKeyRecord deletes an API key record from the database.
+func (db *Database) DeleteAPIKeyRecord(id string) error {
+	result := db.conn.Where(clause.Eq{Column: "id", Value: id}).Delete(&APIKey{})
+	if result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AuditRecordFilter holds the filters used to find audit records. Empty fields are ignored.
+type AuditRecordFilter struct {
+	FeedID string
+	Actor  string
+	// Since is inclusive and Until exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// FindAuditRecords finds the audit records matching the filter, the most recent first.
+// If beforeID is not zero, only records older than it are returned. Zero limit means no limit.
+func (db *Database) FindAuditRecords(filter AuditRecordFilter, beforeID uint64, limit int) ([]AuditEntry, error) {
+	var records []AuditEntry
+	chain := db.conn.Model(&AuditEntry{})
+
+	if filter.FeedID != "" {
+		chain = chain.Where(clause.Eq{Column: "feed_id", Value: filter.FeedID})
+	}
+
+	if filter.Actor != "" {
+		chain = chain.Where(clause.Eq{Column: "actor", Value: filter.Actor})
+	}
+
+	if !filter.Since.IsZero() {
+		chain = chain.Where(clause.Gte{Column: "created_at", Value: filter.Since.UTC()})
+	}
+
+	if !filter.Until.IsZero() {
+		chain = chain.Where(clause.Lt{Column: "created_at", Value: filter.Until.UTC()})
+	}
+
+	if beforeID != 0 {
+		chain = chain.Where(clause.Lt{Column: "id", Value: beforeID})
+	}
+
+	if limit > 0 {
+		chain = chain.Limit(limit)
+	}
+
+	// IDs grow along with the time records are inserted at
+	result := chain.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: true}).Find(&records)
+	return records, result.Error
+}
+
+// insertAuditRecord records a change to a feed in the audit log, with the feed records before and after the change.
+// Actor names and request IDs too long are truncated.
+func insertAuditRecord(tx *gorm.DB, actor entities.Actor, action string, before *Feed, after *Feed) error {
+	record := AuditEntry{
+		Action:    action,
+		Actor:     truncate(actor.Name, entities.MaxAuditActorLength),
+		RequestID: truncate(actor.RequestID, entities.MaxAuditRequestIDLength),
+	}
+
+	var err error
+	if before != nil {
+		record.FeedID = before.ID
+		if record.Before, err = feedSnapshot(*before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		record.FeedID = after.ID
+		if record.After, err = feedSnapshot(*after); err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&record).Error
+}
+
+// feedSnapshot returns a feed record, with its provider and category loaded, as recorded in the audit log.
+func feedSnapshot(feedRecord Feed) (string, error) {
+	data, err := json.Marshal(mapFeedRecord(feedRecord))
+	return string(data), err
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// findAllNamedRecords finds all the records in a providers-like table, leaving deleted ones out, along with the number
+// of feeds referencing them through the foreign key column provided. Soft-deleted feeds are not counted.
+func (db *Database) findAllNamedRecords(table string, feedsFKColumn string) ([]NamedRecordCount, error) {
+	var records []NamedRecordCount
+	result := db.conn.Table(table).
+		Select(fmt.Sprintf("%[1]s.id, %[1]s.name, COUNT(feeds.id) AS feed_count", table)).
+		Joins(fmt.Sprintf("LEFT JOIN feeds ON feeds.%s = %s.id AND feeds.deleted_at IS NULL", feedsFKColumn, table)).
+		Where(fmt.Sprintf("%s.deleted_at IS NULL", table)).
+		Group(fmt.Sprintf("%[1]s.id, %[1]s.name", table)).
+		Order(fmt.Sprintf("%s.name", table)).
+		Scan(&records)
+	return records, result.Error
+}
+
+// insertNamedRecord inserts the provider or category record provided, unless there is a deleted record with the same
+// name, which is restored and loaded into the model instead.
+func (db *Database) insertNamedRecord(model interface{}, name string) error {
+	return db.conn.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(model).Where("name = ? AND deleted_at IS NOT NULL", name).UpdateColumn("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		} else if result.RowsAffected != 0 {
+			return tx.Where("name = ?", name).Take(model).Error
+		}
+
+		return tx.Create(model).Error
+	})
+}
+
+// updateNamedRecord updates the name of a provider or category record.
+// Deleted records still hold on to their name, so other records can't be renamed after them.
+func (db *Database) updateNamedRecord(model interface{}, id uint64, name string) error {
+	// First check record exists
+	result := db.conn.Model(model).Where("id = ? AND deleted_at IS NULL", id).Take(model)
 	if result.Error != nil {
 		return result.Error
 	}
 
-	result = db.conn.Where(&Feed{URL: url}).Delete(&feedRecord)
+	result = db.conn.Model(model).Update("name", name)
 	return result.Error
 }
+
+// deleteNamedRecord deletes a provider or category record, and optionally soft-deletes the feeds referencing it.
+// Records still referenced by soft-deleted feeds are only marked as deleted, so those feeds can still be restored
+// until they are purged.
+func (db *Database) deleteNamedRecord(model interface{}, id uint64, feedsFKColumn string, cascade bool,
+	actor entities.Actor) error {
+	feedsFK := clause.Eq{Column: clause.Column{Table: "feeds", Name: feedsFKColumn}, Value: id}
+
+	return db.conn.Transaction(func(tx *gorm.DB) error {
+		// First check record exists
+		result := tx.Model(model).Where("id = ? AND deleted_at IS NULL", id).Take(model)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var feedRecords []Feed
+		result = tx.Joins("Provider").Joins("Category").Where(feedsFK).Order("feeds.id").Find(&feedRecords)
+		if result.Error != nil {
+			return result.Error
+		} else if len(feedRecords) != 0 && !cascade {
+			return errRecordInUse
+		}
+
+		deletedAt := tx.NowFunc()
+		if len(feedRecords) != 0 {
+			ids := make([]interface{}, 0, len(feedRecords))
+			for _, feedRecord := range feedRecords {
+				ids = append(ids, feedRecord.ID)
+			}
+			feedsIn := clause.IN{Column: clause.Column{Table: "feeds", Name: "id"}, Values: ids}
+
+			result = tx.Model(&Feed{}).Where(feedsIn).UpdateColumn("deleted_at", deletedAt)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			var deletedRecords []Feed
+			result = tx.Unscoped().Joins("Provider").Joins("Category").Where(feedsIn).Order("feeds.id").
+				Find(&deletedRecords)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			for i := range feedRecords {
+				err := insertAuditRecord(tx, actor, entities.AuditActionDelete, &feedRecords[i], &deletedRecords[i])
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		var referencingFeeds int64
+		if result := tx.Unscoped().Model(&Feed{}).Where(feedsFK).Count(&referencingFeeds); result.Error != nil {
+			return result.Error
+		} else if referencingFeeds == 0 {
+			return tx.Delete(model).Error
+		}
+		return tx.Model(model).UpdateColumn("deleted_at", deletedAt).Error
+	})
+}
+
+// restoreNamedRecord restores a provider or category record, given the time it was deleted at, so that feeds can
+// reference it again. Records not deleted are left as they are.
+func restoreNamedRecord(tx *gorm.DB, model interface{}, deletedAt *time.Time) error {
+	if deletedAt == nil {
+		return nil
+	}
+	return tx.Model(model).UpdateColumn("deleted_at", nil).Error
+}
+
+// purgeNamedRecords permanently deletes the deleted records in a providers-like table no longer referenced by any
+// feed through the foreign key column provided.
+func purgeNamedRecords(tx *gorm.DB, table string, feedsFKColumn string) error {
+	return tx.Exec(fmt.Sprintf("DELETE FROM %[1]s WHERE deleted_at IS NOT NULL AND "+
+		"NOT EXISTS (SELECT 1 FROM feeds WHERE feeds.%[2]s = %[1]s.id)", table, feedsFKColumn)).Error
+}
+
+// toInterfaces converts a slice of strings into a slice of empty interfaces, as needed by clause.IN.
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/VividCortex/mysqlerr"
+	driverMySQL "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Database drivers supported.
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// dialect holds everything that is specific to a database driver.
+//
+// Queries should not hardcode identifier quoting (e.g. MySQL backticks), but use GORM clauses instead
+// so that the dialector quotes them appropriately.
+type dialect struct {
+	// open returns the GORM dialector for the DSN provided.
+	open func(dsn string) gorm.Dialector
+	// isDuplicateError checks whether the error is a unique constraint violation.
+	isDuplicateError func(err error) bool
+}
+
+var dialects = map[string]dialect{
+	DriverMySQL: {
+		open: mysql.Open,
+		isDuplicateError: func(err error) bool {
+			var driverErr *driverMySQL.MySQLError
+			return errors.As(err, &driverErr) && driverErr.Number == mysqlerr.ER_DUP_ENTRY
+		},
+	},
+	DriverPostgres: {
+		open: postgres.Open,
+		isDuplicateError: func(err error) bool {
+			var driverErr *pgconn.PgError
+			return errors.As(err, &driverErr) && driverErr.Code == pgerrcode.UniqueViolation
+		},
+	},
+	DriverSQLite: {
+		open: sqlite.Open,
+		isDuplicateError: func(err error) bool {
+			var driverErr sqlite3.Error
+			return errors.As(err, &driverErr) &&
+				(driverErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+					driverErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+		},
+	},
+}
+
+// lookupDialect returns the dialect for the driver provided.
+func lookupDialect(driver string) (dialect, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return dialect{}, fmt.Errorf("database driver not supported: %s", driver)
+	}
+	return d, nil
+}
+
+// MySQLDSN returns the DSN needed to connect to a MySQL database.
+func MySQLDSN(host string, port int, username string, password string, dbname string) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		username, password, host, port, dbname)
+}
+
+// PostgresDSN returns the DSN needed to connect to a PostgreSQL database.
+func PostgresDSN(host string, port int, username string, password string, dbname string, sslmode string) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		host, port, username, password, dbname, sslmode)
+}
+
+// SQLiteDSN returns the DSN needed to open a SQLite database file.
+func SQLiteDSN(path string) string {
+	return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path)
+}
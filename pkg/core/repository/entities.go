@@ -1,23 +1,106 @@
 package repository
 
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
 // Feed represents the 'feeds' table in the database.
 type Feed struct {
-	URL        string `gorm:"primaryKey;type:varchar(250);not null"`
-	Provider   Provider
-	ProviderID uint64 `gorm:"not null"` // Foreign Key
-	Category   Category
-	CategoryID uint64 `gorm:"not null"` // Foreign Key
-	Enabled    *bool  `gorm:"not null;default:false"`
+	// ID is the surrogate key exposed through the API.
+	ID  string `gorm:"primaryKey;type:varchar(36);not null"`
+	URL string `gorm:"type:varchar(250);not null;uniqueIndex"`
+	// CanonicalURL is the form of the URL used to tell feeds apart.
+	CanonicalURL string `gorm:"type:varchar(250);not null;uniqueIndex"`
+	Provider     Provider
+	ProviderID   uint64 `gorm:"not null"` // Foreign Key
+	Category     Category
+	CategoryID   uint64 `gorm:"not null"` // Foreign Key
+	Enabled      *bool  `gorm:"not null;default:false"`
+	// Metadata taken from the feed document
+	Title       string `gorm:"type:varchar(250);not null;default:''"`
+	Description string `gorm:"type:varchar(1000);not null;default:''"`
+	Language    string `gorm:"type:varchar(35);not null;default:'';index"`
+	SiteURL     string `gorm:"type:varchar(250);not null;default:''"`
+	IconURL     string `gorm:"type:varchar(250);not null;default:''"`
+	// Fetch state reported by the fetchers
+	ETag            string `gorm:"column:etag;type:varchar(250);not null;default:''"`
+	LastModified    string `gorm:"type:varchar(250);not null;default:''"`
+	ContentHash     string `gorm:"type:varchar(250);not null;default:''"`
+	UpdateFrequency int64  `gorm:"not null;default:0"`
+	// Scheduling
+	PollInterval int64     `gorm:"not null;default:0"`
+	NextFetchAt  time.Time `gorm:"index"`
+	CreatedAt    time.Time `gorm:"index"`
+	UpdatedAt    time.Time
+	// DeletedAt is set on feeds soft-deleted. Queries leave them out unless unscoped.
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// FeedHealth represents the 'feed_health' table in the database.
+// It holds the outcome of the latest health checks of each feed checked at least once.
+type FeedHealth struct {
+	FeedID              string `gorm:"primaryKey;type:varchar(36);not null"`
+	LastCheckedAt       *time.Time
+	LastSuccessAt       *time.Time
+	LastFailureAt       *time.Time
+	LastStatusCode      int    `gorm:"not null;default:0"`
+	LastError           string `gorm:"type:varchar(250);not null;default:''"`
+	ConsecutiveFailures int    `gorm:"not null;default:0"`
+}
+
+// TableName overrides the table name used by FeedHealth.
+func (FeedHealth) TableName() string {
+	return "feed_health"
 }
 
 // Provider represents the 'providers' table in the database.
 type Provider struct {
 	ID   uint64 `gorm:"primaryKey;autoIncrement;not null"`
 	Name string `gorm:"type:varchar(30);uniqueIndex;not null"`
+	// DeletedAt is set on records deleted while soft-deleted feeds still reference them, which are kept until those
+	// feeds are purged. Unlike feeds, queries don't leave them out on their own.
+	DeletedAt *time.Time `gorm:"index"`
 }
 
 // Category represents the 'categories' table in the database.
 type Category struct {
 	ID   uint64 `gorm:"primaryKey;autoIncrement;not null"`
 	Name string `gorm:"type:varchar(30);uniqueIndex;not null"`
+	// DeletedAt is set on records deleted while soft-deleted feeds still reference them, which are kept until those
+	// feeds are purged. Unlike feeds, queries don't leave them out on their own.
+	DeletedAt *time.Time `gorm:"index"`
+}
+
+// NamedRecordCount holds a provider or category record along with the number of feeds referencing it.
+type NamedRecordCount struct {
+	ID        uint64
+	Name      string
+	FeedCount int64
+}
+
+// APIKey represents the 'api_keys' table in the database.
+type APIKey struct {
+	ID   string `gorm:"primaryKey;type:varchar(36);not null"`
+	Name string `gorm:"type:varchar(50);uniqueIndex;not null"`
+	// KeyHash is the hex encoded SHA-256 hash of the key. Keys themselves are never stored.
+	KeyHash string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	// Scopes is a comma separated list of scopes.
+	Scopes    string `gorm:"type:varchar(100);not null"`
+	CreatedAt time.Time
+}
+
+// AuditEntry represents the 'audit_entries' table in the database.
+// Entries are only ever inserted, in the same transaction as the change they record.
+type AuditEntry struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement;not null"`
+	FeedID    string `gorm:"type:varchar(36);not null;index"`
+	Action    string `gorm:"type:varchar(10);not null"`
+	Actor     string `gorm:"type:varchar(250);not null;index"`
+	RequestID string `gorm:"type:varchar(100);not null"`
+	// Before and After hold the feed as JSON, and are empty for feeds created and deleted respectively.
+	Before    string    `gorm:"type:text;not null"`
+	After     string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"index"`
 }
@@ -0,0 +1,993 @@
+// Package memory provides an in-memory implementation of the core.Repository interface.
+//
+// It is meant to be used in tests and when running the service locally without a database.
+// Data is lost when the process terminates.
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// Repository represents an in-memory repository.
+// It is safe for concurrent use.
+type Repository struct {
+	mu         sync.RWMutex
+	feeds      map[string]entities.Feed       // keyed by feed ID, deleted feeds included until purged
+	health     map[string]entities.FeedHealth // keyed by feed ID, only for feeds checked at least once
+	providers  *namedEntries
+	categories *namedEntries
+	apiKeys    map[string]apiKeyEntry // keyed by API key ID
+	audit      entities.AuditEntries  // sorted by ID
+}
+
+// apiKeyEntry holds an API key along with the hash of the key.
+type apiKeyEntry struct {
+	key     entities.APIKey
+	keyHash string
+}
+
+// NewRepository returns a new empty Repository.
+func NewRepository() *Repository {
+	return &Repository{
+		feeds:      make(map[string]entities.Feed),
+		health:     make(map[string]entities.FeedHealth),
+		providers:  newNamedEntries(),
+		categories: newNamedEntries(),
+		apiKeys:    make(map[string]apiKeyEntry),
+	}
+}
+
+// Close is a no-op, it only exists to mirror repository.DatabaseService.
+func (r *Repository) Close() error {
+	return nil
+}
+
+// HealthCheck always succeeds as there is nothing to check.
+func (r *Repository) HealthCheck() error {
+	return nil
+}
+
+// GetFeeds returns a page of feeds matching a certain criteria.
+func (r *Repository) GetFeeds(query entities.FeedQuery) (page entities.FeedsPage, err error) {
+	field, desc, ok := repository.ParseFeedSort(query.Sort)
+	if !ok {
+		return page, &repository.DBServiceError{Msg: fmt.Sprintf("database error: unknown sort expression <%s>", query.Sort)}
+	}
+
+	var after *repository.Cursor
+	if query.Cursor != "" {
+		cursor, err := repository.DecodeCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return page, err
+		}
+		after = &cursor
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	feeds := entities.Feeds{}
+
+	for _, feed := range r.feeds {
+		if feed.DeletedAt != nil && !query.IncludeDeleted {
+			continue
+		}
+
+		if query.Provider != "" && query.Provider != feed.Provider {
+			continue
+		}
+
+		if query.Category != "" && query.Category != feed.Category {
+			continue
+		}
+
+		if query.Language != "" && !matchLanguage(feed.Language, query.Language) {
+			continue
+		}
+
+		if query.Enabled != nil && *query.Enabled != feed.Enabled {
+			continue
+		}
+
+		feeds = append(feeds, feed)
+	}
+
+	// less reports whether feed A goes before feed B, comparing sort field values first and URLs second
+	less := func(valueA, urlA, valueB, urlB string) bool {
+		if valueA == valueB {
+			valueA, valueB = urlA, urlB
+		}
+		if desc {
+			return valueA > valueB
+		}
+		return valueA < valueB
+	}
+
+	sort.Slice(feeds, func(i, j int) bool {
+		return less(repository.FeedSortValue(feeds[i], field), feeds[i].URL,
+			repository.FeedSortValue(feeds[j], field), feeds[j].URL)
+	})
+
+	page.Total = int64(len(feeds))
+
+	if after != nil {
+		start := sort.Search(len(feeds), func(i int) bool {
+			return less(after.Value, after.URL, repository.FeedSortValue(feeds[i], field), feeds[i].URL)
+		})
+		feeds = feeds[start:]
+	}
+
+	if query.Limit > 0 && len(feeds) > query.Limit {
+		feeds = feeds[:query.Limit]
+		page.NextCursor = repository.NewCursor(query.Sort, feeds[query.Limit-1]).Encode()
+	}
+
+	page.Feeds = feeds
+	return page, nil
+}
+
+// IterateFeeds calls fn for every feed matching a certain criteria, in order.
+// If the query has a limit, at most that many feeds are visited.
+// Iteration stops at the first error returned by fn, which is returned as is.
+func (r *Repository) IterateFeeds(query entities.FeedQuery, fn func(feed entities.Feed) error) (err error) {
+	page, err := r.GetFeeds(query)
+	if err != nil {
+		return err
+	}
+
+	for _, feed := range page.Feeds {
+		if err := fn(feed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetFeed returns the feed with the ID provided.
+func (r *Repository) GetFeed(id string) (feed entities.Feed, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	feed, ok := r.lookupFeed(id)
+	if !ok {
+		return feed, &repository.DBNotFoundError{}
+	}
+
+	return feed, nil
+}
+
+// GetFeedByURL returns the feed with the canonical URL provided.
+func (r *Repository) GetFeedByURL(canonicalURL string) (feed entities.Feed, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	feed, ok := r.lookupCanonicalURL(canonicalURL)
+	if !ok {
+		return feed, &repository.DBNotFoundError{}
+	}
+
+	return feed, nil
+}
+
+// AddFeed adds a new feed and returns it along with its newly assigned ID.
+func (r *Repository) AddFeed(feed entities.Feed, actor entities.Actor) (created entities.Feed, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isDuplicate(feed.URL, repository.FeedCanonicalURL(feed), "") {
+		return created, &repository.DBDUPError{}
+	}
+
+	return r.addFeed(feed, actor), nil
+}
+
+// AddFeeds adds a batch of feeds and returns the outcome for each of them, in the same order.
+// In atomic mode, feeds are only added if none of them are duplicates.
+// In dry run mode, feeds that would have been added are reported as valid instead.
+func (r *Repository) AddFeeds(feeds entities.Feeds, opts entities.FeedBatchOptions, actor entities.Actor) (results entities.FeedBatchResults, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results = make(entities.FeedBatchResults, len(feeds))
+	seen := make(map[string]bool, len(feeds))
+	failed := false
+
+	for i, feed := range feeds {
+		results[i].Feed = feed
+
+		canonicalURL := repository.FeedCanonicalURL(feed)
+		if r.isDuplicate(feed.URL, canonicalURL, "") || seen[canonicalURL] {
+			results[i].Status = entities.FeedBatchDuplicate
+			failed = true
+			continue
+		}
+		seen[canonicalURL] = true
+	}
+
+	for i, feed := range feeds {
+		if results[i].Status != "" {
+			continue
+		}
+
+		if opts.Atomic && failed {
+			results[i].Status = entities.FeedBatchSkipped
+			continue
+		}
+
+		if opts.DryRun {
+			results[i].Status = entities.FeedBatchValid
+			continue
+		}
+
+		results[i] = entities.FeedBatchResult{Status: entities.FeedBatchCreated, Feed: r.addFeed(feed, actor)}
+	}
+
+	return results, nil
+}
+
+// SetFeedState updates a feed enabled field.
+// The change is recorded in the audit log, unless the feed is in that state already.
+func (r *Repository) SetFeedState(id string, enabled bool, actor entities.Actor) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.lookupFeed(id)
+	if !ok {
+		return &repository.DBNotFoundError{}
+	} else if before.Enabled == enabled {
+		return nil
+	}
+
+	feed := before
+	feed.Enabled = enabled
+	feed.UpdatedAt = time.Now().UTC()
+	r.feeds[id] = feed
+
+	action := entities.AuditActionDisable
+	if enabled {
+		action = entities.AuditActionEnable
+	}
+	r.recordChange(actor, action, &before, &feed)
+	return nil
+}
+
+// SetFeedFetchState replaces the fetch state of a feed, and schedules its next fetch.
+// The feed UpdatedAt field is left unchanged, as the fetch state changes on every fetch.
+func (r *Repository) SetFeedFetchState(id string, state entities.FeedFetchState, nextFetchAt time.Time) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	feed, ok := r.lookupFeed(id)
+	if !ok {
+		return &repository.DBNotFoundError{}
+	}
+
+	feed.FetchState = state
+	feed.NextFetchAt = nextFetchAt.UTC()
+	r.feeds[id] = feed
+	return nil
+}
+
+// LeaseDueFeeds leases up to limit enabled feeds due to be fetched, and returns them. The feeds due the longest come
+// first.
+// Leased feeds are not leased again until the lease timeout expires, unless their fetch state is reported first.
+func (r *Repository) LeaseDueFeeds(limit int, leaseTimeout time.Duration) (feeds entities.Feeds, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	feeds = entities.Feeds{}
+	for _, feed := range r.feeds {
+		if feed.Enabled && feed.DeletedAt == nil && !feed.NextFetchAt.After(now) {
+			feeds = append(feeds, feed)
+		}
+	}
+
+	sort.Slice(feeds, func(i, j int) bool {
+		if !feeds[i].NextFetchAt.Equal(feeds[j].NextFetchAt) {
+			return feeds[i].NextFetchAt.Before(feeds[j].NextFetchAt)
+		}
+		return feeds[i].URL < feeds[j].URL
+	})
+
+	if limit > 0 && len(feeds) > limit {
+		feeds = feeds[:limit]
+	}
+
+	for i := range feeds {
+		feeds[i].NextFetchAt = now.Add(leaseTimeout)
+		r.feeds[feeds[i].ID] = feeds[i]
+	}
+
+	return feeds, nil
+}
+
+// UpdateFeed applies the changes provided to a feed and returns the updated feed.
+// It returns DBDUPError if the feed canonical URL is changed to one that already exists.
+func (r *Repository) UpdateFeed(id string, update entities.FeedUpdate, actor entities.Actor) (feed entities.Feed,
+	err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.lookupFeed(id)
+	if !ok {
+		return feed, &repository.DBNotFoundError{}
+	}
+	feed = before
+
+	if update.URL != nil {
+		canonicalURL := *update.URL
+		if update.CanonicalURL != nil {
+			canonicalURL = *update.CanonicalURL
+		}
+
+		if r.isDuplicate(*update.URL, canonicalURL, id) {
+			return entities.Feed{}, &repository.DBDUPError{}
+		}
+		feed.URL = *update.URL
+		feed.CanonicalURL = canonicalURL
+	}
+
+	if update.Provider != nil {
+		r.providers.firstOrAdd(*update.Provider)
+		feed.Provider = *update.Provider
+	}
+
+	if update.Category != nil {
+		r.categories.firstOrAdd(*update.Category)
+		feed.Category = *update.Category
+	}
+
+	if update.Enabled != nil {
+		feed.Enabled = *update.Enabled
+	}
+
+	if update.Metadata != nil {
+		feed.FeedMetadata = *update.Metadata
+	}
+
+	if update.PollInterval != nil {
+		feed.PollInterval = *update.PollInterval
+	}
+
+	// Updates leaving the feed as it is are neither applied nor recorded in the audit log
+	if feed == before {
+		return before, nil
+	}
+
+	feed.UpdatedAt = time.Now().UTC()
+	r.feeds[id] = feed
+	r.recordChange(actor, entities.AuditActionUpdate, &before, &feed)
+	return feed, nil
+}
+
+// DeleteFeed soft-deletes a feed. Deleted feeds can be restored until they are purged.
+func (r *Repository) DeleteFeed(id string, actor entities.Actor) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.lookupFeed(id)
+	if !ok {
+		return &repository.DBNotFoundError{}
+	}
+
+	feed := before
+	deletedAt := time.Now().UTC()
+	feed.DeletedAt = &deletedAt
+	r.feeds[id] = feed
+	r.recordChange(actor, entities.AuditActionDelete, &before, &feed)
+	return nil
+}
+
+// RestoreFeed restores a deleted feed, along with its provider and category if they were deleted too, and returns it.
+// Restoring a feed not deleted just returns it.
+func (r *Repository) RestoreFeed(id string, actor entities.Actor) (feed entities.Feed, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.feeds[id]
+	if !ok {
+		return feed, &repository.DBNotFoundError{}
+	} else if before.DeletedAt == nil {
+		return before, nil
+	}
+
+	r.providers.firstOrAdd(before.Provider)
+	r.categories.firstOrAdd(before.Category)
+
+	feed = before
+	feed.DeletedAt = nil
+	feed.UpdatedAt = time.Now().UTC()
+	r.feeds[id] = feed
+	r.recordChange(actor, entities.AuditActionRestore, &before, &feed)
+	return feed, nil
+}
+
+// PurgeDeletedFeeds permanently deletes up to limit feeds deleted before the time provided, the ones deleted the
+// longest first, and returns how many were purged. Zero limit means no limit.
+// Deleted providers and categories left without feeds are deleted too.
+func (r *Repository) PurgeDeletedFeeds(deletedBefore time.Time, limit int, actor entities.Actor) (purged int,
+	err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	feeds := entities.Feeds{}
+	for _, feed := range r.feeds {
+		if feed.DeletedAt != nil && feed.DeletedAt.Before(deletedBefore) {
+			feeds = append(feeds, feed)
+		}
+	}
+
+	sort.Slice(feeds, func(i, j int) bool {
+		if !feeds[i].DeletedAt.Equal(*feeds[j].DeletedAt) {
+			return feeds[i].DeletedAt.Before(*feeds[j].DeletedAt)
+		}
+		return feeds[i].URL < feeds[j].URL
+	})
+
+	if limit > 0 && len(feeds) > limit {
+		feeds = feeds[:limit]
+	}
+
+	for i := range feeds {
+		r.purgeFeed(feeds[i], actor)
+	}
+
+	r.providers.purge(func(name string) bool {
+		return r.hasFeeds(func(feed entities.Feed) bool { return feed.Provider == name })
+	})
+	r.categories.purge(func(name string) bool {
+		return r.hasFeeds(func(feed entities.Feed) bool { return feed.Category == name })
+	})
+
+	return len(feeds), nil
+}
+
+// GetFeedHealth returns the outcome of the latest health checks of a feed.
+func (r *Repository) GetFeedHealth(id string) (health entities.FeedHealth, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.lookupFeed(id); !ok {
+		return health, &repository.DBNotFoundError{}
+	}
+
+	health, ok := r.health[id]
+	if !ok {
+		return entities.FeedHealth{FeedID: id}, nil
+	}
+	return health, nil
+}
+
+// RecordFeedCheck records the outcome of a health check of a feed and returns its updated health.
+// Errors longer than entities.MaxFeedHealthErrorLength are truncated.
+func (r *Repository) RecordFeedCheck(id string, check entities.FeedCheck) (health entities.FeedHealth, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.lookupFeed(id); !ok {
+		return health, &repository.DBNotFoundError{}
+	}
+
+	if errRunes := []rune(check.Error); len(errRunes) > entities.MaxFeedHealthErrorLength {
+		check.Error = string(errRunes[:entities.MaxFeedHealthErrorLength])
+	}
+
+	health, ok := r.health[id]
+	if !ok {
+		health = entities.FeedHealth{FeedID: id}
+	}
+
+	checkedAt := check.CheckedAt.UTC()
+	health.LastCheckedAt = &checkedAt
+	health.LastStatusCode = check.StatusCode
+	health.LastError = check.Error
+	if check.Error == "" {
+		health.LastSuccessAt = &checkedAt
+		health.ConsecutiveFailures = 0
+	} else {
+		health.LastFailureAt = &checkedAt
+		health.ConsecutiveFailures++
+	}
+
+	r.health[id] = health
+	return health, nil
+}
+
+// GetProviders returns all providers along with their feed count, sorted by name.
+func (r *Repository) GetProviders() (providers entities.Providers, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	providers = entities.Providers{}
+	for _, entry := range r.providers.sorted() {
+		feedCount := r.countFeeds(func(feed entities.Feed) bool { return feed.Provider == entry.name })
+		providers = append(providers, entities.Provider{ID: entry.id, Name: entry.name, FeedCount: feedCount})
+	}
+
+	return providers, nil
+}
+
+// AddProvider adds a new provider.
+func (r *Repository) AddProvider(name string) (provider entities.Provider, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := r.providers.add(name)
+	if err != nil {
+		return provider, err
+	}
+
+	return entities.Provider{ID: id, Name: name}, nil
+}
+
+// RenameProvider updates a provider name.
+func (r *Repository) RenameProvider(id uint64, name string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldName, err := r.providers.rename(id, name)
+	if err != nil {
+		return err
+	}
+
+	for id, feed := range r.feeds {
+		if feed.Provider == oldName {
+			feed.Provider = name
+			r.feeds[id] = feed
+		}
+	}
+
+	return nil
+}
+
+// DeleteProvider deletes a provider.
+// Unless cascade is true, providers with feeds cannot be deleted. With cascade, their feeds are deleted too, and can be
+// restored, along with the provider, until purged.
+func (r *Repository) DeleteProvider(id uint64, cascade bool, actor entities.Actor) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name, ok := r.providers.lookupID(id)
+	if !ok {
+		return &repository.DBNotFoundError{}
+	}
+
+	match := func(feed entities.Feed) bool { return feed.Provider == name }
+	if err := r.deleteFeeds(match, cascade, actor); err != nil {
+		return err
+	}
+
+	r.providers.delete(id, r.hasFeeds(match))
+	return nil
+}
+
+// GetCategories returns all categories along with their feed count, sorted by name.
+func (r *Repository) GetCategories() (categories entities.Categories, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	categories = entities.Categories{}
+	for _, entry := range r.categories.sorted() {
+		feedCount := r.countFeeds(func(feed entities.Feed) bool { return feed.Category == entry.name })
+		categories = append(categories, entities.Category{ID: entry.id, Name: entry.name, FeedCount: feedCount})
+	}
+
+	return categories, nil
+}
+
+// AddCategory adds a new category.
+func (r *Repository) AddCategory(name string) (category entities.Category, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := r.categories.add(name)
+	if err != nil {
+		return category, err
+	}
+
+	return entities.Category{ID: id, Name: name}, nil
+}
+
+// RenameCategory updates a category name.
+func (r *Repository) RenameCategory(id uint64, name string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldName, err := r.categories.rename(id, name)
+	if err != nil {
+		return err
+	}
+
+	for id, feed := range r.feeds {
+		if feed.Category == oldName {
+			feed.Category = name
+			r.feeds[id] = feed
+		}
+	}
+
+	return nil
+}
+
+// DeleteCategory deletes a category.
+// Unless cascade is true, categories with feeds cannot be deleted. With cascade, their feeds are deleted too, and can be
+// restored, along with the category, until purged.
+func (r *Repository) DeleteCategory(id uint64, cascade bool, actor entities.Actor) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name, ok := r.categories.lookupID(id)
+	if !ok {
+		return &repository.DBNotFoundError{}
+	}
+
+	match := func(feed entities.Feed) bool { return feed.Category == name }
+	if err := r.deleteFeeds(match, cascade, actor); err != nil {
+		return err
+	}
+
+	r.categories.delete(id, r.hasFeeds(match))
+	return nil
+}
+
+// GetAPIKeys returns all the API keys issued, sorted by name.
+func (r *Repository) GetAPIKeys() (keys entities.APIKeys, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys = make(entities.APIKeys, 0, len(r.apiKeys))
+	for _, entry := range r.apiKeys {
+		keys = append(keys, entry.key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+// GetAPIKeyByHash returns the API key with the key hash provided.
+func (r *Repository) GetAPIKeyByHash(keyHash string) (key entities.APIKey, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.apiKeys {
+		if entry.keyHash == keyHash {
+			return entry.key, nil
+		}
+	}
+
+	return key, &repository.DBNotFoundError{}
+}
+
+// AddAPIKey adds a new API key, stored by the hash of the key, and returns it along with its newly assigned ID.
+// It returns DBDUPError if there is a key with the same name or hash already.
+func (r *Repository) AddAPIKey(key entities.APIKey, keyHash string) (created entities.APIKey, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range r.apiKeys {
+		if entry.key.Name == key.Name || entry.keyHash == keyHash {
+			return created, &repository.DBDUPError{}
+		}
+	}
+
+	created = entities.APIKey{
+		ID:        uuid.New().String(),
+		Name:      key.Name,
+		Scopes:    append([]string{}, key.Scopes...),
+		CreatedAt: time.Now().UTC(),
+	}
+	r.apiKeys[created.ID] = apiKeyEntry{key: created, keyHash: keyHash}
+	return created, nil
+}
+
+// DeleteAPIKey deletes an API key, revoking it.
+func (r *Repository) DeleteAPIKey(id string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.apiKeys[id]; !ok {
+		return &repository.DBNotFoundError{}
+	}
+
+	delete(r.apiKeys, id)
+	return nil
+}
+
+// GetAuditEntries returns a page of audit log entries matching a certain criteria, the most recent first.
+func (r *Repository) GetAuditEntries(query entities.AuditQuery) (page entities.AuditPage, err error) {
+	var beforeID uint64
+	if query.Cursor != "" {
+		if beforeID, err = repository.DecodeAuditCursor(query.Cursor); err != nil {
+			return page, err
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	page.Entries = entities.AuditEntries{}
+
+	for i := len(r.audit) - 1; i >= 0; i-- {
+		entry := r.audit[i]
+		if beforeID != 0 && entry.ID >= beforeID {
+			continue
+		}
+		if query.FeedID != "" && query.FeedID != entry.FeedID {
+			continue
+		}
+		if query.Actor != "" && query.Actor != entry.Actor {
+			continue
+		}
+		if !query.Since.IsZero() && entry.CreatedAt.Before(query.Since) {
+			continue
+		}
+		if !query.Until.IsZero() && !entry.CreatedAt.Before(query.Until) {
+			continue
+		}
+
+		if query.Limit > 0 && len(page.Entries) == query.Limit {
+			page.NextCursor = repository.EncodeAuditCursor(page.Entries[query.Limit-1].ID)
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+
+	return page, nil
+}
+
+// addFeed adds a new feed, along with its provider and category if they don't exist, and returns it.
+// The caller must hold the lock.
+func (r *Repository) addFeed(feed entities.Feed, actor entities.Actor) entities.Feed {
+	r.providers.firstOrAdd(feed.Provider)
+	r.categories.firstOrAdd(feed.Category)
+
+	feed.ID = uuid.New().String()
+	feed.CanonicalURL = repository.FeedCanonicalURL(feed)
+	feed.CreatedAt = time.Now().UTC()
+	feed.UpdatedAt = feed.CreatedAt
+	feed.NextFetchAt = feed.CreatedAt
+	r.feeds[feed.ID] = feed
+	r.recordChange(actor, entities.AuditActionCreate, nil, &feed)
+	return feed
+}
+
+// recordChange records a change to a feed in the audit log, with the feed before and after the change.
+// The caller must hold the lock.
+func (r *Repository) recordChange(actor entities.Actor, action string, before *entities.Feed, after *entities.Feed) {
+	entry := entities.AuditEntry{
+		ID:        uint64(len(r.audit)) + 1,
+		Action:    action,
+		Actor:     truncate(actor.Name, entities.MaxAuditActorLength),
+		RequestID: truncate(actor.RequestID, entities.MaxAuditRequestIDLength),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Snapshots are copies, so that later changes to the feed don't alter them
+	if before != nil {
+		snapshot := *before
+		entry.FeedID, entry.Before = snapshot.ID, &snapshot
+	}
+	if after != nil {
+		snapshot := *after
+		entry.FeedID, entry.After = snapshot.ID, &snapshot
+	}
+
+	r.audit = append(r.audit, entry)
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// matchLanguage checks if the language is the one in the filter or one of its regional variants.
+func matchLanguage(language string, filter string) bool {
+	filter = strings.ToLower(filter)
+	return language == filter || strings.HasPrefix(language, filter+"-")
+}
+
+// lookupFeed returns the feed with the ID provided, unless it's deleted.
+// The caller must hold the lock.
+func (r *Repository) lookupFeed(id string) (feed entities.Feed, ok bool) {
+	feed, ok = r.feeds[id]
+	if !ok || feed.DeletedAt != nil {
+		return entities.Feed{}, false
+	}
+	return feed, true
+}
+
+// lookupCanonicalURL returns the feed with the canonical URL provided, unless it's deleted.
+// The caller must hold the lock.
+func (r *Repository) lookupCanonicalURL(canonicalURL string) (feed entities.Feed, ok bool) {
+	for _, feed := range r.feeds {
+		if feed.CanonicalURL == canonicalURL && feed.DeletedAt == nil {
+			return feed, true
+		}
+	}
+	return feed, false
+}
+
+// isDuplicate checks whether a feed other than the one with the ID provided has either the URL or the canonical URL
+// provided. Deleted feeds hold on to their URLs until purged.
+// The caller must hold the lock.
+func (r *Repository) isDuplicate(url string, canonicalURL string, id string) bool {
+	for _, feed := range r.feeds {
+		if feed.ID != id && (feed.URL == url || feed.CanonicalURL == canonicalURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// countFeeds counts the feeds matching the predicate, leaving deleted feeds out.
+// The caller must hold the lock.
+func (r *Repository) countFeeds(match func(feed entities.Feed) bool) (count int64) {
+	for _, feed := range r.feeds {
+		if feed.DeletedAt == nil && match(feed) {
+			count++
+		}
+	}
+	return count
+}
+
+// hasFeeds checks whether any feeds match the predicate, deleted feeds included.
+// The caller must hold the lock.
+func (r *Repository) hasFeeds(match func(feed entities.Feed) bool) bool {
+	for _, feed := range r.feeds {
+		if match(feed) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteFeeds deletes the feeds matching the predicate if cascade is true, otherwise returns DBInUseError if there
+// are any. Deleted feeds are left as they are.
+// The caller must hold the lock.
+func (r *Repository) deleteFeeds(match func(feed entities.Feed) bool, cascade bool, actor entities.Actor) error {
+	if !cascade && r.countFeeds(match) != 0 {
+		return &repository.DBInUseError{}
+	}
+
+	deletedAt := time.Now().UTC()
+	for id, before := range r.feeds {
+		if before.DeletedAt != nil || !match(before) {
+			continue
+		}
+
+		feed := before
+		feed.DeletedAt = &deletedAt
+		r.feeds[id] = feed
+		r.recordChange(actor, entities.AuditActionDelete, &before, &feed)
+	}
+	return nil
+}
+
+// purgeFeed permanently deletes a deleted feed along with its health, recording it in the audit log.
+// The caller must hold the lock.
+func (r *Repository) purgeFeed(feed entities.Feed, actor entities.Actor) {
+	delete(r.feeds, feed.ID)
+	delete(r.health, feed.ID)
+	r.recordChange(actor, entities.AuditActionPurge, &feed, nil)
+}
+
+// namedEntries holds providers or categories, keyed by ID.
+// Deleted entries are kept, holding on to their name, until purged, so that deleted feeds can be restored along with
+// them.
+type namedEntries struct {
+	lastID  uint64
+	names   map[uint64]string
+	deleted map[uint64]bool
+}
+
+// namedEntry represents a provider or category.
+type namedEntry struct {
+	id   uint64
+	name string
+}
+
+func newNamedEntries() *namedEntries {
+	return &namedEntries{names: make(map[uint64]string), deleted: make(map[uint64]bool)}
+}
+
+// lookupID returns the name of the entry with the ID provided, unless it's deleted.
+func (n *namedEntries) lookupID(id uint64) (name string, ok bool) {
+	name, ok = n.names[id]
+	if !ok || n.deleted[id] {
+		return "", false
+	}
+	return name, true
+}
+
+// lookup returns the ID of the entry with the name provided, deleted entries included.
+func (n *namedEntries) lookup(name string) (id uint64, ok bool) {
+	for id, entryName := range n.names {
+		if entryName == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// add adds a new entry, failing with DBDUPError if the name is already taken. A deleted entry with the same name is
+// restored instead.
+func (n *namedEntries) add(name string) (id uint64, err error) {
+	if id, ok := n.lookup(name); ok && n.deleted[id] {
+		delete(n.deleted, id)
+		return id, nil
+	} else if ok {
+		return 0, &repository.DBDUPError{}
+	}
+
+	n.lastID++
+	n.names[n.lastID] = name
+	return n.lastID, nil
+}
+
+// firstOrAdd adds a new entry, unless there is one with the same name already, which is restored if deleted.
+func (n *namedEntries) firstOrAdd(name string) {
+	// Only fails if there is an entry not deleted already
+	_, _ = n.add(name)
+}
+
+// rename renames an entry and returns its old name.
+func (n *namedEntries) rename(id uint64, name string) (oldName string, err error) {
+	oldName, ok := n.lookupID(id)
+	if !ok {
+		return "", &repository.DBNotFoundError{}
+	}
+
+	if otherID, ok := n.lookup(name); ok && otherID != id {
+		return "", &repository.DBDUPError{}
+	}
+
+	n.names[id] = name
+	return oldName, nil
+}
+
+// delete deletes an entry, or only marks it as deleted if it must be kept.
+func (n *namedEntries) delete(id uint64, keep bool) {
+	if keep {
+		n.deleted[id] = true
+		return
+	}
+
+	delete(n.names, id)
+	delete(n.deleted, id)
+}
+
+// purge permanently deletes the deleted entries no longer referenced, as the predicate tells by name.
+func (n *namedEntries) purge(referenced func(name string) bool) {
+	for id := range n.deleted {
+		if !referenced(n.names[id]) {
+			n.delete(id, false)
+		}
+	}
+}
+
+// sorted returns all entries not deleted, sorted by name.
+func (n *namedEntries) sorted() []namedEntry {
+	entries := make([]namedEntry, 0, len(n.names))
+	for id, name := range n.names {
+		if n.deleted[id] {
+			continue
+		}
+		entries = append(entries, namedEntry{id: id, name: name})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
@@ -0,0 +1,908 @@
+package memory_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	trueV  = true
+	falseV = false
+)
+
+// Make sure the in-memory repository satisfies the interface.
+var _ core.Repository = &memory.Repository{}
+
+func TestGetFeeds(t *testing.T) {
+	repo := setupRepository(t)
+
+	tests := map[string]struct {
+		provider     string
+		category     string
+		enabled      *bool
+		expectedURLs []string
+	}{
+		"all enabled": {
+			enabled: &trueV,
+			expectedURLs: []string{
+				"http://feeds.bbci.co.uk/news/technology/rss.xml",
+				"http://feeds.bbci.co.uk/news/uk/rss.xml",
+				"http://feeds.skynews.com/feeds/rss/technology.xml"}},
+		"all disabled": {
+			enabled:      &falseV,
+			expectedURLs: []string{"http://feeds.skynews.com/feeds/rss/uk.xml"}},
+		"any state": {
+			provider: "Sky News",
+			expectedURLs: []string{
+				"http://feeds.skynews.com/feeds/rss/technology.xml",
+				"http://feeds.skynews.com/feeds/rss/uk.xml"}},
+		"provider filter": {
+			provider:     "Sky News",
+			enabled:      &trueV,
+			expectedURLs: []string{"http://feeds.skynews.com/feeds/rss/technology.xml"}},
+		"category filter": {
+			category: "Technology",
+			enabled:  &trueV,
+			expectedURLs: []string{
+				"http://feeds.bbci.co.uk/news/technology/rss.xml",
+				"http://feeds.skynews.com/feeds/rss/technology.xml"}},
+		"no match": {
+			provider:     "Unknown",
+			enabled:      &trueV,
+			expectedURLs: []string{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			page, err := repo.GetFeeds(entities.FeedQuery{Provider: test.provider, Category: test.category, Enabled: test.enabled})
+			require.NoError(t, err)
+
+			urls := []string{}
+			for _, feed := range page.Feeds {
+				urls = append(urls, feed.URL)
+			}
+			assert.Equal(t, test.expectedURLs, urls)
+		})
+	}
+}
+
+func TestGetFeedsPagination(t *testing.T) {
+	repo := setupRepository(t)
+
+	bbcTech := "http://feeds.bbci.co.uk/news/technology/rss.xml"
+	bbcUK := "http://feeds.bbci.co.uk/news/uk/rss.xml"
+	skyTech := "http://feeds.skynews.com/feeds/rss/technology.xml"
+
+	tests := map[string][]string{
+		"url":         {bbcTech, bbcUK, skyTech},
+		"-url":        {skyTech, bbcUK, bbcTech},
+		"provider":    {bbcTech, bbcUK, skyTech},
+		"-provider":   {skyTech, bbcUK, bbcTech},
+		"category":    {bbcTech, skyTech, bbcUK},
+		"-category":   {bbcUK, skyTech, bbcTech},
+		"created_at":  {bbcTech, bbcUK, skyTech},
+		"-created_at": {skyTech, bbcUK, bbcTech},
+	}
+
+	for sort, expectedURLs := range tests {
+		for _, limit := range []int{1, 2, 3} {
+			t.Run(fmt.Sprintf("%s limit %d", sort, limit), func(t *testing.T) {
+				urls := []string{}
+				query := entities.FeedQuery{Enabled: &trueV, Limit: limit, Sort: sort}
+
+				for {
+					page, err := repo.GetFeeds(query)
+					require.NoError(t, err)
+					assert.EqualValues(t, 3, page.Total)
+					assert.LessOrEqual(t, len(page.Feeds), limit)
+
+					for _, feed := range page.Feeds {
+						urls = append(urls, feed.URL)
+					}
+
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+
+				assert.Equal(t, expectedURLs, urls)
+			})
+		}
+	}
+
+	// Cursors are only valid for the sort order they were generated for
+	page, err := repo.GetFeeds(entities.FeedQuery{Enabled: &trueV, Limit: 1, Sort: "url"})
+	require.NoError(t, err)
+	_, err = repo.GetFeeds(entities.FeedQuery{Enabled: &trueV, Limit: 1, Sort: "-url", Cursor: page.NextCursor})
+	assert.IsType(t, &repository.DBInvalidCursorError{}, err)
+
+	_, err = repo.GetFeeds(entities.FeedQuery{Enabled: &trueV, Limit: 1, Sort: "url", Cursor: "invalid"})
+	assert.IsType(t, &repository.DBInvalidCursorError{}, err)
+}
+
+func TestIterateFeeds(t *testing.T) {
+	repo := setupRepository(t)
+
+	urls := []string{}
+	query := entities.FeedQuery{Enabled: &trueV, Limit: 2, Sort: "-url"}
+	err := repo.IterateFeeds(query, func(feed entities.Feed) error {
+		urls = append(urls, feed.URL)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"http://feeds.skynews.com/feeds/rss/technology.xml",
+		"http://feeds.bbci.co.uk/news/uk/rss.xml"}, urls)
+
+	errStop := fmt.Errorf("stop")
+	err = repo.IterateFeeds(entities.FeedQuery{}, func(feed entities.Feed) error {
+		return errStop
+	})
+	assert.Equal(t, errStop, err)
+}
+
+func TestGetFeed(t *testing.T) {
+	repo := setupRepository(t)
+
+	_, err := repo.GetFeed("00000000-0000-0000-0000-000000000000")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	_, err = repo.GetFeedByURL("http://url.does.not.exist.com")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	feed, err := repo.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+	require.NoError(t, err)
+	assert.NotEmpty(t, feed.ID)
+	assert.Equal(t, "Sky News", feed.Provider)
+
+	sameFeed, err := repo.GetFeed(feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, feed, sameFeed)
+
+	// Feeds are looked up by their canonical URL
+	added, err := repo.AddFeed(entities.Feed{URL: "http://example.com/rss.xml?utm_source=x",
+		CanonicalURL: "https://example.com/rss.xml", Provider: "Example", Category: "UK"}, entities.Actor{})
+	require.NoError(t, err)
+	feed, err = repo.GetFeedByURL("https://example.com/rss.xml")
+	require.NoError(t, err)
+	assert.Equal(t, added.ID, feed.ID)
+	_, err = repo.GetFeedByURL("http://example.com/rss.xml?utm_source=x")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+}
+
+func TestAddFeed(t *testing.T) {
+	repo := setupRepository(t)
+
+	_, err := repo.AddFeed(entities.Feed{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK"}, entities.Actor{})
+	assert.IsType(t, &repository.DBDUPError{}, err)
+
+	created, err := repo.AddFeed(entities.Feed{URL: "http://example.com/rss.xml", Provider: "Example", Category: "UK", Enabled: true}, entities.Actor{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+
+	page, err := repo.GetFeeds(entities.FeedQuery{Provider: "Example", Enabled: &trueV})
+	require.NoError(t, err)
+	require.Len(t, page.Feeds, 1)
+	assert.Equal(t, created, page.Feeds[0])
+}
+
+func TestAddFeeds(t *testing.T) {
+	repo := setupRepository(t)
+
+	statuses := func(results entities.FeedBatchResults) []string {
+		result := []string{}
+		for _, r := range results {
+			result = append(result, r.Status)
+		}
+		return result
+	}
+
+	// Dry runs add nothing
+	results, err := repo.AddFeeds(entities.Feeds{
+		{URL: "http://example.com/1.xml", Provider: "Example", Category: "UK", Enabled: true},
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK"},
+	}, entities.FeedBatchOptions{DryRun: true}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{entities.FeedBatchValid, entities.FeedBatchDuplicate}, statuses(results))
+
+	// Atomic batches with duplicates add nothing
+	results, err = repo.AddFeeds(entities.Feeds{
+		{URL: "http://example.com/1.xml", Provider: "Example", Category: "UK", Enabled: true},
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK"},
+	}, entities.FeedBatchOptions{Atomic: true}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{entities.FeedBatchSkipped, entities.FeedBatchDuplicate}, statuses(results))
+
+	_, err = repo.GetFeedByURL("http://example.com/1.xml")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	results, err = repo.AddFeeds(entities.Feeds{
+		{URL: "http://example.com/1.xml", Provider: "Example", Category: "UK", Enabled: true},
+		{URL: "http://example.com/2.xml", Provider: "Example", Category: "World", Enabled: true},
+	}, entities.FeedBatchOptions{Atomic: true}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{entities.FeedBatchCreated, entities.FeedBatchCreated}, statuses(results))
+
+	feed, err := repo.GetFeedByURL("http://example.com/2.xml")
+	require.NoError(t, err)
+	assert.Equal(t, results[1].Feed, feed)
+
+	// Otherwise feeds are added independently
+	results, err = repo.AddFeeds(entities.Feeds{
+		{URL: "http://example.com/2.xml", Provider: "Example", Category: "UK", Enabled: true},
+		{URL: "http://example.com/3.xml", Provider: "Example", Category: "UK", Enabled: true},
+		{URL: "http://example.com/3.xml", Provider: "Example", Category: "UK", Enabled: true},
+	}, entities.FeedBatchOptions{}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{entities.FeedBatchDuplicate, entities.FeedBatchCreated, entities.FeedBatchDuplicate}, statuses(results))
+	assert.NotEmpty(t, results[1].Feed.ID)
+
+	page, err := repo.GetFeeds(entities.FeedQuery{Provider: "Example"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, page.Total)
+}
+
+func TestFeedCanonicalURL(t *testing.T) {
+	repo := setupRepository(t)
+
+	canonicalURL := "https://example.com/rss.xml"
+
+	created, err := repo.AddFeed(entities.Feed{URL: "http://Example.com/rss.xml/", CanonicalURL: canonicalURL,
+		Provider: "Example", Category: "UK"}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, canonicalURL, created.CanonicalURL)
+
+	other, err := repo.AddFeed(entities.Feed{URL: "http://example.com/other.xml", Provider: "Example", Category: "UK"}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/other.xml", other.CanonicalURL)
+
+	_, err = repo.AddFeed(entities.Feed{URL: "https://example.com/rss.xml?utm_source=x", CanonicalURL: canonicalURL,
+		Provider: "Example", Category: "UK"}, entities.Actor{})
+	assert.IsType(t, &repository.DBDUPError{}, err)
+
+	results, err := repo.AddFeeds(entities.Feeds{
+		{URL: "https://example.com/new.xml", CanonicalURL: "https://example.com/new.xml", Provider: "Example",
+			Category: "UK"},
+		{URL: "https://example.com/new.xml/", CanonicalURL: "https://example.com/new.xml", Provider: "Example",
+			Category: "UK"},
+	}, entities.FeedBatchOptions{DryRun: true}, entities.Actor{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, entities.FeedBatchValid, results[0].Status)
+	assert.Equal(t, entities.FeedBatchDuplicate, results[1].Status)
+
+	otherURL := "http://EXAMPLE.com/rss.xml"
+	_, err = repo.UpdateFeed(other.ID, entities.FeedUpdate{URL: &otherURL, CanonicalURL: &canonicalURL}, entities.Actor{})
+	assert.IsType(t, &repository.DBDUPError{}, err)
+
+	updated, err := repo.UpdateFeed(other.ID, entities.FeedUpdate{URL: &otherURL}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, otherURL, updated.CanonicalURL)
+}
+
+func TestSetFeedState(t *testing.T) {
+	repo := setupRepository(t)
+
+	err := repo.SetFeedState("00000000-0000-0000-0000-000000000000", true, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	feed, err := repo.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+	require.NoError(t, err)
+	err = repo.SetFeedState(feed.ID, true, entities.Actor{})
+	require.NoError(t, err)
+
+	page, err := repo.GetFeeds(entities.FeedQuery{Provider: "Sky News", Category: "UK", Enabled: &trueV})
+	require.NoError(t, err)
+	assert.Len(t, page.Feeds, 1)
+}
+
+func TestSetFeedFetchState(t *testing.T) {
+	repo := setupRepository(t)
+
+	state := entities.FeedFetchState{ETag: `"5e8f-1a2b"`, LastModified: "Sat, 01 May 2021 10:00:00 GMT",
+		UpdateFrequency: 1800}
+
+	nextFetchAt := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	err := repo.SetFeedFetchState("00000000-0000-0000-0000-000000000000", state, nextFetchAt)
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	feed, err := repo.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+	require.NoError(t, err)
+	err = repo.SetFeedFetchState(feed.ID, state, nextFetchAt)
+	require.NoError(t, err)
+
+	updated, err := repo.GetFeed(feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, state, updated.FetchState)
+	assert.True(t, nextFetchAt.Equal(updated.NextFetchAt))
+	assert.Equal(t, feed.UpdatedAt, updated.UpdatedAt)
+}
+
+func TestLeaseDueFeeds(t *testing.T) {
+	repo := setupRepository(t)
+
+	feeds, err := repo.LeaseDueFeeds(2, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, feeds, 2)
+
+	feeds, err = repo.LeaseDueFeeds(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, feeds, 1)
+	assert.NotEqual(t, "http://feeds.skynews.com/feeds/rss/uk.xml", feeds[0].URL)
+
+	feeds, err = repo.LeaseDueFeeds(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, feeds)
+
+	feed, err := repo.GetFeedByURL("http://feeds.bbci.co.uk/news/uk/rss.xml")
+	require.NoError(t, err)
+	err = repo.SetFeedFetchState(feed.ID, entities.FeedFetchState{}, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+
+	feeds, err = repo.LeaseDueFeeds(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, feeds, 1)
+	assert.Equal(t, "http://feeds.bbci.co.uk/news/uk/rss.xml", feeds[0].URL)
+}
+
+func TestUpdateFeed(t *testing.T) {
+	repo := setupRepository(t)
+
+	_, err := repo.UpdateFeed("00000000-0000-0000-0000-000000000000", entities.FeedUpdate{Enabled: &falseV}, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	feed, err := repo.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+	require.NoError(t, err)
+
+	// Changes are not applied at all when the new URL is taken
+	takenURL := "http://feeds.bbci.co.uk/news/uk/rss.xml"
+	newProvider := "Sky"
+	_, err = repo.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &takenURL, Provider: &newProvider}, entities.Actor{})
+	assert.IsType(t, &repository.DBDUPError{}, err)
+
+	providers, err := repo.GetProviders()
+	require.NoError(t, err)
+	assert.Len(t, providers, 2)
+
+	newURL := "https://feeds.skynews.com/feeds/rss/uk.xml"
+	newCategory := "World"
+	updated, err := repo.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &newURL, Provider: &newProvider, Category: &newCategory, Enabled: &trueV}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, feed.ID, updated.ID)
+	assert.Equal(t, newURL, updated.URL)
+	assert.Equal(t, "Sky", updated.Provider)
+	assert.Equal(t, "World", updated.Category)
+	assert.True(t, updated.Enabled)
+	assert.True(t, updated.CreatedAt.Equal(feed.CreatedAt))
+
+	sameFeed, err := repo.GetFeed(feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, updated, sameFeed)
+
+	_, err = repo.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	// Setting the URL a feed already has is not a conflict
+	_, err = repo.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &newURL}, entities.Actor{})
+	require.NoError(t, err)
+}
+
+func TestFeedMetadata(t *testing.T) {
+	repo := setupRepository(t)
+
+	feeds := entities.Feeds{
+		{URL: "https://www.rtp.pt/noticias/rss", FeedMetadata: entities.FeedMetadata{Language: "pt-pt"}},
+		{URL: "https://g1.globo.com/rss/g1/", FeedMetadata: entities.FeedMetadata{Language: "pt-br"}},
+		{URL: "https://www.ptinews.com/rss", FeedMetadata: entities.FeedMetadata{Language: "ptx"}},
+	}
+
+	for _, feed := range feeds {
+		feed.Provider = "Example"
+		feed.Category = "World"
+		_, err := repo.AddFeed(feed, entities.Actor{})
+		require.NoError(t, err)
+	}
+
+	page, err := repo.GetFeeds(entities.FeedQuery{Language: "PT", Sort: "url"})
+	require.NoError(t, err)
+	require.Len(t, page.Feeds, 2)
+	assert.Equal(t, "https://g1.globo.com/rss/g1/", page.Feeds[0].URL)
+	assert.Equal(t, "https://www.rtp.pt/noticias/rss", page.Feeds[1].URL)
+
+	metadata := entities.FeedMetadata{Title: "RTP", Language: "pt"}
+	updated, err := repo.UpdateFeed(page.Feeds[1].ID, entities.FeedUpdate{Metadata: &metadata}, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, metadata, updated.FeedMetadata)
+}
+
+func TestFeedHealth(t *testing.T) {
+	repo := setupRepository(t)
+
+	_, err := repo.RecordFeedCheck("00000000-0000-0000-0000-000000000000", entities.FeedCheck{CheckedAt: time.Now()})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	feed, err := repo.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+	require.NoError(t, err)
+
+	health, err := repo.GetFeedHealth(feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.FeedHealth{FeedID: feed.ID}, health)
+
+	checkedAt := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	_, err = repo.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: checkedAt, StatusCode: 200})
+	require.NoError(t, err)
+	for i := 1; i <= 2; i++ {
+		health, err = repo.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: checkedAt.Add(time.Hour),
+			Error: "error fetching document"})
+		require.NoError(t, err)
+		assert.Equal(t, i, health.ConsecutiveFailures)
+	}
+	assert.Equal(t, 0, health.LastStatusCode)
+	assert.Equal(t, checkedAt, *health.LastSuccessAt)
+
+	health, err = repo.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: checkedAt.Add(2 * time.Hour),
+		StatusCode: 200})
+	require.NoError(t, err)
+	assert.Equal(t, 0, health.ConsecutiveFailures)
+	assert.Equal(t, checkedAt.Add(time.Hour), *health.LastFailureAt)
+
+	err = repo.DeleteFeed(feed.ID, entities.Actor{})
+	require.NoError(t, err)
+	_, err = repo.GetFeedHealth(feed.ID)
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+}
+
+func TestDeleteFeed(t *testing.T) {
+	repo := setupRepository(t)
+
+	err := repo.DeleteFeed("00000000-0000-0000-0000-000000000000", entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	feed, err := repo.GetFeedByURL("http://feeds.bbci.co.uk/news/uk/rss.xml")
+	require.NoError(t, err)
+	err = repo.DeleteFeed(feed.ID, entities.Actor{})
+	require.NoError(t, err)
+
+	page, err := repo.GetFeeds(entities.FeedQuery{Provider: "BBC News", Category: "UK", Enabled: &trueV})
+	require.NoError(t, err)
+	assert.Len(t, page.Feeds, 0)
+
+	// Deleted feeds are kept until purged
+	page, err = repo.GetFeeds(entities.FeedQuery{Provider: "BBC News", Category: "UK", IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, page.Feeds, 1)
+	assert.Equal(t, feed.ID, page.Feeds[0].ID)
+	assert.NotNil(t, page.Feeds[0].DeletedAt)
+
+	_, err = repo.GetFeed(feed.ID)
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+	err = repo.SetFeedState(feed.ID, false, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+	err = repo.DeleteFeed(feed.ID, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	providers, err := repo.GetProviders()
+	require.NoError(t, err)
+	assert.Equal(t, "BBC News", providers[0].Name)
+	assert.EqualValues(t, 1, providers[0].FeedCount)
+
+	// They hold on to their URL
+	_, err = repo.AddFeed(entities.Feed{URL: feed.URL, Provider: "BBC News", Category: "UK"}, entities.Actor{})
+	assert.IsType(t, &repository.DBDUPError{}, err)
+}
+
+func TestRestoreFeed(t *testing.T) {
+	repo := setupRepository(t)
+
+	_, err := repo.RestoreFeed("00000000-0000-0000-0000-000000000000", entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	feed, err := repo.GetFeedByURL("http://feeds.bbci.co.uk/news/uk/rss.xml")
+	require.NoError(t, err)
+	_, err = repo.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: time.Now(), StatusCode: 200})
+	require.NoError(t, err)
+	err = repo.DeleteFeed(feed.ID, entities.Actor{})
+	require.NoError(t, err)
+
+	restored, err := repo.RestoreFeed(feed.ID, entities.Actor{Name: "user:jane"})
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, feed.URL, restored.URL)
+	assert.Equal(t, "BBC News", restored.Provider)
+	assert.Equal(t, "UK", restored.Category)
+
+	// Health is kept along with the feed
+	health, err := repo.GetFeedHealth(feed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 200, health.LastStatusCode)
+
+	page, err := repo.GetAuditEntries(entities.AuditQuery{FeedID: feed.ID})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 3)
+	assert.Equal(t, entities.AuditActionRestore, page.Entries[0].Action)
+	assert.Equal(t, "user:jane", page.Entries[0].Actor)
+	assert.NotNil(t, page.Entries[0].Before.DeletedAt)
+	assert.Nil(t, page.Entries[0].After.DeletedAt)
+
+	// Restoring a feed not deleted changes nothing
+	restored, err = repo.RestoreFeed(feed.ID, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, feed.ID, restored.ID)
+
+	page, err = repo.GetAuditEntries(entities.AuditQuery{FeedID: feed.ID})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 3)
+}
+
+func TestPurgeDeletedFeeds(t *testing.T) {
+	repo := setupRepository(t)
+
+	page, err := repo.GetFeeds(entities.FeedQuery{Provider: "BBC News"})
+	require.NoError(t, err)
+	require.Len(t, page.Feeds, 2)
+	purgedFeed, keptFeed := page.Feeds[0], page.Feeds[1]
+	for _, feed := range page.Feeds {
+		err = repo.DeleteFeed(feed.ID, entities.Actor{})
+		require.NoError(t, err)
+	}
+
+	// Feeds deleted after the time provided are kept
+	purged, err := repo.PurgeDeletedFeeds(time.Now().Add(-time.Hour), 10, entities.Actor{Name: "purge"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	// The feeds deleted the longest go first
+	purged, err = repo.PurgeDeletedFeeds(time.Now().Add(time.Second), 1, entities.Actor{Name: "purge"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = repo.RestoreFeed(purgedFeed.ID, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	audit, err := repo.GetAuditEntries(entities.AuditQuery{FeedID: purgedFeed.ID})
+	require.NoError(t, err)
+	require.Len(t, audit.Entries, 3)
+	assert.Equal(t, entities.AuditActionPurge, audit.Entries[0].Action)
+	assert.Equal(t, "purge", audit.Entries[0].Actor)
+	assert.Nil(t, audit.Entries[0].After)
+
+	// Purged feeds free their URL
+	readded, err := repo.AddFeed(entities.Feed{URL: purgedFeed.URL, Provider: "BBC News", Category: "UK"},
+		entities.Actor{})
+	require.NoError(t, err)
+
+	// Providers with feeds are only deleted with cascade, which deletes their feeds too
+	providers, err := repo.GetProviders()
+	require.NoError(t, err)
+	bbc := providers[0]
+	require.Equal(t, "BBC News", bbc.Name)
+	err = repo.DeleteProvider(bbc.ID, false, entities.Actor{})
+	assert.IsType(t, &repository.DBInUseError{}, err)
+	err = repo.DeleteProvider(bbc.ID, true, entities.Actor{Name: "admin"})
+	require.NoError(t, err)
+
+	_, err = repo.GetFeed(readded.ID)
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+	audit, err = repo.GetAuditEntries(entities.AuditQuery{FeedID: readded.ID})
+	require.NoError(t, err)
+	assert.Equal(t, entities.AuditActionDelete, audit.Entries[0].Action)
+	assert.Equal(t, "admin", audit.Entries[0].Actor)
+	require.NotNil(t, audit.Entries[0].After)
+	assert.NotNil(t, audit.Entries[0].After.DeletedAt)
+
+	providers, err = repo.GetProviders()
+	require.NoError(t, err)
+	assert.Len(t, providers, 1)
+	err = repo.DeleteProvider(bbc.ID, true, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	// Deleted feeds keep their provider until purged, so that they can still be restored along with it
+	restored, err := repo.RestoreFeed(readded.ID, entities.Actor{})
+	require.NoError(t, err)
+	assert.Equal(t, "BBC News", restored.Provider)
+	providers, err = repo.GetProviders()
+	require.NoError(t, err)
+	assert.Equal(t, entities.Provider{ID: bbc.ID, Name: "BBC News", FeedCount: 1}, providers[0])
+
+	// Providers with deleted feeds only are deleted without cascade
+	err = repo.DeleteFeed(readded.ID, entities.Actor{})
+	require.NoError(t, err)
+	err = repo.DeleteProvider(bbc.ID, false, entities.Actor{})
+	require.NoError(t, err)
+
+	// Adding the provider again restores it
+	provider, err := repo.AddProvider("BBC News")
+	require.NoError(t, err)
+	assert.Equal(t, bbc.ID, provider.ID)
+	err = repo.DeleteProvider(bbc.ID, false, entities.Actor{})
+	require.NoError(t, err)
+
+	// Once its feeds are purged, the provider is gone for good
+	purged, err = repo.PurgeDeletedFeeds(time.Now().Add(time.Second), 0, entities.Actor{Name: "purge"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	page, err = repo.GetFeeds(entities.FeedQuery{Provider: "BBC News", IncludeDeleted: true})
+	require.NoError(t, err)
+	assert.Empty(t, page.Feeds)
+
+	provider, err = repo.AddProvider("BBC News")
+	require.NoError(t, err)
+	assert.NotEqual(t, bbc.ID, provider.ID)
+
+	audit, err = repo.GetAuditEntries(entities.AuditQuery{FeedID: keptFeed.ID})
+	require.NoError(t, err)
+	require.NotEmpty(t, audit.Entries)
+	assert.Equal(t, entities.AuditActionPurge, audit.Entries[0].Action)
+	assert.Nil(t, audit.Entries[0].After)
+}
+
+func TestProviders(t *testing.T) {
+	repo := setupRepository(t)
+
+	providers, err := repo.GetProviders()
+	require.NoError(t, err)
+	require.Len(t, providers, 2)
+	assert.Equal(t, "BBC News", providers[0].Name)
+	assert.EqualValues(t, 2, providers[0].FeedCount)
+	sky := providers[1]
+	assert.Equal(t, "Sky News", sky.Name)
+
+	_, err = repo.AddProvider("BBC News")
+	assert.IsType(t, &repository.DBDUPError{}, err)
+
+	cnn, err := repo.AddProvider("CNN")
+	require.NoError(t, err)
+	assert.Equal(t, "CNN", cnn.Name)
+
+	// Rename
+	err = repo.RenameProvider(9999, "Unknown")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+	err = repo.RenameProvider(sky.ID, "BBC News")
+	assert.IsType(t, &repository.DBDUPError{}, err)
+	err = repo.RenameProvider(sky.ID, "Sky")
+	require.NoError(t, err)
+
+	page, err := repo.GetFeeds(entities.FeedQuery{Provider: "Sky"})
+	require.NoError(t, err)
+	assert.Len(t, page.Feeds, 2)
+
+	// Delete
+	err = repo.DeleteProvider(9999, false, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+	err = repo.DeleteProvider(sky.ID, false, entities.Actor{})
+	assert.IsType(t, &repository.DBInUseError{}, err)
+	err = repo.DeleteProvider(cnn.ID, false, entities.Actor{})
+	require.NoError(t, err)
+	err = repo.DeleteProvider(sky.ID, true, entities.Actor{})
+	require.NoError(t, err)
+
+	providers, err = repo.GetProviders()
+	require.NoError(t, err)
+	assert.Equal(t, entities.Providers{{ID: providers[0].ID, Name: "BBC News", FeedCount: 2}}, providers)
+
+	page, err = repo.GetFeeds(entities.FeedQuery{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, page.Total)
+}
+
+func TestCategories(t *testing.T) {
+	repo := setupRepository(t)
+
+	categories, err := repo.GetCategories()
+	require.NoError(t, err)
+	require.Len(t, categories, 2)
+	tech := categories[0]
+	assert.Equal(t, "Technology", tech.Name)
+	assert.EqualValues(t, 2, tech.FeedCount)
+
+	_, err = repo.AddCategory("UK")
+	assert.IsType(t, &repository.DBDUPError{}, err)
+
+	sport, err := repo.AddCategory("Sport")
+	require.NoError(t, err)
+
+	err = repo.RenameCategory(tech.ID, "UK")
+	assert.IsType(t, &repository.DBDUPError{}, err)
+	err = repo.RenameCategory(tech.ID, "Tech")
+	require.NoError(t, err)
+
+	err = repo.DeleteCategory(tech.ID, false, entities.Actor{})
+	assert.IsType(t, &repository.DBInUseError{}, err)
+	err = repo.DeleteCategory(sport.ID, false, entities.Actor{})
+	require.NoError(t, err)
+	err = repo.DeleteCategory(sport.ID, false, entities.Actor{})
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+	err = repo.DeleteCategory(tech.ID, true, entities.Actor{})
+	require.NoError(t, err)
+
+	categories, err = repo.GetCategories()
+	require.NoError(t, err)
+	assert.Equal(t, entities.Categories{{ID: categories[0].ID, Name: "UK", FeedCount: 2}}, categories)
+	uk := categories[0]
+
+	// Adding a feed to a deleted category restores it
+	_, err = repo.AddFeed(entities.Feed{URL: "http://example.com/rss.xml", Provider: "Example", Category: "Tech"},
+		entities.Actor{})
+	require.NoError(t, err)
+
+	categories, err = repo.GetCategories()
+	require.NoError(t, err)
+	assert.Equal(t, entities.Categories{
+		{ID: tech.ID, Name: "Tech", FeedCount: 1},
+		{ID: uk.ID, Name: "UK", FeedCount: 2}}, categories)
+}
+
+func TestAPIKeys(t *testing.T) {
+	repo := setupRepository(t)
+
+	keys, err := repo.GetAPIKeys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	ci, err := repo.AddAPIKey(entities.APIKey{Name: "ci", Scopes: []string{"read", "write"}}, "hash-1")
+	require.NoError(t, err)
+	assert.Len(t, ci.ID, 36)
+	assert.Equal(t, "ci", ci.Name)
+	assert.False(t, ci.CreatedAt.IsZero())
+	_, err = repo.AddAPIKey(entities.APIKey{Name: "admin", Scopes: []string{"admin"}}, "hash-3")
+	require.NoError(t, err)
+
+	_, err = repo.AddAPIKey(entities.APIKey{Name: "ci", Scopes: []string{"read"}}, "hash-2")
+	assert.IsType(t, &repository.DBDUPError{}, err)
+	_, err = repo.AddAPIKey(entities.APIKey{Name: "other", Scopes: []string{"read"}}, "hash-1")
+	assert.IsType(t, &repository.DBDUPError{}, err)
+
+	key, err := repo.GetAPIKeyByHash("hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, ci.ID, key.ID)
+	assert.Equal(t, []string{"read", "write"}, key.Scopes)
+	_, err = repo.GetAPIKeyByHash("unknown")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+
+	_, err = repo.AddAPIKey(entities.APIKey{Name: "none"}, "hash-4")
+	require.NoError(t, err)
+	key, err = repo.GetAPIKeyByHash("hash-4")
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, key.Scopes)
+
+	keys, err = repo.GetAPIKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	assert.Equal(t, "admin", keys[0].Name)
+	assert.Equal(t, "ci", keys[1].Name)
+
+	// Revoke
+	err = repo.DeleteAPIKey(ci.ID)
+	require.NoError(t, err)
+	err = repo.DeleteAPIKey(ci.ID)
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+	_, err = repo.GetAPIKeyByHash("hash-1")
+	assert.IsType(t, &repository.DBNotFoundError{}, err)
+}
+
+func TestAuditLog(t *testing.T) {
+	repo := setupRepository(t)
+	jane := entities.Actor{Name: "user:jane", RequestID: "req-1"}
+
+	feed, err := repo.AddFeed(entities.Feed{URL: "http://example.com/rss.xml", Provider: "Example", Category: "UK",
+		Enabled: true}, jane)
+	require.NoError(t, err)
+	err = repo.SetFeedState(feed.ID, false, jane)
+	require.NoError(t, err)
+	// Nothing changes, so nothing is recorded
+	err = repo.SetFeedState(feed.ID, false, jane)
+	require.NoError(t, err)
+	newProvider := "Other"
+	_, err = repo.UpdateFeed(feed.ID, entities.FeedUpdate{Provider: &newProvider}, jane)
+	require.NoError(t, err)
+	// Updates setting fields to the values they already have aren't recorded
+	_, err = repo.UpdateFeed(feed.ID, entities.FeedUpdate{Provider: &newProvider, URL: &feed.URL}, jane)
+	require.NoError(t, err)
+	// Changes that fail are not recorded either
+	takenURL := "http://feeds.bbci.co.uk/news/uk/rss.xml"
+	_, err = repo.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &takenURL}, jane)
+	assert.IsType(t, &repository.DBDUPError{}, err)
+	err = repo.DeleteFeed(feed.ID, entities.Actor{Name: "apikey:ci"})
+	require.NoError(t, err)
+
+	page, err := repo.GetAuditEntries(entities.AuditQuery{FeedID: feed.ID})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 4)
+
+	deleted, updated, disabled, created := page.Entries[0], page.Entries[1], page.Entries[2], page.Entries[3]
+	assert.Equal(t, entities.AuditActionCreate, created.Action)
+	assert.Equal(t, "user:jane", created.Actor)
+	assert.Equal(t, "req-1", created.RequestID)
+	assert.Nil(t, created.Before)
+	require.NotNil(t, created.After)
+	assert.True(t, created.After.Enabled)
+
+	assert.Equal(t, entities.AuditActionDisable, disabled.Action)
+	assert.True(t, disabled.Before.Enabled)
+	assert.False(t, disabled.After.Enabled)
+
+	assert.Equal(t, entities.AuditActionUpdate, updated.Action)
+	assert.Equal(t, "Example", updated.Before.Provider)
+	assert.Equal(t, "Other", updated.After.Provider)
+
+	assert.Equal(t, entities.AuditActionDelete, deleted.Action)
+	assert.Equal(t, "apikey:ci", deleted.Actor)
+	assert.Nil(t, deleted.Before.DeletedAt)
+	require.NotNil(t, deleted.After)
+	assert.NotNil(t, deleted.After.DeletedAt)
+
+	// Feeds deleted along with their provider are recorded too
+	providers, err := repo.GetProviders()
+	require.NoError(t, err)
+	err = repo.DeleteProvider(providers[0].ID, true, jane)
+	require.NoError(t, err)
+
+	page, err = repo.GetAuditEntries(entities.AuditQuery{Actor: "user:jane"})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 5)
+	for _, entry := range page.Entries[:2] {
+		assert.Equal(t, entities.AuditActionDelete, entry.Action)
+		assert.Equal(t, "BBC News", entry.Before.Provider)
+	}
+
+	// Time range
+	page, err = repo.GetAuditEntries(entities.AuditQuery{Since: created.CreatedAt})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 6)
+	page, err = repo.GetAuditEntries(entities.AuditQuery{Until: created.CreatedAt})
+	require.NoError(t, err)
+	for _, entry := range page.Entries {
+		assert.Less(t, entry.ID, created.ID)
+	}
+
+	// Pagination
+	var ids []uint64
+	query := entities.AuditQuery{Limit: 4}
+	for {
+		page, err := repo.GetAuditEntries(query)
+		require.NoError(t, err)
+		for _, entry := range page.Entries {
+			ids = append(ids, entry.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	require.Len(t, ids, 10)
+	for i := 1; i < len(ids); i++ {
+		assert.Greater(t, ids[i-1], ids[i])
+	}
+
+	_, err = repo.GetAuditEntries(entities.AuditQuery{Cursor: "not-a-cursor"})
+	assert.IsType(t, &repository.DBInvalidCursorError{}, err)
+}
+
+func setupRepository(t *testing.T) *memory.Repository {
+	repo := memory.NewRepository()
+
+	data := entities.Feeds{
+		{URL: "http://feeds.bbci.co.uk/news/technology/rss.xml", Provider: "BBC News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK", Enabled: true},
+		{URL: "http://feeds.skynews.com/feeds/rss/technology.xml", Provider: "Sky News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.skynews.com/feeds/rss/uk.xml", Provider: "Sky News", Category: "UK", Enabled: false},
+	}
+
+	for _, feed := range data {
+		_, err := repo.AddFeed(feed, entities.Actor{})
+		require.NoError(t, err)
+	}
+
+	return repo
+}
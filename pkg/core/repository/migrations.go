@@ -0,0 +1,828 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+	"gorm.io/gorm"
+)
+
+// Migration represents a versioned change to the database schema.
+//
+// Migrations must never use the models in entities.go, as those always reflect the latest version of the schema.
+// Instead, each migration declares its own snapshot of the tables it touches.
+type Migration struct {
+	Version     uint
+	Description string
+	Up          func(tx *gorm.DB) error
+	Down        func(tx *gorm.DB) error
+}
+
+// ignoreURLSchemeKey is the context key holding whether migrations give 'http' and 'https' URLs the same canonical
+// form.
+type ignoreURLSchemeKey struct{}
+
+// MigrationStatus represents the state of a migration in the database.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// SchemaMigration represents the 'schema_migrations' table in the database.
+// It keeps track of which migrations have been applied.
+type SchemaMigration struct {
+	Version     uint      `gorm:"primaryKey;autoIncrement:false;not null"`
+	Description string    `gorm:"type:varchar(250);not null"`
+	AppliedAt   time.Time `gorm:"not null"`
+}
+
+// migrations holds all the migrations, sorted by version.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create providers, categories and feeds tables",
+		Up: func(tx *gorm.DB) error {
+			type provider struct {
+				ID   uint64 `gorm:"primaryKey;autoIncrement;not null"`
+				Name string `gorm:"type:varchar(30);uniqueIndex;not null"`
+			}
+			type category struct {
+				ID   uint64 `gorm:"primaryKey;autoIncrement;not null"`
+				Name string `gorm:"type:varchar(30);uniqueIndex;not null"`
+			}
+			type feed struct {
+				URL        string `gorm:"primaryKey;type:varchar(250);not null"`
+				Provider   provider
+				ProviderID uint64 `gorm:"not null"`
+				Category   category
+				CategoryID uint64 `gorm:"not null"`
+				Enabled    *bool  `gorm:"not null;default:false"`
+			}
+
+			// Databases created before migrations existed already have these tables
+			for _, model := range []interface{}{&provider{}, &category{}, &feed{}} {
+				if tx.Migrator().HasTable(model) {
+					continue
+				}
+				if err := tx.Migrator().CreateTable(model); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range []string{"feeds", "providers", "categories"} {
+				if err := tx.Migrator().DropTable(table); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "add feeds.created_at",
+		Up: func(tx *gorm.DB) error {
+			type feed struct {
+				CreatedAt *time.Time `gorm:"index"`
+			}
+
+			if err := tx.Migrator().AddColumn(&feed{}, "CreatedAt"); err != nil {
+				return err
+			}
+
+			// Existing feeds are considered to have been created now
+			err := tx.Model(&feed{}).Where("created_at IS NULL").Update("created_at", time.Now().UTC()).Error
+			if err != nil {
+				return err
+			}
+
+			return tx.Migrator().CreateIndex(&feed{}, "CreatedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			type feed struct {
+				CreatedAt *time.Time `gorm:"index"`
+			}
+
+			if err := tx.Migrator().DropIndex(&feed{}, "CreatedAt"); err != nil {
+				return err
+			}
+			return dropColumn(tx, &feed{}, "CreatedAt")
+		},
+	},
+	{
+		Version:     3,
+		Description: "add feeds.id surrogate key",
+		Up: func(tx *gorm.DB) error {
+			type feedID struct {
+				ID *string `gorm:"type:varchar(36)"`
+			}
+			type provider struct {
+				ID uint64 `gorm:"primaryKey;autoIncrement;not null"`
+			}
+			type category struct {
+				ID uint64 `gorm:"primaryKey;autoIncrement;not null"`
+			}
+			type feed struct {
+				ID         string `gorm:"primaryKey;type:varchar(36);not null"`
+				URL        string `gorm:"type:varchar(250);not null;uniqueIndex"`
+				Provider   provider
+				ProviderID uint64 `gorm:"not null"`
+				Category   category
+				CategoryID uint64     `gorm:"not null"`
+				Enabled    *bool      `gorm:"not null;default:false"`
+				CreatedAt  *time.Time `gorm:"index"`
+			}
+
+			// The column can only become the primary key once existing feeds have IDs
+			if err := tx.Table("feeds").Migrator().AddColumn(&feedID{}, "ID"); err != nil {
+				return err
+			}
+
+			var urls []string
+			if err := tx.Table("feeds").Where("id IS NULL").Pluck("url", &urls).Error; err != nil {
+				return err
+			}
+
+			for _, url := range urls {
+				err := tx.Table("feeds").Where("url = ?", url).Update("id", uuid.New().String()).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			if tx.Dialector.Name() == DriverSQLite {
+				return rebuildTable(tx, &feed{})
+			}
+
+			// Making the column the primary key makes it not null as well
+			if err := setFeedsPrimaryKey(tx, "id"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&feed{}, "URL")
+		},
+		Down: func(tx *gorm.DB) error {
+			type provider struct {
+				ID uint64 `gorm:"primaryKey;autoIncrement;not null"`
+			}
+			type category struct {
+				ID uint64 `gorm:"primaryKey;autoIncrement;not null"`
+			}
+			type feed struct {
+				URL        string `gorm:"primaryKey;type:varchar(250);not null"`
+				Provider   provider
+				ProviderID uint64 `gorm:"not null"`
+				Category   category
+				CategoryID uint64     `gorm:"not null"`
+				Enabled    *bool      `gorm:"not null;default:false"`
+				CreatedAt  *time.Time `gorm:"index"`
+			}
+
+			if tx.Dialector.Name() == DriverSQLite {
+				return rebuildTable(tx, &feed{})
+			}
+
+			if err := setFeedsPrimaryKey(tx, "url"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&feed{}, "idx_feeds_url"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&feed{}, "id")
+		},
+	},
+	{
+		Version:     4,
+		Description: "add feeds.updated_at",
+		Up: func(tx *gorm.DB) error {
+			type feed struct {
+				CreatedAt *time.Time
+				UpdatedAt *time.Time
+			}
+
+			if err := tx.Migrator().AddColumn(&feed{}, "UpdatedAt"); err != nil {
+				return err
+			}
+
+			// Existing feeds are considered not to have been updated since they were created
+			return tx.Model(&feed{}).Where("updated_at IS NULL").UpdateColumn("updated_at", gorm.Expr("created_at")).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			type feed struct {
+				UpdatedAt *time.Time
+			}
+
+			return dropColumn(tx, &feed{}, "UpdatedAt")
+		},
+	},
+	{
+		Version:     5,
+		Description: "add feeds metadata columns",
+		Up: func(tx *gorm.DB) error {
+			type feed struct {
+				Title       string `gorm:"type:varchar(250);not null;default:''"`
+				Description string `gorm:"type:varchar(1000);not null;default:''"`
+				Language    string `gorm:"type:varchar(35);not null;default:'';index"`
+				SiteURL     string `gorm:"type:varchar(250);not null;default:''"`
+				IconURL     string `gorm:"type:varchar(250);not null;default:''"`
+			}
+
+			for _, field := range []string{"Title", "Description", "Language", "SiteURL", "IconURL"} {
+				if err := tx.Migrator().AddColumn(&feed{}, field); err != nil {
+					return err
+				}
+			}
+
+			return tx.Migrator().CreateIndex(&feed{}, "Language")
+		},
+		Down: func(tx *gorm.DB) error {
+			type feed struct {
+				Title       string `gorm:"type:varchar(250);not null;default:''"`
+				Description string `gorm:"type:varchar(1000);not null;default:''"`
+				Language    string `gorm:"type:varchar(35);not null;default:'';index"`
+				SiteURL     string `gorm:"type:varchar(250);not null;default:''"`
+				IconURL     string `gorm:"type:varchar(250);not null;default:''"`
+			}
+
+			if err := tx.Migrator().DropIndex(&feed{}, "Language"); err != nil {
+				return err
+			}
+			for _, field := range []string{"Title", "Description", "Language", "SiteURL", "IconURL"} {
+				if err := dropColumn(tx, &feed{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     6,
+		Description: "create feed_health table",
+		Up: func(tx *gorm.DB) error {
+			type feedHealth struct {
+				FeedID              string `gorm:"primaryKey;type:varchar(36);not null"`
+				LastCheckedAt       *time.Time
+				LastSuccessAt       *time.Time
+				LastFailureAt       *time.Time
+				LastStatusCode      int    `gorm:"not null;default:0"`
+				LastError           string `gorm:"type:varchar(250);not null;default:''"`
+				ConsecutiveFailures int    `gorm:"not null;default:0"`
+			}
+
+			return tx.Table("feed_health").Migrator().CreateTable(&feedHealth{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("feed_health")
+		},
+	},
+	{
+		Version:     7,
+		Description: "add feeds fetch state columns",
+		Up: func(tx *gorm.DB) error {
+			type feed struct {
+				ETag            string `gorm:"column:etag;type:varchar(250);not null;default:''"`
+				LastModified    string `gorm:"type:varchar(250);not null;default:''"`
+				ContentHash     string `gorm:"type:varchar(250);not null;default:''"`
+				UpdateFrequency int64  `gorm:"not null;default:0"`
+			}
+
+			for _, field := range []string{"ETag", "LastModified", "ContentHash", "UpdateFrequency"} {
+				if err := tx.Migrator().AddColumn(&feed{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			type feed struct {
+				ETag            string `gorm:"column:etag;type:varchar(250);not null;default:''"`
+				LastModified    string `gorm:"type:varchar(250);not null;default:''"`
+				ContentHash     string `gorm:"type:varchar(250);not null;default:''"`
+				UpdateFrequency int64  `gorm:"not null;default:0"`
+			}
+
+			for _, field := range []string{"ETag", "LastModified", "ContentHash", "UpdateFrequency"} {
+				if err := dropColumn(tx, &feed{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     8,
+		Description: "add feeds scheduling columns",
+		Up: func(tx *gorm.DB) error {
+			type feed struct {
+				CreatedAt    *time.Time
+				PollInterval int64      `gorm:"not null;default:0"`
+				NextFetchAt  *time.Time `gorm:"index"`
+			}
+
+			for _, field := range []string{"PollInterval", "NextFetchAt"} {
+				if err := tx.Migrator().AddColumn(&feed{}, field); err != nil {
+					return err
+				}
+			}
+
+			// Existing feeds are due straight away, in the order they were created
+			err := tx.Model(&feed{}).Where("next_fetch_at IS NULL").
+				UpdateColumn("next_fetch_at", gorm.Expr("created_at")).Error
+			if err != nil {
+				return err
+			}
+
+			return tx.Migrator().CreateIndex(&feed{}, "NextFetchAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			type feed struct {
+				PollInterval int64      `gorm:"not null;default:0"`
+				NextFetchAt  *time.Time `gorm:"index"`
+			}
+
+			if err := tx.Migrator().DropIndex(&feed{}, "NextFetchAt"); err != nil {
+				return err
+			}
+			for _, field := range []string{"PollInterval", "NextFetchAt"} {
+				if err := dropColumn(tx, &feed{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     9,
+		Description: "add feeds.canonical_url",
+		Up: func(tx *gorm.DB) error {
+			type feedCanonicalURL struct {
+				CanonicalURL *string `gorm:"type:varchar(250)"`
+			}
+			type provider struct {
+				ID uint64 `gorm:"primaryKey;autoIncrement;not null"`
+			}
+			type category struct {
+				ID uint64 `gorm:"primaryKey;autoIncrement;not null"`
+			}
+			type feed struct {
+				ID              string `gorm:"primaryKey;type:varchar(36);not null"`
+				URL             string `gorm:"type:varchar(250);not null;uniqueIndex"`
+				CanonicalURL    string `gorm:"type:varchar(250);not null;uniqueIndex"`
+				Provider        provider
+				ProviderID      uint64 `gorm:"not null"`
+				Category        category
+				CategoryID      uint64     `gorm:"not null"`
+				Enabled         *bool      `gorm:"not null;default:false"`
+				Title           string     `gorm:"type:varchar(250);not null;default:''"`
+				Description     string     `gorm:"type:varchar(1000);not null;default:''"`
+				Language        string     `gorm:"type:varchar(35);not null;default:'';index"`
+				SiteURL         string     `gorm:"type:varchar(250);not null;default:''"`
+				IconURL         string     `gorm:"type:varchar(250);not null;default:''"`
+				ETag            string     `gorm:"column:etag;type:varchar(250);not null;default:''"`
+				LastModified    string     `gorm:"type:varchar(250);not null;default:''"`
+				ContentHash     string     `gorm:"type:varchar(250);not null;default:''"`
+				UpdateFrequency int64      `gorm:"not null;default:0"`
+				PollInterval    int64      `gorm:"not null;default:0"`
+				NextFetchAt     *time.Time `gorm:"index"`
+				CreatedAt       *time.Time `gorm:"index"`
+				UpdatedAt       *time.Time
+			}
+
+			// The column can only become not null once existing feeds have canonical URLs
+			if err := tx.Table("feeds").Migrator().AddColumn(&feedCanonicalURL{}, "CanonicalURL"); err != nil {
+				return err
+			}
+
+			if _, err := canonicalizeFeedURLs(tx, migrationIgnoresURLScheme(tx)); err != nil {
+				return err
+			}
+
+			if tx.Dialector.Name() == DriverSQLite {
+				return rebuildTable(tx, &feed{})
+			}
+
+			notNull := "ALTER TABLE feeds MODIFY canonical_url varchar(250) NOT NULL"
+			if tx.Dialector.Name() == DriverPostgres {
+				notNull = "ALTER TABLE feeds ALTER COLUMN canonical_url SET NOT NULL"
+			}
+			if err := tx.Exec(notNull).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&feed{}, "CanonicalURL")
+		},
+		Down: func(tx *gorm.DB) error {
+			type feed struct {
+				CanonicalURL string `gorm:"type:varchar(250);not null;uniqueIndex"`
+			}
+
+			if err := tx.Migrator().DropIndex(&feed{}, "CanonicalURL"); err != nil {
+				return err
+			}
+			return dropColumn(tx, &feed{}, "CanonicalURL")
+		},
+	},
+	{
+		Version:     10,
+		Description: "create api_keys table",
+		Up: func(tx *gorm.DB) error {
+			type apiKey struct {
+				ID        string `gorm:"primaryKey;type:varchar(36);not null"`
+				Name      string `gorm:"type:varchar(50);uniqueIndex;not null"`
+				KeyHash   string `gorm:"type:varchar(64);uniqueIndex;not null"`
+				Scopes    string `gorm:"type:varchar(100);not null"`
+				CreatedAt time.Time
+			}
+
+			return tx.Table("api_keys").Migrator().CreateTable(&apiKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("api_keys")
+		},
+	},
+	{
+		Version:     11,
+		Description: "create audit_entries table",
+		Up: func(tx *gorm.DB) error {
+			type auditEntry struct {
+				ID        uint64    `gorm:"primaryKey;autoIncrement;not null"`
+				FeedID    string    `gorm:"type:varchar(36);not null;index"`
+				Action    string    `gorm:"type:varchar(10);not null"`
+				Actor     string    `gorm:"type:varchar(250);not null;index"`
+				RequestID string    `gorm:"type:varchar(100);not null"`
+				Before    string    `gorm:"type:text;not null"`
+				After     string    `gorm:"type:text;not null"`
+				CreatedAt time.Time `gorm:"index"`
+			}
+
+			return tx.Migrator().CreateTable(&auditEntry{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("audit_entries")
+		},
+	},
+	{
+		Version:     12,
+		Description: "add deleted_at to feeds, providers and categories",
+		Up: func(tx *gorm.DB) error {
+			type feed struct {
+				DeletedAt *time.Time `gorm:"index"`
+			}
+			type provider feed
+			type category feed
+
+			for _, model := range []interface{}{&feed{}, &provider{}, &category{}} {
+				if err := tx.Migrator().AddColumn(model, "DeletedAt"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(model, "DeletedAt"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			type feed struct {
+				DeletedAt *time.Time `gorm:"index"`
+			}
+			type provider feed
+			type category feed
+
+			// Soft-deleted feeds, and the providers and categories only they reference, would come back otherwise
+			err := tx.Exec("DELETE FROM feed_health WHERE feed_id IN (SELECT id FROM feeds WHERE deleted_at IS NOT NULL)").
+				Error
+			if err != nil {
+				return err
+			}
+			for _, table := range []string{"feeds", "providers", "categories"} {
+				if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE deleted_at IS NOT NULL", table)).Error; err != nil {
+					return err
+				}
+			}
+
+			for _, model := range []interface{}{&feed{}, &provider{}, &category{}} {
+				if err := tx.Migrator().DropIndex(model, "DeletedAt"); err != nil {
+					return err
+				}
+				if err := dropColumn(tx, model, "DeletedAt"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// dropColumn drops the column of the model field provided.
+//
+// SQLite drops columns by rebuilding the table, which loses all its indexes, so they are recreated afterwards.
+// Indexes on the column itself must be dropped beforehand.
+func dropColumn(tx *gorm.DB, model interface{}, field string) error {
+	if tx.Dialector.Name() != DriverSQLite {
+		return tx.Migrator().DropColumn(model, field)
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	var indexes []string
+	err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = ? AND tbl_name = ? AND sql IS NOT NULL",
+		"index", stmt.Schema.Table).Scan(&indexes).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Migrator().DropColumn(model, field); err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if err := tx.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildTable recreates the table of the model provided, in SQLite, as the model declares it, indexes included.
+// Rows are copied over to the new table. Every column of the model must exist in the table already.
+//
+// SQLite can't make some changes in place, such as changing the primary key or making a column not null.
+func rebuildTable(tx *gorm.DB, model interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	table := stmt.Schema.Table
+	oldTable := table + "_old"
+
+	// Index names are unique across the whole database, so those of the old table must go first
+	var indexes []string
+	err := tx.Raw("SELECT name FROM sqlite_master WHERE type = ? AND tbl_name = ? AND sql IS NOT NULL",
+		"index", table).Scan(&indexes).Error
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if err := tx.Migrator().DropIndex(model, index); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Migrator().RenameTable(table, oldTable); err != nil {
+		return err
+	}
+	if err := tx.Migrator().CreateTable(model); err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		columns = append(columns, stmt.Quote(name))
+	}
+	err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%[2]s) SELECT %[2]s FROM %s", stmt.Quote(table),
+		strings.Join(columns, ", "), stmt.Quote(oldTable))).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Migrator().DropTable(oldTable)
+}
+
+// canonicalizeFeedURLs sets the canonical URL of every feed, deleted ones included, from its URL, and returns how many
+// feeds got a different canonical URL. It fails if feeds turn out to share a canonical URL, listing them, as only one
+// of them can be kept.
+func canonicalizeFeedURLs(tx *gorm.DB, ignoreScheme bool) (changed int, err error) {
+	type feed struct {
+		ID           string
+		URL          string
+		CanonicalURL *string
+	}
+
+	var feeds []feed
+	if err := tx.Order("url").Find(&feeds).Error; err != nil {
+		return 0, err
+	}
+
+	urls := make(map[string]string, len(feeds))
+	var updates []feed
+	var conflicts []string
+	for _, f := range feeds {
+		canonicalURL, err := core.CanonicalizeURL(f.URL, ignoreScheme)
+		if err != nil {
+			return 0, fmt.Errorf("feed %s: %w", f.ID, err)
+		}
+
+		if url, ok := urls[canonicalURL]; ok {
+			conflicts = append(conflicts, fmt.Sprintf("<%s> and <%s>", url, f.URL))
+			continue
+		}
+		urls[canonicalURL] = f.URL
+
+		if f.CanonicalURL == nil || *f.CanonicalURL != canonicalURL {
+			updates = append(updates, feed{ID: f.ID, CanonicalURL: &canonicalURL})
+		}
+	}
+	if len(conflicts) > 0 {
+		return 0, fmt.Errorf("feeds share the same canonical URL, delete and purge all but one of them first: %s",
+			strings.Join(conflicts, ", "))
+	}
+
+	// Feeds take their IDs as canonical URLs first, which never look like URLs, so that the unique index doesn't
+	// fail while canonical URLs swap between feeds
+	for _, f := range updates {
+		err := tx.Model(&feed{}).Where("id = ?", f.ID).UpdateColumn("canonical_url", gorm.Expr("id")).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, f := range updates {
+		err := tx.Model(&feed{}).Where("id = ?", f.ID).UpdateColumn("canonical_url", f.CanonicalURL).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(updates), nil
+}
+
+// migrationIgnoresURLScheme reports whether the migrations run by tx give 'http' and 'https' URLs the same
+// canonical form.
+func migrationIgnoresURLScheme(tx *gorm.DB) bool {
+	ignoreScheme, ok := tx.Statement.Context.Value(ignoreURLSchemeKey{}).(bool)
+	if !ok {
+		return core.DefaultIgnoreURLScheme
+	}
+	return ignoreScheme
+}
+
+// setFeedsPrimaryKey makes the column provided the primary key of the feeds table, in MySQL and PostgreSQL.
+func setFeedsPrimaryKey(tx *gorm.DB, column string) error {
+	statements := []string{fmt.Sprintf("ALTER TABLE feeds DROP PRIMARY KEY, ADD PRIMARY KEY (%s)", column)}
+	if tx.Dialector.Name() == DriverPostgres {
+		statements = []string{
+			"ALTER TABLE feeds DROP CONSTRAINT feeds_pkey",
+			fmt.Sprintf("ALTER TABLE feeds ADD PRIMARY KEY (%s)", column),
+		}
+	}
+
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateUp applies all pending migrations and returns the ones applied.
+func (db *Database) MigrateUp() (applied []Migration, err error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(context.Background(), ignoreURLSchemeKey{}, db.IgnoreURLScheme)
+	conn := db.conn.WithContext(ctx)
+
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+
+		m := status.Migration
+		err = runMigration(conn, func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+
+			record := SchemaMigration{Version: m.Version, Description: m.Description, AppliedAt: time.Now()}
+			return tx.Create(&record).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the latest applied migration and returns it.
+// It returns nil if there are no migrations to revert.
+func (db *Database) MigrateDown() (reverted *Migration, err error) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+
+		m := statuses[i].Migration
+		err = runMigration(db.conn, func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+
+			return tx.Delete(&SchemaMigration{Version: m.Version}).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("migration %d (%s) revert failed: %w", m.Version, m.Description, err)
+		}
+
+		return &m, nil
+	}
+
+	return nil, nil
+}
+
+// runMigration runs a migration step in a transaction.
+//
+// SQLite can't rebuild tables other tables reference with foreign keys on, and they can't be turned off within a
+// transaction, so they are turned off for the connection running the transaction instead, and checked before
+// committing.
+func runMigration(conn *gorm.DB, step func(tx *gorm.DB) error) error {
+	if conn.Dialector.Name() != DriverSQLite {
+		return conn.Transaction(step)
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return err
+	}
+	sqlConn, err := sqlDB.Conn(conn.Statement.Context)
+	if err != nil {
+		return err
+	}
+	defer sqlConn.Close()
+
+	session := conn.WithContext(conn.Statement.Context)
+	session.Statement.ConnPool = sqlConn
+
+	if err := session.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+		return err
+	}
+	defer session.Exec("PRAGMA foreign_keys = ON")
+
+	return session.Transaction(func(tx *gorm.DB) error {
+		if err := step(tx); err != nil {
+			return err
+		}
+
+		rows, err := tx.Raw("PRAGMA foreign_key_check").Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		if rows.Next() {
+			return errors.New("foreign key constraint failed")
+		}
+		return rows.Err()
+	})
+}
+
+// CanonicalizeFeedURLs sets the canonical URL of every feed again, following IgnoreURLScheme, and returns how many
+// changed. It must be run whenever IgnoreURLScheme changes, as the canonical URLs stored follow the setting in use
+// when they were stored.
+func (db *Database) CanonicalizeFeedURLs() (changed int, err error) {
+	err = db.conn.Transaction(func(tx *gorm.DB) error {
+		changed, err = canonicalizeFeedURLs(tx, db.IgnoreURLScheme)
+		return err
+	})
+	return changed, err
+}
+
+// MigrationStatus returns the state of every migration known, sorted by version.
+func (db *Database) MigrationStatus() ([]MigrationStatus, error) {
+	if err := db.conn.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var records []SchemaMigration
+	if err := db.conn.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[uint]time.Time, len(records))
+	for _, record := range records {
+		appliedAt[record.Version] = record.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		t, ok := appliedAt[m.Version]
+		statuses = append(statuses, MigrationStatus{Migration: m, Applied: ok, AppliedAt: t})
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		db := dbs.Database
+
+		statuses, err := db.MigrationStatus()
+		require.NoError(t, err)
+		require.Len(t, statuses, len(migrations))
+		for _, status := range statuses {
+			assert.True(t, status.Applied, "migration %d should be applied", status.Version)
+		}
+
+		// Nothing left to apply
+		applied, err := db.MigrateUp()
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+
+		// Feeds are identified by their ID, and told apart by their canonical URL, neither of which can be missing
+		insert := "INSERT INTO feeds (id, url, canonical_url, provider_id, category_id) VALUES (?, ?, ?, 1, 1)"
+		url := "http://example.com/rss.xml"
+		err = db.conn.Exec(insert, nil, url, url).Error
+		assert.Error(t, err)
+		err = db.conn.Exec(insert, "00000000-0000-0000-0000-000000000000", url, nil).Error
+		assert.Error(t, err)
+		err = db.conn.Exec(insert, "00000000-0000-0000-0000-000000000000", url, url).Error
+		assert.NoError(t, err)
+
+		// Revert everything
+		for i := len(migrations) - 1; i >= 0; i-- {
+			reverted, err := db.MigrateDown()
+			require.NoError(t, err)
+			require.NotNil(t, reverted)
+			assert.Equal(t, migrations[i].Version, reverted.Version)
+		}
+
+		reverted, err := db.MigrateDown()
+		require.NoError(t, err)
+		assert.Nil(t, reverted)
+		assert.False(t, db.conn.Migrator().HasTable(&Feed{}))
+
+		// And apply everything again
+		applied, err = db.MigrateUp()
+		require.NoError(t, err)
+		assert.Len(t, applied, len(migrations))
+		assert.True(t, db.conn.Migrator().HasTable(&Feed{}))
+	})
+}
+
+func TestMigrationBackfills(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		db := dbs.Database
+
+		// Go back to before feeds had IDs, existing feeds must be backfilled when migrating up again
+		for {
+			reverted, err := db.MigrateDown()
+			require.NoError(t, err)
+			require.NotNil(t, reverted)
+			if reverted.Version == 3 {
+				break
+			}
+		}
+
+		_, err := db.MigrateUp()
+		require.NoError(t, err)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Feeds, 4)
+
+		ids := map[string]bool{}
+		for _, feed := range page.Feeds {
+			assert.Len(t, feed.ID, 36)
+			assert.True(t, feed.UpdatedAt.Equal(feed.CreatedAt))
+			assert.Equal(t, strings.Replace(feed.URL, "http://", "https://", 1), feed.CanonicalURL)
+			ids[feed.ID] = true
+		}
+		assert.Len(t, ids, 4)
+
+	})
+}
+
+func TestCanonicalizeFeedURLs(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		db := dbs.Database
+
+		db.IgnoreURLScheme = true
+		changed, err := db.CanonicalizeFeedURLs()
+		require.NoError(t, err)
+		assert.Equal(t, 4, changed)
+
+		feed, err := dbs.GetFeedByURL("https://feeds.bbci.co.uk/news/uk/rss.xml")
+		require.NoError(t, err)
+		assert.Equal(t, "http://feeds.bbci.co.uk/news/uk/rss.xml", feed.URL)
+
+		// Canonical URLs follow the scheme setting
+		db.IgnoreURLScheme = false
+		changed, err = db.CanonicalizeFeedURLs()
+		require.NoError(t, err)
+		assert.Equal(t, 4, changed)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{})
+		require.NoError(t, err)
+		for _, feed := range page.Feeds {
+			assert.Equal(t, feed.URL, feed.CanonicalURL)
+		}
+
+		changed, err = db.CanonicalizeFeedURLs()
+		require.NoError(t, err)
+		assert.Zero(t, changed)
+
+		// Feeds that become the same feed must go first
+		_, err = dbs.AddFeed(entities.Feed{URL: "https://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News",
+			Category: "UK"}, entities.Actor{})
+		require.NoError(t, err)
+		db.IgnoreURLScheme = true
+		_, err = db.CanonicalizeFeedURLs()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(),
+			"<http://feeds.bbci.co.uk/news/uk/rss.xml> and <https://feeds.bbci.co.uk/news/uk/rss.xml>")
+
+		feed, err = dbs.GetFeedByURL("http://feeds.bbci.co.uk/news/uk/rss.xml")
+		require.NoError(t, err)
+		assert.Equal(t, "http://feeds.bbci.co.uk/news/uk/rss.xml", feed.URL)
+	})
+}
+
+func TestMigrationCanonicalURLConflicts(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		db := dbs.Database
+
+		// Go back to before feeds had canonical URLs, and store a variant of an existing feed
+		for {
+			reverted, err := db.MigrateDown()
+			require.NoError(t, err)
+			require.NotNil(t, reverted)
+			if reverted.Version == 9 {
+				break
+			}
+		}
+
+		variant := "https://feeds.bbci.co.uk/news/uk/rss.xml/"
+		err := db.conn.Exec("INSERT INTO feeds (id, url, provider_id, category_id) VALUES (?, ?, 1, 1)",
+			"00000000-0000-0000-0000-000000000000", variant).Error
+		require.NoError(t, err)
+
+		applied, err := db.MigrateUp()
+		require.Error(t, err)
+		assert.Empty(t, applied)
+		assert.Contains(t, err.Error(), "<http://feeds.bbci.co.uk/news/uk/rss.xml> and <"+variant+">")
+		assert.False(t, db.conn.Migrator().HasColumn(&Feed{}, "CanonicalURL"))
+
+		// Once the variant is gone, the migration goes through
+		err = db.conn.Exec("DELETE FROM feeds WHERE url = ?", variant).Error
+		require.NoError(t, err)
+		applied, err = db.MigrateUp()
+		require.NoError(t, err)
+		assert.Len(t, applied, len(migrations)-8)
+
+		feed, err := dbs.GetFeedByURL("https://feeds.bbci.co.uk/news/uk/rss.xml")
+		require.NoError(t, err)
+		assert.Equal(t, "http://feeds.bbci.co.uk/news/uk/rss.xml", feed.URL)
+	})
+}
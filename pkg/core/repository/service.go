@@ -1,11 +1,13 @@
 package repository
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/VividCortex/mysqlerr"
-	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
 	"gorm.io/gorm"
 )
@@ -36,15 +38,26 @@ type DBNotFoundError struct{}
 
 func (e *DBNotFoundError) Error() string { return "database error: entry not found" }
 
+// DBInUseError represents an error deleting an entry that is still referenced by other entries.
+type DBInUseError struct{}
+
+func (e *DBInUseError) Error() string { return "database error: entry still in use" }
+
+// DBInvalidCursorError represents an error caused by a malformed page cursor,
+// or a cursor generated for a different sort order.
+type DBInvalidCursorError struct{}
+
+func (e *DBInvalidCursorError) Error() string { return "database error: invalid page cursor" }
+
 // DatabaseService represents the database service.
 type DatabaseService struct {
 	Database *Database
 }
 
 // NewDatabaseService returns a new DatabaseService.
-func NewDatabaseService(host string, port int, username string, password string, dbname string) (dbs *DatabaseService, err error) {
+func NewDatabaseService(driver string, dsn string) (dbs *DatabaseService, err error) {
 	dbs = &DatabaseService{}
-	dbs.Database, err = NewDatabase(host, port, username, password, dbname)
+	dbs.Database, err = NewDatabase(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -62,49 +75,299 @@ func (dbs *DatabaseService) HealthCheck() error {
 	return dbs.Database.HealthCheck()
 }
 
-// GetFeeds returns all feed records matching a certain criteria.
-func (dbs *DatabaseService) GetFeeds(provider string, category string, enabled bool) (feeds entities.Feeds, err error) {
-	feedRecords, err := dbs.Database.FindAllFeedRecords(provider, category, enabled)
+// GetFeeds returns a page of feed records matching a certain criteria.
+func (dbs *DatabaseService) GetFeeds(query entities.FeedQuery) (page entities.FeedsPage, err error) {
+	field, desc, ok := ParseFeedSort(query.Sort)
+	if !ok {
+		return page, &DBServiceError{Msg: fmt.Sprintf("database error: unknown sort expression <%s>", query.Sort)}
+	}
+
+	filter := FeedRecordFilter{
+		Provider:       query.Provider,
+		Category:       query.Category,
+		Language:       query.Language,
+		Enabled:        query.Enabled,
+		IncludeDeleted: query.IncludeDeleted,
+	}
+	recordsPage := FeedRecordPage{SortField: field, SortDesc: desc}
+
+	if query.Cursor != "" {
+		cursor, err := DecodeCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return page, err
+		}
+		recordsPage.After = &cursor
+	}
+
+	// Fetch one extra record to find out whether there is a next page
+	if query.Limit > 0 {
+		recordsPage.Limit = query.Limit + 1
+	}
+
+	feedRecords, err := dbs.Database.FindAllFeedRecords(filter, recordsPage)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return entities.Feeds{}, nil
+		feedRecords = nil
 	} else if err != nil {
-		return nil, &DBServiceError{Msg: "database error", Err: err}
+		return page, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	page.Total, err = dbs.Database.CountFeedRecords(filter)
+	if err != nil {
+		return page, &DBServiceError{Msg: "database error", Err: err}
 	}
 
-	feedList := make(entities.Feeds, 0, len(feedRecords))
+	page.Feeds = make(entities.Feeds, 0, len(feedRecords))
 
 	for _, feedRecord := range feedRecords {
-		feedItem := entities.Feed{
-			URL:      feedRecord.URL,
-			Provider: feedRecord.Provider.Name,
-			Category: feedRecord.Category.Name,
-			Enabled:  *feedRecord.Enabled,
+		page.Feeds = append(page.Feeds, mapFeedRecord(feedRecord))
+	}
+
+	if query.Limit > 0 && len(page.Feeds) > query.Limit {
+		page.Feeds = page.Feeds[:query.Limit]
+		page.NextCursor = NewCursor(query.Sort, page.Feeds[query.Limit-1]).Encode()
+	}
+
+	return page, nil
+}
+
+// feedIterationBatchSize is the number of feed records fetched at a time when iterating over feeds.
+var feedIterationBatchSize = 500
+
+// IterateFeeds calls fn for every feed matching a certain criteria, in order, fetching them in batches so that
+// they are never all held in memory at once. If the query has a limit, at most that many feeds are visited.
+// Iteration stops at the first error returned by fn, which is returned as is.
+func (dbs *DatabaseService) IterateFeeds(query entities.FeedQuery, fn func(feed entities.Feed) error) (err error) {
+	field, desc, ok := ParseFeedSort(query.Sort)
+	if !ok {
+		return &DBServiceError{Msg: fmt.Sprintf("database error: unknown sort expression <%s>", query.Sort)}
+	}
+
+	filter := FeedRecordFilter{
+		Provider:       query.Provider,
+		Category:       query.Category,
+		Language:       query.Language,
+		Enabled:        query.Enabled,
+		IncludeDeleted: query.IncludeDeleted,
+	}
+	recordsPage := FeedRecordPage{SortField: field, SortDesc: desc}
+
+	if query.Cursor != "" {
+		cursor, err := DecodeCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return err
+		}
+		recordsPage.After = &cursor
+	}
+
+	remaining := query.Limit
+
+	for {
+		recordsPage.Limit = feedIterationBatchSize
+		if query.Limit > 0 && remaining < recordsPage.Limit {
+			recordsPage.Limit = remaining
 		}
 
-		feedList = append(feedList, feedItem)
+		feedRecords, err := dbs.Database.FindAllFeedRecords(filter, recordsPage)
+		if err != nil {
+			return &DBServiceError{Msg: "database error", Err: err}
+		}
+
+		var feed entities.Feed
+		for _, feedRecord := range feedRecords {
+			feed = mapFeedRecord(feedRecord)
+			if err := fn(feed); err != nil {
+				return err
+			}
+		}
+
+		remaining -= len(feedRecords)
+		if len(feedRecords) < recordsPage.Limit || (query.Limit > 0 && remaining == 0) {
+			return nil
+		}
+
+		cursor := NewCursor(query.Sort, feed)
+		recordsPage.After = &cursor
+	}
+}
+
+// GetFeed returns the feed with the ID provided.
+func (dbs *DatabaseService) GetFeed(id string) (feed entities.Feed, err error) {
+	feedRecord, err := dbs.Database.FindFeedRecord(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return feed, &DBNotFoundError{}
+	} else if err != nil {
+		return feed, &DBServiceError{Msg: "database error", Err: err}
 	}
 
-	return feedList, nil
+	return mapFeedRecord(feedRecord), nil
 }
 
-// AddFeed adds a new feed record to the database.
-func (dbs *DatabaseService) AddFeed(feed entities.Feed) (err error) {
-	err = dbs.Database.InsertFeedRecord(feed.URL, feed.Provider, feed.Category, feed.Enabled)
+// GetFeedByURL returns the feed with the canonical URL provided.
+func (dbs *DatabaseService) GetFeedByURL(canonicalURL string) (feed entities.Feed, err error) {
+	feedRecord, err := dbs.Database.FindFeedRecordByCanonicalURL(canonicalURL)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return feed, &DBNotFoundError{}
+	} else if err != nil {
+		return feed, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapFeedRecord(feedRecord), nil
+}
+
+// AddFeed adds a new feed record to the database and returns it along with its newly assigned ID.
+func (dbs *DatabaseService) AddFeed(feed entities.Feed, actor entities.Actor) (created entities.Feed, err error) {
+	feedRecord, err := dbs.Database.InsertFeedRecord(newFeedRecord(feed), actor)
+	if dbs.Database.IsDuplicateError(err) {
+		return created, &DBDUPError{}
+	} else if err != nil {
+		return created, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapFeedRecord(feedRecord), nil
+}
+
+// AddFeeds adds a batch of feeds to the database and returns the outcome for each of them, in the same order.
+//
+// Feeds whose canonical URL already exists, or appears earlier in the batch, are reported as duplicates.
+// In atomic mode, feeds are only added if none of them are duplicates, and in a single transaction.
+// Otherwise, each feed is added independently and failures are reported per feed.
+// In dry run mode, feeds that would have been added are reported as valid instead.
+func (dbs *DatabaseService) AddFeeds(feeds entities.Feeds, opts entities.FeedBatchOptions, actor entities.Actor) (results entities.FeedBatchResults, err error) {
+	canonicalURLs := make([]string, 0, len(feeds))
+	for _, feed := range feeds {
+		canonicalURLs = append(canonicalURLs, FeedCanonicalURL(feed))
+	}
+
+	existing, err := dbs.Database.FindExistingCanonicalURLs(canonicalURLs)
 	if err != nil {
-		if driverErr, ok := err.(*mysql.MySQLError); ok {
-			if driverErr.Number == mysqlerr.ER_DUP_ENTRY {
-				return &DBDUPError{}
-			}
+		return nil, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	results, pending := markDuplicateFeeds(feeds, existing)
+
+	if opts.Atomic && len(pending) != len(feeds) {
+		for _, i := range pending {
+			results[i].Status = entities.FeedBatchSkipped
 		}
+		return results, nil
+	}
+
+	if opts.DryRun {
+		for _, i := range pending {
+			results[i].Status = entities.FeedBatchValid
+		}
+		return results, nil
+	}
+
+	if opts.Atomic {
+		records := make([]NewFeedRecord, 0, len(feeds))
+		for _, feed := range feeds {
+			records = append(records, newFeedRecord(feed))
+		}
+
+		feedRecords, err := dbs.Database.InsertFeedRecords(records, actor)
+		if dbs.Database.IsDuplicateError(err) {
+			return nil, &DBDUPError{}
+		} else if err != nil {
+			return nil, &DBServiceError{Msg: "database error", Err: err}
+		}
+
+		for i, feedRecord := range feedRecords {
+			results[i] = entities.FeedBatchResult{Status: entities.FeedBatchCreated, Feed: mapFeedRecord(feedRecord)}
+		}
+		return results, nil
+	}
+
+	for _, i := range pending {
+		feed, err := dbs.AddFeed(feeds[i], actor)
+		if _, ok := err.(*DBDUPError); ok {
+			results[i].Status = entities.FeedBatchDuplicate
+		} else if err != nil {
+			results[i].Status = entities.FeedBatchFailed
+			results[i].Error = err.Error()
+		} else {
+			results[i] = entities.FeedBatchResult{Status: entities.FeedBatchCreated, Feed: feed}
+		}
+	}
+
+	return results, nil
+}
+
+// SetFeedState updates a feed enabled field.
+func (dbs *DatabaseService) SetFeedState(id string, enabled bool, actor entities.Actor) (err error) {
+	err = dbs.Database.UpdateFeedState(id, enabled, actor)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &DBNotFoundError{}
+	} else if err != nil {
 		return &DBServiceError{Msg: "database error", Err: err}
 	}
 
 	return nil
 }
 
-// SetFeedState updates a feed enabled field.
-func (dbs *DatabaseService) SetFeedState(url string, enabled bool) (err error) {
-	err = dbs.Database.UpdateFeedState(url, enabled)
+// SetFeedFetchState replaces the fetch state of a feed, and schedules its next fetch.
+func (dbs *DatabaseService) SetFeedFetchState(id string, state entities.FeedFetchState, nextFetchAt time.Time) (err error) {
+	err = dbs.Database.UpdateFeedFetchState(id, state, nextFetchAt.UTC())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &DBNotFoundError{}
+	} else if err != nil {
+		return &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return nil
+}
+
+// LeaseDueFeeds leases up to limit enabled feeds due to be fetched, and returns them. The feeds due the longest come
+// first.
+// Leased feeds are not leased again until the lease timeout expires, unless their fetch state is reported first.
+func (dbs *DatabaseService) LeaseDueFeeds(limit int, leaseTimeout time.Duration) (feeds entities.Feeds, err error) {
+	now := time.Now().UTC()
+
+	feedRecords, err := dbs.Database.LeaseDueFeedRecords(limit, now, now.Add(leaseTimeout))
+	if err != nil {
+		return nil, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	feeds = make(entities.Feeds, 0, len(feedRecords))
+	for _, feedRecord := range feedRecords {
+		feeds = append(feeds, mapFeedRecord(feedRecord))
+	}
+
+	return feeds, nil
+}
+
+// UpdateFeed applies the changes provided to a feed and returns the updated feed.
+// It returns DBDUPError if the feed canonical URL is changed to one that already exists.
+func (dbs *DatabaseService) UpdateFeed(id string, update entities.FeedUpdate, actor entities.Actor) (feed entities.Feed,
+	err error) {
+	recordUpdate := FeedRecordUpdate{
+		URL:          update.URL,
+		CanonicalURL: update.CanonicalURL,
+		Provider:     update.Provider,
+		Category:     update.Category,
+		Enabled:      update.Enabled,
+		Metadata:     update.Metadata,
+		PollInterval: update.PollInterval,
+	}
+	if update.URL != nil && update.CanonicalURL == nil {
+		recordUpdate.CanonicalURL = update.URL
+	}
+
+	feedRecord, err := dbs.Database.UpdateFeedRecord(id, recordUpdate, actor)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return feed, &DBNotFoundError{}
+	} else if dbs.Database.IsDuplicateError(err) {
+		return feed, &DBDUPError{}
+	} else if err != nil {
+		return feed, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapFeedRecord(feedRecord), nil
+}
+
+// DeleteFeed soft-deletes a feed. Deleted feeds can be restored until they are purged.
+func (dbs *DatabaseService) DeleteFeed(id string, actor entities.Actor) (err error) {
+	err = dbs.Database.DeleteFeedRecord(id, actor)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return &DBNotFoundError{}
 	} else if err != nil {
@@ -114,9 +377,186 @@ func (dbs *DatabaseService) SetFeedState(url string, enabled bool) (err error) {
 	return nil
 }
 
-// DeleteFeed deletes a feed record from the database.
-func (dbs *DatabaseService) DeleteFeed(url string) (err error) {
-	err = dbs.Database.DeleteFeedRecord(url)
+// RestoreFeed restores a deleted feed and returns it. Restoring a feed not deleted just returns it.
+func (dbs *DatabaseService) RestoreFeed(id string, actor entities.Actor) (feed entities.Feed, err error) {
+	feedRecord, err := dbs.Database.RestoreFeedRecord(id, actor)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return feed, &DBNotFoundError{}
+	} else if err != nil {
+		return feed, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapFeedRecord(feedRecord), nil
+}
+
+// PurgeDeletedFeeds permanently deletes up to limit feeds deleted before the time provided, the ones deleted the
+// longest first, and returns how many were purged. Zero limit means no limit.
+func (dbs *DatabaseService) PurgeDeletedFeeds(deletedBefore time.Time, limit int, actor entities.Actor) (purged int,
+	err error) {
+	purged, err = dbs.Database.PurgeFeedRecords(deletedBefore.UTC(), limit, actor)
+	if err != nil {
+		return purged, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return purged, nil
+}
+
+// GetFeedHealth returns the outcome of the latest health checks of a feed.
+func (dbs *DatabaseService) GetFeedHealth(id string) (health entities.FeedHealth, err error) {
+	healthRecord, err := dbs.Database.FindFeedHealthRecord(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return health, &DBNotFoundError{}
+	} else if err != nil {
+		return health, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapFeedHealthRecord(healthRecord), nil
+}
+
+// RecordFeedCheck records the outcome of a health check of a feed and returns its updated health.
+// Errors longer than entities.MaxFeedHealthErrorLength are truncated.
+func (dbs *DatabaseService) RecordFeedCheck(id string, check entities.FeedCheck) (health entities.FeedHealth, err error) {
+	if errRunes := []rune(check.Error); len(errRunes) > entities.MaxFeedHealthErrorLength {
+		check.Error = string(errRunes[:entities.MaxFeedHealthErrorLength])
+	}
+
+	healthRecord, err := dbs.Database.UpsertFeedHealthRecord(id, check)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return health, &DBNotFoundError{}
+	} else if err != nil {
+		return health, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapFeedHealthRecord(healthRecord), nil
+}
+
+// GetProviders returns all providers along with their feed count.
+func (dbs *DatabaseService) GetProviders() (providers entities.Providers, err error) {
+	records, err := dbs.Database.FindAllProviderRecords()
+	if err != nil {
+		return nil, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	providers = make(entities.Providers, 0, len(records))
+	for _, record := range records {
+		providers = append(providers, entities.Provider{ID: record.ID, Name: record.Name, FeedCount: record.FeedCount})
+	}
+
+	return providers, nil
+}
+
+// AddProvider adds a new provider record to the database.
+func (dbs *DatabaseService) AddProvider(name string) (provider entities.Provider, err error) {
+	record, err := dbs.Database.InsertProviderRecord(name)
+	if dbs.Database.IsDuplicateError(err) {
+		return provider, &DBDUPError{}
+	} else if err != nil {
+		return provider, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return entities.Provider{ID: record.ID, Name: record.Name}, nil
+}
+
+// RenameProvider updates a provider name.
+func (dbs *DatabaseService) RenameProvider(id uint64, name string) (err error) {
+	return dbs.mapNamedRecordError(dbs.Database.UpdateProviderName(id, name))
+}
+
+// DeleteProvider deletes a provider record from the database.
+// Unless cascade is true, providers with feeds cannot be deleted. With cascade, their feeds are deleted too, and can be
+// restored, along with the provider, until purged.
+func (dbs *DatabaseService) DeleteProvider(id uint64, cascade bool, actor entities.Actor) (err error) {
+	return dbs.mapNamedRecordError(dbs.Database.DeleteProviderRecord(id, cascade, actor))
+}
+
+// GetCategories returns all categories along with their feed count.
+func (dbs *DatabaseService) GetCategories() (categories entities.Categories, err error) {
+	records, err := dbs.Database.FindAllCategoryRecords()
+	if err != nil {
+		return nil, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	categories = make(entities.Categories, 0, len(records))
+	for _, record := range records {
+		categories = append(categories, entities.Category{ID: record.ID, Name: record.Name, FeedCount: record.FeedCount})
+	}
+
+	return categories, nil
+}
+
+// AddCategory adds a new category record to the database.
+func (dbs *DatabaseService) AddCategory(name string) (category entities.Category, err error) {
+	record, err := dbs.Database.InsertCategoryRecord(name)
+	if dbs.Database.IsDuplicateError(err) {
+		return category, &DBDUPError{}
+	} else if err != nil {
+		return category, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return entities.Category{ID: record.ID, Name: record.Name}, nil
+}
+
+// RenameCategory updates a category name.
+func (dbs *DatabaseService) RenameCategory(id uint64, name string) (err error) {
+	return dbs.mapNamedRecordError(dbs.Database.UpdateCategoryName(id, name))
+}
+
+// DeleteCategory deletes a category record from the database.
+// Unless cascade is true, categories with feeds cannot be deleted. With cascade, their feeds are deleted too, and can be
+// restored, along with the category, until purged.
+func (dbs *DatabaseService) DeleteCategory(id uint64, cascade bool, actor entities.Actor) (err error) {
+	return dbs.mapNamedRecordError(dbs.Database.DeleteCategoryRecord(id, cascade, actor))
+}
+
+// GetAPIKeys returns all the API keys issued, sorted by name.
+func (dbs *DatabaseService) GetAPIKeys() (keys entities.APIKeys, err error) {
+	records, err := dbs.Database.FindAllAPIKeyRecords()
+	if err != nil {
+		return nil, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	keys = make(entities.APIKeys, 0, len(records))
+	for _, record := range records {
+		keys = append(keys, mapAPIKeyRecord(record))
+	}
+
+	return keys, nil
+}
+
+// GetAPIKeyByHash returns the API key with the key hash provided.
+func (dbs *DatabaseService) GetAPIKeyByHash(keyHash string) (key entities.APIKey, err error) {
+	record, err := dbs.Database.FindAPIKeyRecordByHash(keyHash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, &DBNotFoundError{}
+	} else if err != nil {
+		return key, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapAPIKeyRecord(record), nil
+}
+
+// AddAPIKey adds a new API key record, stored by the hash of the key, and returns it along with its newly assigned
+// ID.
+// It returns DBDUPError if there is a key with the same name or hash already.
+func (dbs *DatabaseService) AddAPIKey(key entities.APIKey, keyHash string) (created entities.APIKey, err error) {
+	record, err := dbs.Database.InsertAPIKeyRecord(APIKey{
+		ID:      uuid.New().String(),
+		Name:    key.Name,
+		KeyHash: keyHash,
+		Scopes:  strings.Join(key.Scopes, ","),
+	})
+	if dbs.Database.IsDuplicateError(err) {
+		return created, &DBDUPError{}
+	} else if err != nil {
+		return created, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	return mapAPIKeyRecord(record), nil
+}
+
+// DeleteAPIKey deletes an API key record from the database, revoking the key.
+func (dbs *DatabaseService) DeleteAPIKey(id string) (err error) {
+	err = dbs.Database.DeleteAPIKeyRecord(id)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return &DBNotFoundError{}
 	} else if err != nil {
@@ -125,3 +565,197 @@ func (dbs *DatabaseService) DeleteFeed(url string) (err error) {
 
 	return nil
 }
+
+// GetAuditEntries returns a page of audit log entries matching a certain criteria, the most recent first.
+func (dbs *DatabaseService) GetAuditEntries(query entities.AuditQuery) (page entities.AuditPage, err error) {
+	filter := AuditRecordFilter{FeedID: query.FeedID, Actor: query.Actor, Since: query.Since, Until: query.Until}
+
+	var beforeID uint64
+	if query.Cursor != "" {
+		if beforeID, err = DecodeAuditCursor(query.Cursor); err != nil {
+			return page, err
+		}
+	}
+
+	// Fetch one extra record to find out whether there is a next page
+	limit := query.Limit
+	if limit > 0 {
+		limit++
+	}
+
+	records, err := dbs.Database.FindAuditRecords(filter, beforeID, limit)
+	if err != nil {
+		return page, &DBServiceError{Msg: "database error", Err: err}
+	}
+
+	page.Entries = make(entities.AuditEntries, 0, len(records))
+	for _, record := range records {
+		entry, err := mapAuditRecord(record)
+		if err != nil {
+			return page, &DBServiceError{Msg: "database error", Err: err}
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+
+	if query.Limit > 0 && len(page.Entries) > query.Limit {
+		page.Entries = page.Entries[:query.Limit]
+		page.NextCursor = EncodeAuditCursor(page.Entries[query.Limit-1].ID)
+	}
+
+	return page, nil
+}
+
+// mapNamedRecordError maps errors returned when updating or deleting providers and categories.
+func (dbs *DatabaseService) mapNamedRecordError(err error) error {
+	if err == nil {
+		return nil
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &DBNotFoundError{}
+	} else if errors.Is(err, errRecordInUse) {
+		return &DBInUseError{}
+	} else if dbs.Database.IsDuplicateError(err) {
+		return &DBDUPError{}
+	}
+
+	return &DBServiceError{Msg: "database error", Err: err}
+}
+
+// mapFeedRecord converts a feed record, with its provider and category loaded, into a feed entity.
+func mapFeedRecord(feedRecord Feed) entities.Feed {
+	feed := entities.Feed{
+		ID:           feedRecord.ID,
+		URL:          feedRecord.URL,
+		CanonicalURL: feedRecord.CanonicalURL,
+		Provider:     feedRecord.Provider.Name,
+		Category:     feedRecord.Category.Name,
+		Enabled:      *feedRecord.Enabled,
+		FeedMetadata: entities.FeedMetadata{
+			Title:       feedRecord.Title,
+			Description: feedRecord.Description,
+			Language:    feedRecord.Language,
+			SiteURL:     feedRecord.SiteURL,
+			IconURL:     feedRecord.IconURL,
+		},
+		FetchState: entities.FeedFetchState{
+			ETag:            feedRecord.ETag,
+			LastModified:    feedRecord.LastModified,
+			ContentHash:     feedRecord.ContentHash,
+			UpdateFrequency: feedRecord.UpdateFrequency,
+		},
+		PollInterval: feedRecord.PollInterval,
+		NextFetchAt:  feedRecord.NextFetchAt,
+		CreatedAt:    feedRecord.CreatedAt,
+		UpdatedAt:    feedRecord.UpdatedAt,
+	}
+
+	if feedRecord.DeletedAt.Valid {
+		deletedAt := feedRecord.DeletedAt.Time
+		feed.DeletedAt = &deletedAt
+	}
+
+	return feed
+}
+
+// mapFeedHealthRecord converts a feed health record into a feed health entity.
+func mapFeedHealthRecord(healthRecord FeedHealth) entities.FeedHealth {
+	return entities.FeedHealth{
+		FeedID:              healthRecord.FeedID,
+		LastCheckedAt:       healthRecord.LastCheckedAt,
+		LastSuccessAt:       healthRecord.LastSuccessAt,
+		LastFailureAt:       healthRecord.LastFailureAt,
+		LastStatusCode:      healthRecord.LastStatusCode,
+		LastError:           healthRecord.LastError,
+		ConsecutiveFailures: healthRecord.ConsecutiveFailures,
+	}
+}
+
+// mapAPIKeyRecord converts an API key record into an API key entity.
+func mapAPIKeyRecord(record APIKey) entities.APIKey {
+	// Splitting an empty string would give a single empty scope
+	scopes := []string{}
+	if record.Scopes != "" {
+		scopes = strings.Split(record.Scopes, ",")
+	}
+
+	return entities.APIKey{
+		ID:        record.ID,
+		Name:      record.Name,
+		Scopes:    scopes,
+		CreatedAt: record.CreatedAt,
+	}
+}
+
+// mapAuditRecord converts an audit record into an audit entry.
+func mapAuditRecord(record AuditEntry) (entry entities.AuditEntry, err error) {
+	entry = entities.AuditEntry{
+		ID:        record.ID,
+		FeedID:    record.FeedID,
+		Action:    record.Action,
+		Actor:     record.Actor,
+		RequestID: record.RequestID,
+		CreatedAt: record.CreatedAt,
+	}
+
+	if record.Before != "" {
+		entry.Before = &entities.Feed{}
+		if err := json.Unmarshal([]byte(record.Before), entry.Before); err != nil {
+			return entry, fmt.Errorf("error parsing audit entry %d: %w", record.ID, err)
+		}
+	}
+
+	if record.After != "" {
+		entry.After = &entities.Feed{}
+		if err := json.Unmarshal([]byte(record.After), entry.After); err != nil {
+			return entry, fmt.Errorf("error parsing audit entry %d: %w", record.ID, err)
+		}
+	}
+
+	return entry, nil
+}
+
+// newFeedRecord returns the fields of a new feed record for the feed provided, with a newly assigned ID.
+func newFeedRecord(feed entities.Feed) NewFeedRecord {
+	return NewFeedRecord{
+		ID:           uuid.New().String(),
+		URL:          feed.URL,
+		CanonicalURL: FeedCanonicalURL(feed),
+		Provider:     feed.Provider,
+		Category:     feed.Category,
+		Enabled:      feed.Enabled,
+		Metadata:     feed.FeedMetadata,
+		PollInterval: feed.PollInterval,
+	}
+}
+
+// markDuplicateFeeds returns a result for every feed, marking as duplicates the ones whose canonical URL is in
+// existing or appears earlier in the batch, along with the indexes of the feeds still pending.
+func markDuplicateFeeds(feeds entities.Feeds, existing []string) (results entities.FeedBatchResults, pending []int) {
+	seen := make(map[string]bool, len(feeds)+len(existing))
+	for _, canonicalURL := range existing {
+		seen[canonicalURL] = true
+	}
+
+	results = make(entities.FeedBatchResults, len(feeds))
+	for i, feed := range feeds {
+		results[i].Feed = feed
+
+		canonicalURL := FeedCanonicalURL(feed)
+		if seen[canonicalURL] {
+			results[i].Status = entities.FeedBatchDuplicate
+			continue
+		}
+
+		seen[canonicalURL] = true
+		pending = append(pending, i)
+	}
+
+	return results, pending
+}
+
+// FeedCanonicalURL returns the canonical URL of a feed, which is the URL itself if none was set.
+func FeedCanonicalURL(feed entities.Feed) string {
+	if feed.CanonicalURL == "" {
+		return feed.URL
+	}
+	return feed.CanonicalURL
+}
@@ -0,0 +1,1082 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	trueV  = true
+	falseV = false
+)
+
+func TestGetFeeds(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		tests := map[string]struct {
+			provider     string
+			category     string
+			enabled      *bool
+			expectedURLs []string
+		}{
+			"all enabled": {
+				enabled: &trueV,
+				expectedURLs: []string{
+					"http://feeds.bbci.co.uk/news/technology/rss.xml",
+					"http://feeds.bbci.co.uk/news/uk/rss.xml",
+					"http://feeds.skynews.com/feeds/rss/technology.xml"}},
+			"all disabled": {
+				enabled:      &falseV,
+				expectedURLs: []string{"http://feeds.skynews.com/feeds/rss/uk.xml"}},
+			"any state": {
+				provider: "Sky News",
+				expectedURLs: []string{
+					"http://feeds.skynews.com/feeds/rss/technology.xml",
+					"http://feeds.skynews.com/feeds/rss/uk.xml"}},
+			"provider and category filter": {
+				provider:     "BBC News",
+				category:     "UK",
+				enabled:      &trueV,
+				expectedURLs: []string{"http://feeds.bbci.co.uk/news/uk/rss.xml"}},
+			"no match": {
+				category:     "Unknown",
+				enabled:      &trueV,
+				expectedURLs: []string{}},
+		}
+
+		for name, test := range tests {
+			t.Run(name, func(t *testing.T) {
+				page, err := dbs.GetFeeds(entities.FeedQuery{Provider: test.provider, Category: test.category, Enabled: test.enabled})
+				require.NoError(t, err)
+
+				urls := []string{}
+				for _, feed := range page.Feeds {
+					urls = append(urls, feed.URL)
+				}
+				assert.ElementsMatch(t, test.expectedURLs, urls)
+			})
+		}
+	})
+}
+
+func TestGetFeedsPagination(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		bbcTech := "http://feeds.bbci.co.uk/news/technology/rss.xml"
+		bbcUK := "http://feeds.bbci.co.uk/news/uk/rss.xml"
+		skyTech := "http://feeds.skynews.com/feeds/rss/technology.xml"
+
+		tests := map[string][]string{
+			"url":         {bbcTech, bbcUK, skyTech},
+			"-url":        {skyTech, bbcUK, bbcTech},
+			"provider":    {bbcTech, bbcUK, skyTech},
+			"-provider":   {skyTech, bbcUK, bbcTech},
+			"category":    {bbcTech, skyTech, bbcUK},
+			"-category":   {bbcUK, skyTech, bbcTech},
+			"created_at":  {bbcTech, bbcUK, skyTech},
+			"-created_at": {skyTech, bbcUK, bbcTech},
+		}
+
+		for sort, expectedURLs := range tests {
+			for _, limit := range []int{1, 2, 3} {
+				t.Run(fmt.Sprintf("%s limit %d", sort, limit), func(t *testing.T) {
+					urls := []string{}
+					query := entities.FeedQuery{Enabled: &trueV, Limit: limit, Sort: sort}
+
+					for {
+						page, err := dbs.GetFeeds(query)
+						require.NoError(t, err)
+						assert.EqualValues(t, 3, page.Total)
+						assert.LessOrEqual(t, len(page.Feeds), limit)
+
+						for _, feed := range page.Feeds {
+							urls = append(urls, feed.URL)
+						}
+
+						if page.NextCursor == "" {
+							break
+						}
+						query.Cursor = page.NextCursor
+					}
+
+					assert.Equal(t, expectedURLs, urls)
+				})
+			}
+		}
+
+		// Cursors are only valid for the sort order they were generated for
+		page, err := dbs.GetFeeds(entities.FeedQuery{Enabled: &trueV, Limit: 1, Sort: "url"})
+		require.NoError(t, err)
+		_, err = dbs.GetFeeds(entities.FeedQuery{Enabled: &trueV, Limit: 1, Sort: "-url", Cursor: page.NextCursor})
+		assert.IsType(t, &DBInvalidCursorError{}, err)
+
+		_, err = dbs.GetFeeds(entities.FeedQuery{Enabled: &trueV, Limit: 1, Sort: "url", Cursor: "invalid"})
+		assert.IsType(t, &DBInvalidCursorError{}, err)
+	})
+}
+
+func TestIterateFeeds(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		bbcTech := "http://feeds.bbci.co.uk/news/technology/rss.xml"
+		bbcUK := "http://feeds.bbci.co.uk/news/uk/rss.xml"
+		skyTech := "http://feeds.skynews.com/feeds/rss/technology.xml"
+
+		defer func(batchSize int) { feedIterationBatchSize = batchSize }(feedIterationBatchSize)
+
+		tests := map[string]struct {
+			sort         string
+			limit        int
+			expectedURLs []string
+		}{
+			"all":          {sort: "url", expectedURLs: []string{bbcTech, bbcUK, skyTech}},
+			"descending":   {sort: "-category", expectedURLs: []string{bbcUK, skyTech, bbcTech}},
+			"with limit":   {sort: "url", limit: 2, expectedURLs: []string{bbcTech, bbcUK}},
+			"limit beyond": {sort: "provider", limit: 10, expectedURLs: []string{bbcTech, bbcUK, skyTech}},
+		}
+
+		for name, test := range tests {
+			for _, batchSize := range []int{1, 2, 500} {
+				t.Run(fmt.Sprintf("%s batch size %d", name, batchSize), func(t *testing.T) {
+					feedIterationBatchSize = batchSize
+
+					urls := []string{}
+					query := entities.FeedQuery{Enabled: &trueV, Limit: test.limit, Sort: test.sort}
+					err := dbs.IterateFeeds(query, func(feed entities.Feed) error {
+						urls = append(urls, feed.URL)
+						return nil
+					})
+					require.NoError(t, err)
+					assert.Equal(t, test.expectedURLs, urls)
+				})
+			}
+		}
+
+		t.Run("stops on error", func(t *testing.T) {
+			feedIterationBatchSize = 1
+
+			errStop := errors.New("stop")
+			count := 0
+			err := dbs.IterateFeeds(entities.FeedQuery{Sort: "url"}, func(feed entities.Feed) error {
+				count++
+				return errStop
+			})
+			assert.Equal(t, errStop, err)
+			assert.Equal(t, 1, count)
+		})
+
+		t.Run("invalid cursor", func(t *testing.T) {
+			err := dbs.IterateFeeds(entities.FeedQuery{Sort: "url", Cursor: "invalid"}, func(feed entities.Feed) error {
+				return nil
+			})
+			assert.IsType(t, &DBInvalidCursorError{}, err)
+		})
+	})
+}
+
+func TestGetFeed(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		_, err := dbs.GetFeed("00000000-0000-0000-0000-000000000000")
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		_, err = dbs.GetFeedByURL("http://url.does.not.exist.com")
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		feed, err := dbs.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+		require.NoError(t, err)
+		assert.NotEmpty(t, feed.ID)
+		assert.Equal(t, "Sky News", feed.Provider)
+		assert.Equal(t, "UK", feed.Category)
+		assert.False(t, feed.Enabled)
+
+		sameFeed, err := dbs.GetFeed(feed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, feed, sameFeed)
+
+		// Feeds are looked up by their canonical URL
+		added, err := dbs.AddFeed(entities.Feed{URL: "http://example.com/rss.xml?utm_source=x",
+			CanonicalURL: "https://example.com/rss.xml", Provider: "Example", Category: "UK"}, entities.Actor{})
+		require.NoError(t, err)
+		feed, err = dbs.GetFeedByURL("https://example.com/rss.xml")
+		require.NoError(t, err)
+		assert.Equal(t, added.ID, feed.ID)
+		_, err = dbs.GetFeedByURL("http://example.com/rss.xml?utm_source=x")
+		assert.IsType(t, &DBNotFoundError{}, err)
+	})
+}
+
+func TestAddFeed(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		_, err := dbs.AddFeed(entities.Feed{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "Sky News", Category: "UK"}, entities.Actor{})
+		assert.IsType(t, &DBDUPError{}, err)
+
+		created, err := dbs.AddFeed(entities.Feed{URL: "http://example.com/rss.xml", Provider: "Example", Category: "UK", Enabled: true}, entities.Actor{})
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.ID)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{Provider: "Example", Category: "UK", Enabled: &trueV})
+		require.NoError(t, err)
+		require.Len(t, page.Feeds, 1)
+		assert.Equal(t, created.ID, page.Feeds[0].ID)
+		assert.Equal(t, "http://example.com/rss.xml", page.Feeds[0].URL)
+		assert.Equal(t, "Example", page.Feeds[0].Provider)
+		assert.Equal(t, "UK", page.Feeds[0].Category)
+		assert.True(t, page.Feeds[0].Enabled)
+		assert.False(t, page.Feeds[0].CreatedAt.IsZero())
+	})
+}
+
+func TestAddFeeds(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		statuses := func(results entities.FeedBatchResults) []string {
+			result := []string{}
+			for _, r := range results {
+				result = append(result, r.Status)
+			}
+			return result
+		}
+
+		// Dry runs add nothing
+		results, err := dbs.AddFeeds(entities.Feeds{
+			{URL: "http://example.com/1.xml", Provider: "Example", Category: "UK", Enabled: true},
+			{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK"},
+		}, entities.FeedBatchOptions{DryRun: true}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{entities.FeedBatchValid, entities.FeedBatchDuplicate}, statuses(results))
+
+		// Atomic batches with duplicates add nothing
+		results, err = dbs.AddFeeds(entities.Feeds{
+			{URL: "http://example.com/1.xml", Provider: "Example", Category: "UK", Enabled: true},
+			{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK"},
+		}, entities.FeedBatchOptions{Atomic: true}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{entities.FeedBatchSkipped, entities.FeedBatchDuplicate}, statuses(results))
+
+		_, err = dbs.GetFeedByURL("http://example.com/1.xml")
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		results, err = dbs.AddFeeds(entities.Feeds{
+			{URL: "http://example.com/1.xml", Provider: "Example", Category: "UK", Enabled: true},
+			{URL: "http://example.com/2.xml", Provider: "Example", Category: "World", Enabled: true},
+		}, entities.FeedBatchOptions{Atomic: true}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{entities.FeedBatchCreated, entities.FeedBatchCreated}, statuses(results))
+
+		feed, err := dbs.GetFeedByURL("http://example.com/2.xml")
+		require.NoError(t, err)
+		assert.Equal(t, results[1].Feed, feed)
+
+		// Otherwise feeds are added independently
+		results, err = dbs.AddFeeds(entities.Feeds{
+			{URL: "http://example.com/2.xml", Provider: "Example", Category: "UK", Enabled: true},
+			{URL: "http://example.com/3.xml", Provider: "Example", Category: "UK", Enabled: true},
+			{URL: "http://example.com/3.xml", Provider: "Example", Category: "UK", Enabled: true},
+		}, entities.FeedBatchOptions{}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{entities.FeedBatchDuplicate, entities.FeedBatchCreated, entities.FeedBatchDuplicate}, statuses(results))
+		assert.NotEmpty(t, results[1].Feed.ID)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{Provider: "Example"})
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, page.Total)
+	})
+}
+
+func TestFeedCanonicalURL(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		canonicalURL := "https://example.com/rss.xml"
+
+		created, err := dbs.AddFeed(entities.Feed{URL: "http://Example.com/rss.xml/", CanonicalURL: canonicalURL,
+			Provider: "Example", Category: "UK"}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, "http://Example.com/rss.xml/", created.URL)
+		assert.Equal(t, canonicalURL, created.CanonicalURL)
+
+		// Feeds without a canonical URL use the URL itself
+		other, err := dbs.AddFeed(entities.Feed{URL: "http://example.com/other.xml", Provider: "Example", Category: "UK"}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com/other.xml", other.CanonicalURL)
+
+		_, err = dbs.AddFeed(entities.Feed{URL: "https://example.com/rss.xml?utm_source=x", CanonicalURL: canonicalURL,
+			Provider: "Example", Category: "UK"}, entities.Actor{})
+		assert.IsType(t, &DBDUPError{}, err)
+
+		results, err := dbs.AddFeeds(entities.Feeds{
+			{URL: "https://example.com/rss.xml#top", CanonicalURL: canonicalURL, Provider: "Example", Category: "UK"},
+			{URL: "https://example.com/new.xml", CanonicalURL: "https://example.com/new.xml", Provider: "Example",
+				Category: "UK"},
+			{URL: "https://example.com/new.xml/", CanonicalURL: "https://example.com/new.xml", Provider: "Example",
+				Category: "UK"},
+		}, entities.FeedBatchOptions{DryRun: true}, entities.Actor{})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, entities.FeedBatchDuplicate, results[0].Status)
+		assert.Equal(t, entities.FeedBatchValid, results[1].Status)
+		assert.Equal(t, entities.FeedBatchDuplicate, results[2].Status)
+
+		otherURL := "http://EXAMPLE.com/rss.xml"
+		_, err = dbs.UpdateFeed(other.ID, entities.FeedUpdate{URL: &otherURL, CanonicalURL: &canonicalURL}, entities.Actor{})
+		assert.IsType(t, &DBDUPError{}, err)
+
+		// Changing the URL without a canonical URL uses the URL itself
+		updated, err := dbs.UpdateFeed(other.ID, entities.FeedUpdate{URL: &otherURL}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, otherURL, updated.CanonicalURL)
+	})
+}
+
+func TestSetFeedState(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		err := dbs.SetFeedState("00000000-0000-0000-0000-000000000000", true, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		feed, err := dbs.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+		require.NoError(t, err)
+		err = dbs.SetFeedState(feed.ID, true, entities.Actor{})
+		require.NoError(t, err)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{Provider: "Sky News", Category: "UK", Enabled: &trueV})
+		require.NoError(t, err)
+		assert.Len(t, page.Feeds, 1)
+	})
+}
+
+func TestSetFeedFetchState(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		state := entities.FeedFetchState{
+			ETag:            `W/"5e8f-1a2b"`,
+			LastModified:    "Sat, 01 May 2021 10:00:00 GMT",
+			ContentHash:     "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			UpdateFrequency: 1800,
+		}
+
+		nextFetchAt := time.Date(2021, 5, 1, 10, 30, 0, 0, time.UTC)
+
+		err := dbs.SetFeedFetchState("00000000-0000-0000-0000-000000000000", state, nextFetchAt)
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		feed, err := dbs.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+		require.NoError(t, err)
+		assert.Equal(t, entities.FeedFetchState{}, feed.FetchState)
+
+		err = dbs.SetFeedFetchState(feed.ID, state, nextFetchAt)
+		require.NoError(t, err)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{Provider: "Sky News", Category: "UK"})
+		require.NoError(t, err)
+		require.Len(t, page.Feeds, 1)
+		assert.Equal(t, state, page.Feeds[0].FetchState)
+		assert.True(t, nextFetchAt.Equal(page.Feeds[0].NextFetchAt))
+		assert.Equal(t, feed.UpdatedAt, page.Feeds[0].UpdatedAt)
+	})
+}
+
+func TestLeaseDueFeeds(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		urls := func(feeds entities.Feeds) []string {
+			result := []string{}
+			for _, feed := range feeds {
+				result = append(result, feed.URL)
+			}
+			return result
+		}
+
+		// Feeds are due as soon as they are added, and disabled feeds are never due
+		feeds, err := dbs.LeaseDueFeeds(2, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, feeds, 2)
+		assert.Equal(t, "BBC News", feeds[0].Provider)
+		assert.Equal(t, "Technology", feeds[0].Category)
+
+		leased := map[string]bool{}
+		for _, feed := range feeds {
+			leased[feed.URL] = true
+			assert.True(t, feed.NextFetchAt.After(time.Now().Add(50*time.Second)))
+		}
+
+		feeds, err = dbs.LeaseDueFeeds(10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, feeds, 1)
+		assert.False(t, leased[feeds[0].URL])
+		assert.NotEqual(t, "http://feeds.skynews.com/feeds/rss/uk.xml", feeds[0].URL)
+
+		// Leased feeds are not due again until the lease expires
+		feeds, err = dbs.LeaseDueFeeds(10, time.Minute)
+		require.NoError(t, err)
+		assert.Empty(t, urls(feeds))
+
+		// Reporting the fetch state schedules the next fetch
+		feed, err := dbs.GetFeedByURL("http://feeds.bbci.co.uk/news/uk/rss.xml")
+		require.NoError(t, err)
+		err = dbs.SetFeedFetchState(feed.ID, entities.FeedFetchState{}, time.Now().Add(-time.Second))
+		require.NoError(t, err)
+
+		feeds, err = dbs.LeaseDueFeeds(10, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http://feeds.bbci.co.uk/news/uk/rss.xml"}, urls(feeds))
+	})
+}
+
+func TestUpdateFeed(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		_, err := dbs.UpdateFeed("00000000-0000-0000-0000-000000000000", entities.FeedUpdate{Enabled: &falseV}, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		feed, err := dbs.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+		require.NoError(t, err)
+
+		// Changes are not applied at all when the new URL is taken
+		takenURL := "http://feeds.bbci.co.uk/news/uk/rss.xml"
+		newProvider := "Sky"
+		_, err = dbs.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &takenURL, Provider: &newProvider}, entities.Actor{})
+		assert.IsType(t, &DBDUPError{}, err)
+
+		providers, err := dbs.GetProviders()
+		require.NoError(t, err)
+		assert.Len(t, providers, 2)
+
+		newURL := "https://feeds.skynews.com/feeds/rss/uk.xml"
+		newCategory := "World"
+		updated, err := dbs.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &newURL, Provider: &newProvider, Category: &newCategory, Enabled: &trueV}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, feed.ID, updated.ID)
+		assert.Equal(t, newURL, updated.URL)
+		assert.Equal(t, "Sky", updated.Provider)
+		assert.Equal(t, "World", updated.Category)
+		assert.True(t, updated.Enabled)
+		assert.True(t, updated.CreatedAt.Equal(feed.CreatedAt))
+
+		sameFeed, err := dbs.GetFeed(feed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updated, sameFeed)
+
+		_, err = dbs.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		// Setting the URL a feed already has is not a conflict
+		_, err = dbs.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &newURL}, entities.Actor{})
+		require.NoError(t, err)
+	})
+}
+
+func TestFeedMetadata(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		feeds := entities.Feeds{
+			{URL: "https://www.rtp.pt/noticias/rss", FeedMetadata: entities.FeedMetadata{Language: "pt-pt"}},
+			{URL: "https://g1.globo.com/rss/g1/", FeedMetadata: entities.FeedMetadata{Language: "pt-br"}},
+			{URL: "https://www.ptinews.com/rss", FeedMetadata: entities.FeedMetadata{Language: "ptx"}},
+			{URL: "https://www.lemonde.fr/rss/une.xml", FeedMetadata: entities.FeedMetadata{Language: "fr"}},
+		}
+
+		for _, feed := range feeds {
+			feed.Provider = "Example"
+			feed.Category = "World"
+			feed.Title = "Title of " + feed.URL
+			created, err := dbs.AddFeed(feed, entities.Actor{})
+			require.NoError(t, err)
+			assert.Equal(t, feed.FeedMetadata, created.FeedMetadata)
+		}
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{Language: "PT", Sort: "url"})
+		require.NoError(t, err)
+		require.Len(t, page.Feeds, 2)
+		assert.Equal(t, "https://g1.globo.com/rss/g1/", page.Feeds[0].URL)
+		assert.Equal(t, "Title of https://g1.globo.com/rss/g1/", page.Feeds[0].Title)
+		assert.Equal(t, "https://www.rtp.pt/noticias/rss", page.Feeds[1].URL)
+
+		page, err = dbs.GetFeeds(entities.FeedQuery{Language: "pt-br"})
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, page.Total)
+
+		// Metadata is replaced as a whole
+		metadata := entities.FeedMetadata{Title: "Le Monde", Language: "fr-fr", SiteURL: "https://www.lemonde.fr/"}
+		feed, err := dbs.GetFeedByURL("https://www.lemonde.fr/rss/une.xml")
+		require.NoError(t, err)
+		updated, err := dbs.UpdateFeed(feed.ID, entities.FeedUpdate{Metadata: &metadata}, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, metadata, updated.FeedMetadata)
+		assert.Equal(t, "Example", updated.Provider)
+
+		page, err = dbs.GetFeeds(entities.FeedQuery{Language: "fr"})
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, page.Total)
+	})
+}
+
+func TestFeedHealth(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		_, err := dbs.GetFeedHealth("00000000-0000-0000-0000-000000000000")
+		assert.IsType(t, &DBNotFoundError{}, err)
+		_, err = dbs.RecordFeedCheck("00000000-0000-0000-0000-000000000000", entities.FeedCheck{CheckedAt: time.Now()})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		feed, err := dbs.GetFeedByURL("http://feeds.skynews.com/feeds/rss/uk.xml")
+		require.NoError(t, err)
+
+		health, err := dbs.GetFeedHealth(feed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entities.FeedHealth{FeedID: feed.ID}, health)
+
+		checkedAt := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+		health, err = dbs.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: checkedAt, StatusCode: 200})
+		require.NoError(t, err)
+		assert.Equal(t, 0, health.ConsecutiveFailures)
+
+		for i := 1; i <= 2; i++ {
+			failedAt := checkedAt.Add(time.Duration(i) * time.Hour)
+			health, err = dbs.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: failedAt, StatusCode: 404,
+				Error: strings.Repeat("x", entities.MaxFeedHealthErrorLength+10)})
+			require.NoError(t, err)
+			assert.Equal(t, i, health.ConsecutiveFailures)
+		}
+
+		health, err = dbs.GetFeedHealth(feed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, health.ConsecutiveFailures)
+		assert.Equal(t, 404, health.LastStatusCode)
+		assert.Len(t, health.LastError, entities.MaxFeedHealthErrorLength)
+		require.NotNil(t, health.LastSuccessAt)
+		assert.True(t, checkedAt.Equal(*health.LastSuccessAt))
+		require.NotNil(t, health.LastFailureAt)
+		assert.True(t, checkedAt.Add(2*time.Hour).Equal(*health.LastFailureAt))
+		assert.True(t, health.LastFailureAt.Equal(*health.LastCheckedAt))
+
+		// A successful check resets the failure count
+		health, err = dbs.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: checkedAt.Add(3 * time.Hour),
+			StatusCode: 200})
+		require.NoError(t, err)
+		assert.Equal(t, 0, health.ConsecutiveFailures)
+		assert.Empty(t, health.LastError)
+		assert.True(t, checkedAt.Add(2*time.Hour).Equal(*health.LastFailureAt))
+
+		// Health records are deleted along with their feeds
+		other, err := dbs.GetFeedByURL("http://feeds.skynews.com/feeds/rss/technology.xml")
+		require.NoError(t, err)
+		_, err = dbs.RecordFeedCheck(other.ID, entities.FeedCheck{CheckedAt: checkedAt, StatusCode: 200})
+		require.NoError(t, err)
+
+		err = dbs.DeleteFeed(feed.ID, entities.Actor{})
+		require.NoError(t, err)
+		_, err = dbs.GetFeedHealth(feed.ID)
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		_, err = dbs.PurgeDeletedFeeds(time.Now().Add(time.Second), 0, entities.Actor{})
+		require.NoError(t, err)
+		providers, err := dbs.GetProviders()
+		require.NoError(t, err)
+		err = dbs.DeleteProvider(providers[1].ID, true, entities.Actor{})
+		require.NoError(t, err)
+
+		// Feeds deleted along with their provider keep their health records until purged
+		var count int64
+		err = dbs.Database.conn.Model(&FeedHealth{}).Count(&count).Error
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+
+		_, err = dbs.PurgeDeletedFeeds(time.Now().Add(time.Second), 0, entities.Actor{})
+		require.NoError(t, err)
+		err = dbs.Database.conn.Model(&FeedHealth{}).Count(&count).Error
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, count)
+	})
+}
+
+func TestDeleteFeed(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		err := dbs.DeleteFeed("00000000-0000-0000-0000-000000000000", entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		feed, err := dbs.GetFeedByURL("http://feeds.bbci.co.uk/news/uk/rss.xml")
+		require.NoError(t, err)
+		err = dbs.DeleteFeed(feed.ID, entities.Actor{})
+		require.NoError(t, err)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{Provider: "BBC News", Category: "UK", Enabled: &trueV})
+		require.NoError(t, err)
+		assert.Len(t, page.Feeds, 0)
+
+		// Deleted feeds are kept until purged
+		page, err = dbs.GetFeeds(entities.FeedQuery{Provider: "BBC News", Category: "UK", IncludeDeleted: true})
+		require.NoError(t, err)
+		require.Len(t, page.Feeds, 1)
+		assert.Equal(t, feed.ID, page.Feeds[0].ID)
+		assert.NotNil(t, page.Feeds[0].DeletedAt)
+
+		_, err = dbs.GetFeed(feed.ID)
+		assert.IsType(t, &DBNotFoundError{}, err)
+		err = dbs.SetFeedState(feed.ID, false, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+		err = dbs.DeleteFeed(feed.ID, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		providers, err := dbs.GetProviders()
+		require.NoError(t, err)
+		assert.Equal(t, "BBC News", providers[0].Name)
+		assert.EqualValues(t, 1, providers[0].FeedCount)
+
+		// They hold on to their URL
+		_, err = dbs.AddFeed(entities.Feed{URL: feed.URL, Provider: "BBC News", Category: "UK"}, entities.Actor{})
+		assert.IsType(t, &DBDUPError{}, err)
+	})
+}
+
+func TestRestoreFeed(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		_, err := dbs.RestoreFeed("00000000-0000-0000-0000-000000000000", entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		feed, err := dbs.GetFeedByURL("http://feeds.bbci.co.uk/news/uk/rss.xml")
+		require.NoError(t, err)
+		_, err = dbs.RecordFeedCheck(feed.ID, entities.FeedCheck{CheckedAt: time.Now(), StatusCode: 200})
+		require.NoError(t, err)
+		err = dbs.DeleteFeed(feed.ID, entities.Actor{})
+		require.NoError(t, err)
+
+		restored, err := dbs.RestoreFeed(feed.ID, entities.Actor{Name: "user:jane"})
+		require.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Equal(t, feed.URL, restored.URL)
+		assert.Equal(t, "BBC News", restored.Provider)
+		assert.Equal(t, "UK", restored.Category)
+
+		// Health is kept along with the feed
+		health, err := dbs.GetFeedHealth(feed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 200, health.LastStatusCode)
+
+		page, err := dbs.GetAuditEntries(entities.AuditQuery{FeedID: feed.ID})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 3)
+		assert.Equal(t, entities.AuditActionRestore, page.Entries[0].Action)
+		assert.Equal(t, "user:jane", page.Entries[0].Actor)
+		assert.NotNil(t, page.Entries[0].Before.DeletedAt)
+		assert.Nil(t, page.Entries[0].After.DeletedAt)
+
+		// Restoring a feed not deleted changes nothing
+		restored, err = dbs.RestoreFeed(feed.ID, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, feed.ID, restored.ID)
+
+		page, err = dbs.GetAuditEntries(entities.AuditQuery{FeedID: feed.ID})
+		require.NoError(t, err)
+		assert.Len(t, page.Entries, 3)
+	})
+}
+
+func TestPurgeDeletedFeeds(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		page, err := dbs.GetFeeds(entities.FeedQuery{Provider: "BBC News"})
+		require.NoError(t, err)
+		require.Len(t, page.Feeds, 2)
+		purgedFeed, keptFeed := page.Feeds[0], page.Feeds[1]
+		for _, feed := range page.Feeds {
+			err = dbs.DeleteFeed(feed.ID, entities.Actor{})
+			require.NoError(t, err)
+		}
+
+		// Feeds deleted after the time provided are kept
+		purged, err := dbs.PurgeDeletedFeeds(time.Now().Add(-time.Hour), 10, entities.Actor{Name: "purge"})
+		require.NoError(t, err)
+		assert.Equal(t, 0, purged)
+
+		// The feeds deleted the longest go first
+		purged, err = dbs.PurgeDeletedFeeds(time.Now().Add(time.Second), 1, entities.Actor{Name: "purge"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = dbs.RestoreFeed(purgedFeed.ID, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		audit, err := dbs.GetAuditEntries(entities.AuditQuery{FeedID: purgedFeed.ID})
+		require.NoError(t, err)
+		require.Len(t, audit.Entries, 3)
+		assert.Equal(t, entities.AuditActionPurge, audit.Entries[0].Action)
+		assert.Equal(t, "purge", audit.Entries[0].Actor)
+		assert.Nil(t, audit.Entries[0].After)
+
+		// Purged feeds free their URL
+		readded, err := dbs.AddFeed(entities.Feed{URL: purgedFeed.URL, Provider: "BBC News", Category: "UK"},
+			entities.Actor{})
+		require.NoError(t, err)
+
+		// Providers with feeds are only deleted with cascade, which deletes their feeds too
+		providers, err := dbs.GetProviders()
+		require.NoError(t, err)
+		bbc := providers[0]
+		require.Equal(t, "BBC News", bbc.Name)
+		err = dbs.DeleteProvider(bbc.ID, false, entities.Actor{})
+		assert.IsType(t, &DBInUseError{}, err)
+		err = dbs.DeleteProvider(bbc.ID, true, entities.Actor{Name: "admin"})
+		require.NoError(t, err)
+
+		_, err = dbs.GetFeed(readded.ID)
+		assert.IsType(t, &DBNotFoundError{}, err)
+		audit, err = dbs.GetAuditEntries(entities.AuditQuery{FeedID: readded.ID})
+		require.NoError(t, err)
+		assert.Equal(t, entities.AuditActionDelete, audit.Entries[0].Action)
+		assert.Equal(t, "admin", audit.Entries[0].Actor)
+		require.NotNil(t, audit.Entries[0].After)
+		assert.NotNil(t, audit.Entries[0].After.DeletedAt)
+
+		providers, err = dbs.GetProviders()
+		require.NoError(t, err)
+		assert.Len(t, providers, 1)
+		err = dbs.DeleteProvider(bbc.ID, true, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		// Deleted feeds keep their provider until purged, so that they can still be restored along with it
+		restored, err := dbs.RestoreFeed(readded.ID, entities.Actor{})
+		require.NoError(t, err)
+		assert.Equal(t, "BBC News", restored.Provider)
+		providers, err = dbs.GetProviders()
+		require.NoError(t, err)
+		assert.Equal(t, entities.Provider{ID: bbc.ID, Name: "BBC News", FeedCount: 1}, providers[0])
+
+		// Providers with deleted feeds only are deleted without cascade
+		err = dbs.DeleteFeed(readded.ID, entities.Actor{})
+		require.NoError(t, err)
+		err = dbs.DeleteProvider(bbc.ID, false, entities.Actor{})
+		require.NoError(t, err)
+
+		// Adding the provider again restores it
+		provider, err := dbs.AddProvider("BBC News")
+		require.NoError(t, err)
+		assert.Equal(t, bbc.ID, provider.ID)
+		err = dbs.DeleteProvider(bbc.ID, false, entities.Actor{})
+		require.NoError(t, err)
+
+		// Once its feeds are purged, the provider is gone for good
+		purged, err = dbs.PurgeDeletedFeeds(time.Now().Add(time.Second), 0, entities.Actor{Name: "purge"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, purged)
+
+		page, err = dbs.GetFeeds(entities.FeedQuery{Provider: "BBC News", IncludeDeleted: true})
+		require.NoError(t, err)
+		assert.Empty(t, page.Feeds)
+
+		var count int64
+		err = dbs.Database.conn.Model(&Provider{}).Count(&count).Error
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+
+		audit, err = dbs.GetAuditEntries(entities.AuditQuery{FeedID: keptFeed.ID})
+		require.NoError(t, err)
+		require.NotEmpty(t, audit.Entries)
+		assert.Equal(t, entities.AuditActionPurge, audit.Entries[0].Action)
+		assert.Nil(t, audit.Entries[0].After)
+	})
+}
+
+// forEachDatabase runs the test function against every database available.
+//
+// SQLite is always available. MySQL and PostgreSQL tests only run when a DSN pointing to a disposable
+// database is provided via the NEWS_APP_FEEDS_MGMT_TEST_MYSQL_DSN and NEWS_APP_FEEDS_MGMT_TEST_POSTGRES_DSN
+// env vars respectively (e.g. a local container).
+func forEachDatabase(t *testing.T, testFn func(t *testing.T, dbs *DatabaseService)) {
+	drivers := map[string]string{
+		DriverMySQL:    os.Getenv("NEWS_APP_FEEDS_MGMT_TEST_MYSQL_DSN"),
+		DriverPostgres: os.Getenv("NEWS_APP_FEEDS_MGMT_TEST_POSTGRES_DSN"),
+		DriverSQLite:   "",
+	}
+
+	for driver, dsn := range drivers {
+		t.Run(driver, func(t *testing.T) {
+			if driver == DriverSQLite {
+				dsn = SQLiteDSN(filepath.Join(t.TempDir(), "feeds.db"))
+			} else if dsn == "" {
+				t.Skipf("no DSN provided for driver %s", driver)
+			}
+
+			testFn(t, setupDatabaseService(t, driver, dsn))
+		})
+	}
+}
+
+// setupDatabaseService returns a DatabaseService backed by a freshly created schema and some data.
+func setupDatabaseService(t *testing.T, driver string, dsn string) *DatabaseService {
+	dbs, err := NewDatabaseService(driver, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { dbs.Close() })
+
+	resetSchema(t, dbs.Database)
+
+	data := entities.Feeds{
+		{URL: "http://feeds.bbci.co.uk/news/technology/rss.xml", Provider: "BBC News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.bbci.co.uk/news/uk/rss.xml", Provider: "BBC News", Category: "UK", Enabled: true},
+		{URL: "http://feeds.skynews.com/feeds/rss/technology.xml", Provider: "Sky News", Category: "Technology", Enabled: true},
+		{URL: "http://feeds.skynews.com/feeds/rss/uk.xml", Provider: "Sky News", Category: "UK", Enabled: false},
+	}
+
+	for _, feed := range data {
+		_, err := dbs.AddFeed(feed, entities.Actor{})
+		require.NoError(t, err)
+	}
+
+	return dbs
+}
+
+// resetSchema reverts all migrations, in case the database is not new, and applies them again.
+func resetSchema(t *testing.T, db *Database) {
+	for {
+		reverted, err := db.MigrateDown()
+		require.NoError(t, err)
+		if reverted == nil {
+			break
+		}
+	}
+
+	_, err := db.MigrateUp()
+	require.NoError(t, err)
+}
+
+func TestProviders(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		providers, err := dbs.GetProviders()
+		require.NoError(t, err)
+		require.Len(t, providers, 2)
+		assert.Equal(t, "BBC News", providers[0].Name)
+		assert.EqualValues(t, 2, providers[0].FeedCount)
+		sky := providers[1]
+		assert.Equal(t, "Sky News", sky.Name)
+
+		_, err = dbs.AddProvider("BBC News")
+		assert.IsType(t, &DBDUPError{}, err)
+
+		cnn, err := dbs.AddProvider("CNN")
+		require.NoError(t, err)
+		assert.Equal(t, "CNN", cnn.Name)
+
+		// Rename
+		err = dbs.RenameProvider(9999, "Unknown")
+		assert.IsType(t, &DBNotFoundError{}, err)
+		err = dbs.RenameProvider(sky.ID, "BBC News")
+		assert.IsType(t, &DBDUPError{}, err)
+		err = dbs.RenameProvider(sky.ID, "Sky")
+		require.NoError(t, err)
+
+		page, err := dbs.GetFeeds(entities.FeedQuery{Provider: "Sky"})
+		require.NoError(t, err)
+		assert.Len(t, page.Feeds, 2)
+
+		// Delete
+		err = dbs.DeleteProvider(9999, false, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+		err = dbs.DeleteProvider(sky.ID, false, entities.Actor{})
+		assert.IsType(t, &DBInUseError{}, err)
+		err = dbs.DeleteProvider(cnn.ID, false, entities.Actor{})
+		require.NoError(t, err)
+		err = dbs.DeleteProvider(sky.ID, true, entities.Actor{})
+		require.NoError(t, err)
+
+		providers, err = dbs.GetProviders()
+		require.NoError(t, err)
+		assert.Equal(t, entities.Providers{{ID: providers[0].ID, Name: "BBC News", FeedCount: 2}}, providers)
+
+		page, err = dbs.GetFeeds(entities.FeedQuery{})
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, page.Total)
+	})
+}
+
+func TestCategories(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		categories, err := dbs.GetCategories()
+		require.NoError(t, err)
+		require.Len(t, categories, 2)
+		tech := categories[0]
+		assert.Equal(t, "Technology", tech.Name)
+		assert.EqualValues(t, 2, tech.FeedCount)
+
+		_, err = dbs.AddCategory("UK")
+		assert.IsType(t, &DBDUPError{}, err)
+
+		sport, err := dbs.AddCategory("Sport")
+		require.NoError(t, err)
+
+		err = dbs.RenameCategory(tech.ID, "UK")
+		assert.IsType(t, &DBDUPError{}, err)
+		err = dbs.RenameCategory(tech.ID, "Tech")
+		require.NoError(t, err)
+
+		err = dbs.DeleteCategory(tech.ID, false, entities.Actor{})
+		assert.IsType(t, &DBInUseError{}, err)
+		err = dbs.DeleteCategory(sport.ID, false, entities.Actor{})
+		require.NoError(t, err)
+		err = dbs.DeleteCategory(sport.ID, false, entities.Actor{})
+		assert.IsType(t, &DBNotFoundError{}, err)
+		err = dbs.DeleteCategory(tech.ID, true, entities.Actor{})
+		require.NoError(t, err)
+
+		categories, err = dbs.GetCategories()
+		require.NoError(t, err)
+		assert.Equal(t, entities.Categories{{ID: categories[0].ID, Name: "UK", FeedCount: 2}}, categories)
+		uk := categories[0]
+
+		// Adding a feed to a deleted category restores it
+		_, err = dbs.AddFeed(entities.Feed{URL: "http://example.com/rss.xml", Provider: "Example", Category: "Tech"},
+			entities.Actor{})
+		require.NoError(t, err)
+
+		categories, err = dbs.GetCategories()
+		require.NoError(t, err)
+		assert.Equal(t, entities.Categories{
+			{ID: tech.ID, Name: "Tech", FeedCount: 1},
+			{ID: uk.ID, Name: "UK", FeedCount: 2}}, categories)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		keys, err := dbs.GetAPIKeys()
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+
+		ci, err := dbs.AddAPIKey(entities.APIKey{Name: "ci", Scopes: []string{"read", "write"}}, "hash-1")
+		require.NoError(t, err)
+		assert.Len(t, ci.ID, 36)
+		assert.Equal(t, "ci", ci.Name)
+		assert.False(t, ci.CreatedAt.IsZero())
+		_, err = dbs.AddAPIKey(entities.APIKey{Name: "admin", Scopes: []string{"admin"}}, "hash-3")
+		require.NoError(t, err)
+
+		_, err = dbs.AddAPIKey(entities.APIKey{Name: "ci", Scopes: []string{"read"}}, "hash-2")
+		assert.IsType(t, &DBDUPError{}, err)
+		_, err = dbs.AddAPIKey(entities.APIKey{Name: "other", Scopes: []string{"read"}}, "hash-1")
+		assert.IsType(t, &DBDUPError{}, err)
+
+		key, err := dbs.GetAPIKeyByHash("hash-1")
+		require.NoError(t, err)
+		assert.Equal(t, ci.ID, key.ID)
+		assert.Equal(t, []string{"read", "write"}, key.Scopes)
+		_, err = dbs.GetAPIKeyByHash("unknown")
+		assert.IsType(t, &DBNotFoundError{}, err)
+
+		_, err = dbs.AddAPIKey(entities.APIKey{Name: "none"}, "hash-4")
+		require.NoError(t, err)
+		key, err = dbs.GetAPIKeyByHash("hash-4")
+		require.NoError(t, err)
+		assert.Equal(t, []string{}, key.Scopes)
+
+		keys, err = dbs.GetAPIKeys()
+		require.NoError(t, err)
+		require.Len(t, keys, 3)
+		assert.Equal(t, "admin", keys[0].Name)
+		assert.Equal(t, "ci", keys[1].Name)
+
+		// Revoke
+		err = dbs.DeleteAPIKey(ci.ID)
+		require.NoError(t, err)
+		err = dbs.DeleteAPIKey(ci.ID)
+		assert.IsType(t, &DBNotFoundError{}, err)
+		_, err = dbs.GetAPIKeyByHash("hash-1")
+		assert.IsType(t, &DBNotFoundError{}, err)
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	forEachDatabase(t, func(t *testing.T, dbs *DatabaseService) {
+		jane := entities.Actor{Name: "user:jane", RequestID: "req-1"}
+
+		feed, err := dbs.AddFeed(entities.Feed{URL: "http://example.com/rss.xml", Provider: "Example", Category: "UK",
+			Enabled: true}, jane)
+		require.NoError(t, err)
+		err = dbs.SetFeedState(feed.ID, false, jane)
+		require.NoError(t, err)
+		// Nothing changes, so nothing is recorded
+		err = dbs.SetFeedState(feed.ID, false, jane)
+		require.NoError(t, err)
+		newProvider := "Other"
+		_, err = dbs.UpdateFeed(feed.ID, entities.FeedUpdate{Provider: &newProvider}, jane)
+		require.NoError(t, err)
+		// Updates setting fields to the values they already have aren't recorded
+		_, err = dbs.UpdateFeed(feed.ID, entities.FeedUpdate{Provider: &newProvider, URL: &feed.URL}, jane)
+		require.NoError(t, err)
+		// Changes that fail are not recorded either
+		takenURL := "http://feeds.bbci.co.uk/news/uk/rss.xml"
+		_, err = dbs.UpdateFeed(feed.ID, entities.FeedUpdate{URL: &takenURL}, jane)
+		assert.IsType(t, &DBDUPError{}, err)
+		err = dbs.DeleteFeed(feed.ID, entities.Actor{Name: "apikey:ci"})
+		require.NoError(t, err)
+
+		page, err := dbs.GetAuditEntries(entities.AuditQuery{FeedID: feed.ID})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 4)
+
+		deleted, updated, disabled, created := page.Entries[0], page.Entries[1], page.Entries[2], page.Entries[3]
+		assert.Equal(t, entities.AuditActionCreate, created.Action)
+		assert.Equal(t, "user:jane", created.Actor)
+		assert.Equal(t, "req-1", created.RequestID)
+		assert.Nil(t, created.Before)
+		require.NotNil(t, created.After)
+		assert.Equal(t, feed.URL, created.After.URL)
+		assert.True(t, created.After.Enabled)
+		assert.False(t, created.CreatedAt.IsZero())
+
+		assert.Equal(t, entities.AuditActionDisable, disabled.Action)
+		assert.True(t, disabled.Before.Enabled)
+		assert.False(t, disabled.After.Enabled)
+
+		assert.Equal(t, entities.AuditActionUpdate, updated.Action)
+		assert.Equal(t, "Example", updated.Before.Provider)
+		assert.Equal(t, "Other", updated.After.Provider)
+
+		assert.Equal(t, entities.AuditActionDelete, deleted.Action)
+		assert.Equal(t, "apikey:ci", deleted.Actor)
+		assert.Equal(t, "Other", deleted.Before.Provider)
+		assert.Nil(t, deleted.Before.DeletedAt)
+		require.NotNil(t, deleted.After)
+		assert.NotNil(t, deleted.After.DeletedAt)
+
+		// Feeds deleted along with their provider are recorded too
+		providers, err := dbs.GetProviders()
+		require.NoError(t, err)
+		err = dbs.DeleteProvider(providers[0].ID, true, jane)
+		require.NoError(t, err)
+
+		page, err = dbs.GetAuditEntries(entities.AuditQuery{Actor: "user:jane"})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 5)
+		for _, entry := range page.Entries[:2] {
+			assert.Equal(t, entities.AuditActionDelete, entry.Action)
+			assert.Equal(t, "BBC News", entry.Before.Provider)
+		}
+
+		// Time range
+		page, err = dbs.GetAuditEntries(entities.AuditQuery{Since: created.CreatedAt})
+		require.NoError(t, err)
+		assert.Len(t, page.Entries, 6)
+		page, err = dbs.GetAuditEntries(entities.AuditQuery{Until: created.CreatedAt})
+		require.NoError(t, err)
+		for _, entry := range page.Entries {
+			assert.Less(t, entry.ID, created.ID)
+		}
+
+		// Pagination
+		var ids []uint64
+		query := entities.AuditQuery{Limit: 4}
+		for {
+			page, err := dbs.GetAuditEntries(query)
+			require.NoError(t, err)
+			for _, entry := range page.Entries {
+				ids = append(ids, entry.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		require.Len(t, ids, 10)
+		for i := 1; i < len(ids); i++ {
+			assert.Greater(t, ids[i-1], ids[i])
+		}
+
+		_, err = dbs.GetAuditEntries(entities.AuditQuery{Cursor: "not-a-cursor"})
+		assert.IsType(t, &DBInvalidCursorError{}, err)
+	})
+}
@@ -0,0 +1,234 @@
+// Package urlpolicy decides which URLs the service may fetch, so that URLs provided by users can't be used to reach
+// internal services (server-side request forgery).
+package urlpolicy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// DefaultAllowedPorts holds the ports URLs may use by default.
+var DefaultAllowedPorts = []int{80, 443}
+
+// maxRedirects is the number of redirects followed, the same as the http.Client default.
+const maxRedirects = 10
+
+// PolicyError is returned when a URL is not allowed by the policy.
+type PolicyError struct {
+	Msg string
+}
+
+func (e *PolicyError) Error() string { return "url not allowed: " + e.Msg }
+
+// deniedNetworks holds the address ranges that are not reachable from the internet, or that reach the host itself.
+// IPv4-mapped IPv6 addresses are checked as IPv4 addresses.
+var deniedNetworks = parseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, including cloud metadata services
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved, including broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // IPv4/IPv6 translation
+	"64:ff9b:1::/48", // local-use IPv4/IPv6 translation
+	"2002::/16",      // 6to4, which embeds IPv4 addresses
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"fec0::/10",      // site-local, deprecated but still routed internally
+	"ff00::/8",       // multicast
+)
+
+// Policy decides which URLs may be fetched.
+//
+// Host lists match the hosts in them along with their subdomains, e.g. 'example.com' matches 'feeds.example.com'.
+// IP addresses may be listed as well.
+type Policy struct {
+	// AllowPrivateAddresses allows hosts resolving to private, loopback, link-local and other addresses that are not
+	// reachable from the internet.
+	AllowPrivateAddresses bool
+	// AllowedHosts, if not empty, only allows the hosts listed.
+	AllowedHosts []string
+	// DeniedHosts never allows the hosts listed.
+	DeniedHosts []string
+	// AllowedPorts, if not empty, only allows the ports listed. Ports left out of URLs are the scheme default ones.
+	AllowedPorts []int
+	// Resolver resolves host names. The default resolver is used when nil.
+	Resolver *net.Resolver
+}
+
+// NewPolicy returns a Policy that denies private addresses and only allows the default ports.
+func NewPolicy() *Policy {
+	return &Policy{AllowedPorts: DefaultAllowedPorts}
+}
+
+// Check checks whether the policy allows the URL provided. The host is resolved so that the addresses it resolves to
+// can be checked too.
+// All errors returned are of type *PolicyError.
+func (p *Policy) Check(ctx context.Context, rawURL string) error {
+	u, err := p.checkURL(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !p.allowIP(ip) {
+			return &PolicyError{Msg: fmt.Sprintf("address <%s> is private or reserved", host)}
+		}
+		return nil
+	}
+
+	resolver := p.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return &PolicyError{Msg: fmt.Sprintf("host <%s> could not be resolved", host)}
+	}
+
+	for _, addr := range addrs {
+		if !p.allowIP(addr.IP) {
+			return &PolicyError{Msg: fmt.Sprintf("host <%s> resolves to private or reserved address <%s>", host,
+				addr.IP)}
+		}
+	}
+
+	return nil
+}
+
+// CheckRedirect checks whether the policy allows the URL of a redirect, without resolving its host. It is meant to
+// be used as http.Client.CheckRedirect along with Control, which checks the addresses connected to.
+func (p *Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	_, err := p.checkURL(req.URL.String())
+	return err
+}
+
+// Control checks whether the policy allows connecting to the address provided, once resolved. It is meant to be used
+// as net.Dialer.Control, so that hosts can't resolve to a different address when connected to than when checked.
+func (p *Policy) Control(network string, address string, c syscall.RawConn) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return &PolicyError{Msg: fmt.Sprintf("invalid address <%s>", address)}
+	}
+
+	if err := p.checkPort(port); err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return &PolicyError{Msg: fmt.Sprintf("invalid address <%s>", address)}
+	}
+
+	if !p.allowIP(ip) {
+		return &PolicyError{Msg: fmt.Sprintf("address <%s> is private or reserved", host)}
+	}
+	return nil
+}
+
+// checkURL checks the scheme, host and port of a URL, without resolving the host.
+func (p *Policy) checkURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &PolicyError{Msg: "url is not a valid HTTP URL"}
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+
+	if matchHost(host, p.DeniedHosts) {
+		return nil, &PolicyError{Msg: fmt.Sprintf("host <%s> is denied", host)}
+	}
+
+	if len(p.AllowedHosts) != 0 && !matchHost(host, p.AllowedHosts) {
+		return nil, &PolicyError{Msg: fmt.Sprintf("host <%s> is not in the allowed hosts", host)}
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	if err := p.checkPort(port); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// checkPort checks whether the port provided is allowed.
+func (p *Policy) checkPort(port string) *PolicyError {
+	if len(p.AllowedPorts) == 0 {
+		return nil
+	}
+
+	n, err := strconv.Atoi(port)
+	if err == nil {
+		for _, allowed := range p.AllowedPorts {
+			if n == allowed {
+				return nil
+			}
+		}
+	}
+
+	return &PolicyError{Msg: fmt.Sprintf("port %s is not allowed", port)}
+}
+
+// allowIP checks whether the address provided is allowed.
+func (p *Policy) allowIP(ip net.IP) bool {
+	if p.AllowPrivateAddresses {
+		return true
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	for _, network := range deniedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHost checks whether the host is one of the hosts provided, or a subdomain of one of them.
+func matchHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSuffix(h, "."))
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs parses the networks provided, in CIDR notation.
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
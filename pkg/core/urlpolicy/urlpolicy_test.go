@@ -0,0 +1,99 @@
+package urlpolicy_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/urlpolicy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	tests := map[string]struct {
+		policy        *urlpolicy.Policy
+		rawURL        string
+		expectedError string
+	}{
+		"public address": {policy: urlpolicy.NewPolicy(), rawURL: "http://93.184.216.34/rss.xml"},
+		"loopback": {policy: urlpolicy.NewPolicy(), rawURL: "http://127.0.0.1/rss.xml",
+			expectedError: "url not allowed: address <127.0.0.1> is private or reserved"},
+		"metadata service": {policy: urlpolicy.NewPolicy(), rawURL: "http://169.254.169.254/latest/meta-data/",
+			expectedError: "url not allowed: address <169.254.169.254> is private or reserved"},
+		"private": {policy: urlpolicy.NewPolicy(), rawURL: "https://10.1.2.3/rss.xml",
+			expectedError: "url not allowed: address <10.1.2.3> is private or reserved"},
+		"ipv6 loopback": {policy: urlpolicy.NewPolicy(), rawURL: "http://[::1]/rss.xml",
+			expectedError: "url not allowed: address <::1> is private or reserved"},
+		"ipv4-mapped loopback": {policy: urlpolicy.NewPolicy(), rawURL: "http://[::ffff:127.0.0.1]/rss.xml",
+			expectedError: "url not allowed: address <::ffff:127.0.0.1> is private or reserved"},
+		"6to4": {policy: urlpolicy.NewPolicy(), rawURL: "http://[2002:7f00:1::]/rss.xml",
+			expectedError: "url not allowed: address <2002:7f00:1::> is private or reserved"},
+		"local-use nat64": {policy: urlpolicy.NewPolicy(), rawURL: "http://[64:ff9b:1::a00:1]/rss.xml",
+			expectedError: "url not allowed: address <64:ff9b:1::a00:1> is private or reserved"},
+		"unique local": {policy: urlpolicy.NewPolicy(), rawURL: "http://[fd00::1]/rss.xml",
+			expectedError: "url not allowed: address <fd00::1> is private or reserved"},
+		"site-local": {policy: urlpolicy.NewPolicy(), rawURL: "http://[fec0::1]/rss.xml",
+			expectedError: "url not allowed: address <fec0::1> is private or reserved"},
+		"localhost": {policy: urlpolicy.NewPolicy(), rawURL: "http://localhost/rss.xml",
+			expectedError: "url not allowed: host <localhost> resolves to private or reserved address"},
+		"private allowed": {policy: &urlpolicy.Policy{AllowPrivateAddresses: true},
+			rawURL: "http://127.0.0.1:8080/rss.xml"},
+		"port not allowed": {policy: urlpolicy.NewPolicy(), rawURL: "http://93.184.216.34:8080/rss.xml",
+			expectedError: "url not allowed: port 8080 is not allowed"},
+		"any port": {policy: &urlpolicy.Policy{}, rawURL: "http://93.184.216.34:8080/rss.xml"},
+		"denied host": {policy: &urlpolicy.Policy{DeniedHosts: []string{"internal.example.com"}},
+			rawURL:        "http://Feeds.Internal.Example.com/rss.xml",
+			expectedError: "url not allowed: host <feeds.internal.example.com> is denied"},
+		"host not allowed": {policy: &urlpolicy.Policy{AllowedHosts: []string{"93.184.216.34"}},
+			rawURL:        "http://93.184.216.35/rss.xml",
+			expectedError: "url not allowed: host <93.184.216.35> is not in the allowed hosts"},
+		"allowed host": {policy: &urlpolicy.Policy{AllowedHosts: []string{"93.184.216.34"}},
+			rawURL: "http://93.184.216.34/rss.xml"},
+		"invalid url": {policy: urlpolicy.NewPolicy(), rawURL: "ftp://93.184.216.34/rss.xml",
+			expectedError: "url not allowed: url is not a valid HTTP URL"},
+		"unresolvable host": {policy: urlpolicy.NewPolicy(), rawURL: "http://feeds.invalid/rss.xml",
+			expectedError: "url not allowed: host <feeds.invalid> could not be resolved"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.policy.Check(context.Background(), test.rawURL)
+			if test.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			// Hosts may resolve to several addresses, and only the first one denied is reported
+			assert.IsType(t, &urlpolicy.PolicyError{}, err)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.expectedError)
+			}
+		})
+	}
+}
+
+func TestControl(t *testing.T) {
+	policy := urlpolicy.NewPolicy()
+
+	assert.NoError(t, policy.Control("tcp4", "93.184.216.34:443", nil))
+	assert.EqualError(t, policy.Control("tcp4", "127.0.0.1:443", nil),
+		"url not allowed: address <127.0.0.1> is private or reserved")
+	assert.EqualError(t, policy.Control("tcp6", "[fe80::1]:80", nil),
+		"url not allowed: address <fe80::1> is private or reserved")
+	assert.EqualError(t, policy.Control("tcp4", "93.184.216.34:22", nil), "url not allowed: port 22 is not allowed")
+}
+
+func TestCheckRedirect(t *testing.T) {
+	policy := &urlpolicy.Policy{DeniedHosts: []string{"internal"}}
+
+	redirect := func(rawURL string, via int) error {
+		u, err := url.Parse(rawURL)
+		assert.NoError(t, err)
+		return policy.CheckRedirect(&http.Request{URL: u}, make([]*http.Request, via))
+	}
+
+	assert.NoError(t, redirect("https://example.com/rss.xml", 1))
+	assert.EqualError(t, redirect("http://internal/rss.xml", 1), "url not allowed: host <internal> is denied")
+	assert.Error(t, redirect("https://example.com/rss.xml", 10))
+}
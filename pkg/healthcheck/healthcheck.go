@@ -0,0 +1,171 @@
+// Package healthcheck periodically checks whether the enabled feeds can still be fetched.
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+)
+
+// actor is who feeds disabled by the checker are recorded as disabled by in the audit log.
+var actor = entities.Actor{Name: "healthcheck"}
+
+// Checker fetches every enabled feed at regular intervals and records the outcome in the repository.
+type Checker struct {
+	Logger  log.Logger
+	Repo    core.Repository
+	Fetcher *fetcher.Fetcher
+	// Interval is the time between the start of consecutive rounds of checks.
+	Interval time.Duration
+	// Concurrency is the maximum number of feeds checked at the same time.
+	Concurrency int
+	// DisableAfter disables feeds after that many consecutive failed checks. Zero never disables feeds.
+	DisableAfter int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewChecker creates a new checker.
+func NewChecker(logger log.Logger, repo core.Repository, fetcher *fetcher.Fetcher, interval time.Duration,
+	concurrency int, disableAfter int) *Checker {
+	return &Checker{
+		Logger:       logger,
+		Repo:         repo,
+		Fetcher:      fetcher,
+		Interval:     interval,
+		Concurrency:  concurrency,
+		DisableAfter: disableAfter,
+	}
+}
+
+// Start starts checking feeds in the background. The first round of checks starts straight away.
+func (c *Checker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	go c.run(ctx)
+}
+
+// ShutDown stops checking feeds, cancelling the checks in progress, and waits for them to finish.
+// Checks cancelled are not recorded.
+func (c *Checker) ShutDown(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run checks all the feeds every interval until the context is cancelled.
+func (c *Checker) run(ctx context.Context) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.CheckFeeds(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckFeeds checks all the enabled feeds once.
+// It returns when all the checks are done, or as soon as the checks in progress finish if the context is cancelled.
+func (c *Checker) CheckFeeds(ctx context.Context) {
+	c.Logger.Debug("checking feeds", log.Field("type", "healthcheck"))
+
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	feeds := make(chan entities.Feed)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range feeds {
+				c.checkFeed(ctx, feed)
+			}
+		}()
+	}
+
+	enabled := true
+	query := entities.FeedQuery{Enabled: &enabled, Sort: entities.FeedSortURL}
+	err := c.Repo.IterateFeeds(query, func(feed entities.Feed) error {
+		select {
+		case feeds <- feed:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	close(feeds)
+	wg.Wait()
+
+	if err != nil && ctx.Err() == nil {
+		c.Logger.Error(fmt.Sprintf("error listing feeds to check: %s", err.Error()), log.Field("type", "healthcheck"))
+	}
+}
+
+// checkFeed fetches a feed, records the outcome and disables the feed if it failed too many times in a row.
+func (c *Checker) checkFeed(ctx context.Context, feed entities.Feed) {
+	statusCode, err := c.Fetcher.Check(ctx, feed.URL)
+	if ctx.Err() != nil {
+		// The check was interrupted, so it says nothing about the feed
+		return
+	}
+
+	check := entities.FeedCheck{CheckedAt: time.Now().UTC(), StatusCode: statusCode}
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	health, err := c.Repo.RecordFeedCheck(feed.ID, check)
+	if _, ok := err.(*repository.DBNotFoundError); ok {
+		// The feed was deleted while being checked
+		return
+	} else if err != nil {
+		c.Logger.Error(fmt.Sprintf("error recording check of feed: %s", err.Error()),
+			log.Field("type", "healthcheck"), log.Field("feed_id", feed.ID))
+		return
+	}
+
+	if c.DisableAfter <= 0 || health.ConsecutiveFailures < c.DisableAfter {
+		return
+	}
+
+	err = c.Repo.SetFeedState(feed.ID, false, actor)
+	if _, ok := err.(*repository.DBNotFoundError); ok {
+		return
+	} else if err != nil {
+		c.Logger.Error(fmt.Sprintf("error disabling feed: %s", err.Error()),
+			log.Field("type", "healthcheck"), log.Field("feed_id", feed.ID))
+		return
+	}
+
+	c.Logger.Warn(fmt.Sprintf("feed disabled after %d consecutive failed checks", health.ConsecutiveFailures),
+		log.Field("type", "healthcheck"), log.Field("feed_id", feed.ID), log.Field("feed_url", feed.URL))
+}
@@ -0,0 +1,162 @@
+package healthcheck_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/fetcher"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository/memory"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/healthcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rssDocument = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example</title></channel></rss>`
+
+func TestCheckFeeds(t *testing.T) {
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rss.xml":
+			w.Write([]byte(rssDocument))
+		case "/page.html":
+			w.Write([]byte("<html><body>Not a feed</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer feedServer.Close()
+
+	repo := memory.NewRepository()
+	ids := map[string]string{}
+	for path, enabled := range map[string]bool{"/rss.xml": true, "/page.html": true, "/gone.xml": true,
+		"/disabled.xml": false} {
+		created, err := repo.AddFeed(entities.Feed{URL: feedServer.URL + path, Provider: "Example",
+			Category: "Tests", Enabled: enabled}, entities.Actor{})
+		require.NoError(t, err)
+		ids[path] = created.ID
+	}
+
+	checker := healthcheck.NewChecker(log.NullLogger{}, repo, fetcher.NewFetcher(time.Second, 1<<20, nil),
+		time.Hour, 2, 2)
+
+	checker.CheckFeeds(context.Background())
+
+	health, err := repo.GetFeedHealth(ids["/rss.xml"])
+	require.NoError(t, err)
+	assert.Equal(t, 200, health.LastStatusCode)
+	assert.Equal(t, 0, health.ConsecutiveFailures)
+	assert.NotNil(t, health.LastSuccessAt)
+	assert.Nil(t, health.LastFailureAt)
+
+	health, err = repo.GetFeedHealth(ids["/page.html"])
+	require.NoError(t, err)
+	assert.Equal(t, 200, health.LastStatusCode)
+	assert.Equal(t, 1, health.ConsecutiveFailures)
+	assert.Contains(t, health.LastError, "not a valid feed")
+
+	health, err = repo.GetFeedHealth(ids["/gone.xml"])
+	require.NoError(t, err)
+	assert.Equal(t, 404, health.LastStatusCode)
+	assert.Equal(t, 1, health.ConsecutiveFailures)
+
+	// Disabled feeds are not checked
+	health, err = repo.GetFeedHealth(ids["/disabled.xml"])
+	require.NoError(t, err)
+	assert.Nil(t, health.LastCheckedAt)
+
+	// Failing feeds are disabled after the second failure in a row
+	checker.CheckFeeds(context.Background())
+
+	for path, expectedEnabled := range map[string]bool{"/rss.xml": true, "/page.html": false, "/gone.xml": false} {
+		feed, err := repo.GetFeed(ids[path])
+		require.NoError(t, err)
+		assert.Equal(t, expectedEnabled, feed.Enabled, path)
+	}
+
+	// The audit log records who disabled them
+	page, err := repo.GetAuditEntries(entities.AuditQuery{FeedID: ids["/gone.xml"]})
+	require.NoError(t, err)
+	require.NotEmpty(t, page.Entries)
+	assert.Equal(t, entities.AuditActionDisable, page.Entries[0].Action)
+	assert.Equal(t, "healthcheck", page.Entries[0].Actor)
+}
+
+func TestCheckFeedsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(rssDocument))
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer feedServer.Close()
+
+	repo := memory.NewRepository()
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+		_, err := repo.AddFeed(entities.Feed{URL: feedServer.URL + path, Provider: "Example", Category: "Tests",
+			Enabled: true}, entities.Actor{})
+		require.NoError(t, err)
+	}
+
+	checker := healthcheck.NewChecker(log.NullLogger{}, repo, fetcher.NewFetcher(time.Second, 1<<20, nil),
+		time.Hour, 2, 0)
+	checker.CheckFeeds(context.Background())
+
+	assert.Equal(t, 2, maxInFlight)
+}
+
+func TestCheckerShutDown(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Write([]byte(rssDocument))
+	}))
+	defer feedServer.Close()
+
+	repo := memory.NewRepository()
+	feed, err := repo.AddFeed(entities.Feed{URL: feedServer.URL + "/rss.xml", Provider: "Example",
+		Category: "Tests", Enabled: true}, entities.Actor{})
+	require.NoError(t, err)
+
+	checker := healthcheck.NewChecker(log.NullLogger{}, repo, fetcher.NewFetcher(time.Second, 1<<20, nil),
+		10*time.Millisecond, 1, 0)
+	checker.Start()
+
+	// The feed is checked straight away, and then every interval
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = checker.ShutDown(ctx)
+	assert.NoError(t, err)
+
+	health, err := repo.GetFeedHealth(feed.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, health.LastSuccessAt)
+}
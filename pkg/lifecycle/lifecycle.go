@@ -12,19 +12,42 @@ import (
 	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
 )
 
+// shutdownTimeout is how long the HTTP server and background workers have to shut down gracefully.
+const shutdownTimeout = 5 * time.Second
+
 // TerminateHandler terminates the application.
-// This function waits on a SIGINT or SIGTERM signal and shuts down the HTTP server gracefully.
-func TerminateHandler(logger log.Logger, server core.ShutDowner) {
+// This function waits on a SIGINT or SIGTERM signal and shuts down the HTTP server, and any background workers
+// provided along with it, gracefully and in order.
+func TerminateHandler(logger log.Logger, server core.ShutDowner, workers ...core.ShutDowner) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Info("shutting down application ...")
 
-	// We will wait 5 seconds for the server to shutdown gracefully
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	err := server.ShutDown(ctx)
 	if err != nil {
 		logger.Error(fmt.Sprintf("server failed to shutdown gracefully: %s", err.Error()))
 	}
+
+	shutDownWorkers(ctx, logger, workers)
+}
+
+// ShutDownWorkers shuts down the background workers provided gracefully and in order.
+// It is meant for when the application terminates without a signal, e.g. because the HTTP server failed to serve.
+func ShutDownWorkers(logger log.Logger, workers ...core.ShutDowner) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	shutDownWorkers(ctx, logger, workers)
+}
+
+// shutDownWorkers shuts down the background workers provided in order, until ctx is done.
+func shutDownWorkers(ctx context.Context, logger log.Logger, workers []core.ShutDowner) {
+	for _, worker := range workers {
+		err := worker.ShutDown(ctx)
+		if err != nil {
+			logger.Error(fmt.Sprintf("worker failed to shutdown gracefully: %s", err.Error()))
+		}
+	}
 }
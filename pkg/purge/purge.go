@@ -0,0 +1,107 @@
+// Package purge periodically deletes permanently the feeds deleted longer ago than the retention period.
+package purge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+)
+
+// batchSize is the maximum number of feeds purged in a single transaction.
+const batchSize = 100
+
+// actor is who feeds purged are recorded as purged by in the audit log.
+var actor = entities.Actor{Name: "purge"}
+
+// Purger purges deleted feeds at regular intervals.
+type Purger struct {
+	Logger log.Logger
+	Repo   core.Repository
+	// Interval is the time between the start of consecutive purges.
+	Interval time.Duration
+	// Retention is the time deleted feeds are kept for, so that they can be restored.
+	Retention time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPurger creates a new purger.
+func NewPurger(logger log.Logger, repo core.Repository, interval time.Duration, retention time.Duration) *Purger {
+	return &Purger{
+		Logger:    logger,
+		Repo:      repo,
+		Interval:  interval,
+		Retention: retention,
+	}
+}
+
+// Start starts purging feeds in the background. The first purge starts straight away.
+func (p *Purger) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go p.run(ctx)
+}
+
+// ShutDown stops purging feeds and waits for the purge in progress, if any, to finish.
+func (p *Purger) ShutDown(ctx context.Context) error {
+	if p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run purges feeds every interval until the context is cancelled.
+func (p *Purger) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.PurgeFeeds(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeFeeds purges all the feeds deleted longer ago than the retention period, in batches.
+// It returns the number of feeds purged. If the context is cancelled, it returns once the batch in progress is done.
+func (p *Purger) PurgeFeeds(ctx context.Context) (purged int) {
+	deletedBefore := time.Now().UTC().Add(-p.Retention)
+
+	for ctx.Err() == nil {
+		n, err := p.Repo.PurgeDeletedFeeds(deletedBefore, batchSize, actor)
+		if err != nil {
+			p.Logger.Error(fmt.Sprintf("error purging deleted feeds: %s", err.Error()), log.Field("type", "purge"))
+			break
+		}
+
+		purged += n
+		if n < batchSize {
+			break
+		}
+	}
+
+	if purged > 0 {
+		p.Logger.Info(fmt.Sprintf("purged %d deleted feeds", purged), log.Field("type", "purge"))
+	}
+	return purged
+}
@@ -0,0 +1,70 @@
+package purge_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/entities"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/log"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/core/repository/memory"
+	"github.com/gustavooferreira/news-app-feeds-mgmt-service/pkg/purge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeFeeds(t *testing.T) {
+	repo := memory.NewRepository()
+
+	var deleted []string
+	for i := 0; i < 150; i++ {
+		feed, err := repo.AddFeed(entities.Feed{URL: fmt.Sprintf("http://example.com/rss.xml?page=%d", i),
+			Provider: "Example", Category: "Tests"}, entities.Actor{})
+		require.NoError(t, err)
+		err = repo.DeleteFeed(feed.ID, entities.Actor{})
+		require.NoError(t, err)
+		deleted = append(deleted, feed.ID)
+	}
+
+	kept, err := repo.AddFeed(entities.Feed{URL: "http://example.com/kept.xml", Provider: "Example",
+		Category: "Tests"}, entities.Actor{})
+	require.NoError(t, err)
+
+	// Feeds deleted within the retention period are kept
+	purger := purge.NewPurger(log.NullLogger{}, repo, time.Hour, time.Hour)
+	assert.Equal(t, 0, purger.PurgeFeeds(context.Background()))
+
+	// All of them are purged, across batches
+	purger.Retention = 0
+	assert.Equal(t, 150, purger.PurgeFeeds(context.Background()))
+
+	for _, id := range deleted {
+		_, err := repo.RestoreFeed(id, entities.Actor{})
+		assert.IsType(t, &repository.DBNotFoundError{}, err)
+	}
+
+	_, err = repo.GetFeed(kept.ID)
+	assert.NoError(t, err)
+
+	page, err := repo.GetAuditEntries(entities.AuditQuery{Actor: "purge"})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 150)
+	for _, entry := range page.Entries {
+		assert.Equal(t, entities.AuditActionPurge, entry.Action)
+	}
+}
+
+func TestPurgerShutDown(t *testing.T) {
+	purger := purge.NewPurger(log.NullLogger{}, memory.NewRepository(), time.Hour, time.Hour)
+
+	// Shutting down a purger never started is fine
+	require.NoError(t, purger.ShutDown(context.Background()))
+
+	purger.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, purger.ShutDown(ctx))
+}